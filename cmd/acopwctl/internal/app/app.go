@@ -12,6 +12,8 @@ import (
 	"git.sr.ht/~jamesponddotco/acciopassword/internal/build"
 	"git.sr.ht/~jamesponddotco/acciopassword/internal/config"
 	"git.sr.ht/~jamesponddotco/acciopassword/internal/database"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/logging"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/sandbox"
 	"git.sr.ht/~jamesponddotco/acciopassword/internal/server"
 	"git.sr.ht/~jamesponddotco/xstd-go/xlog"
 	"github.com/spf13/cobra"
@@ -26,6 +28,8 @@ func Run() int {
 		return 1
 	}
 
+	logger = logging.Redact(logger)
+
 	rootCmd := &cobra.Command{
 		Use:               "acopwctl",
 		Short:             "acopwctl is a CLI tool for controlling the Accio Password server.",
@@ -49,6 +53,10 @@ func Run() int {
 func AddCommands(rootCmd *cobra.Command, logger *zap.Logger) {
 	addStartCommand(rootCmd, logger)
 	addStopCommand(rootCmd, logger)
+	addKeyCommand(rootCmd, logger)
+	addSigningKeyCommand(rootCmd, logger)
+	addAuditCommand(rootCmd, logger)
+	addCombineCommand(rootCmd)
 }
 
 func addStartCommand(rootCmd *cobra.Command, logger *zap.Logger) {
@@ -65,6 +73,27 @@ func addStartCommand(rootCmd *cobra.Command, logger *zap.Logger) {
 				return
 			}
 
+			if err := cfg.Validate(); err != nil {
+				logger.Error("Invalid config", zap.Error(err))
+
+				return
+			}
+
+			if cfg.Sandbox.Enabled && !sandbox.Sandboxed() {
+				policy, err := sandboxPolicy(cfg, configPath)
+				if err != nil {
+					logger.Error("Failed to create sandbox policy", zap.Error(err))
+
+					return
+				}
+
+				if err := sandbox.Enter(policy); err != nil {
+					logger.Error("Failed to sandbox server", zap.Error(err))
+
+					return
+				}
+			}
+
 			db, err := database.Open(logger, cfg.Database.DSN)
 			if err != nil {
 				logger.Error("Failed to open database", zap.Error(err))
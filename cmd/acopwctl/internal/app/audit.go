@@ -0,0 +1,127 @@
+package app
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/config"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/database"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func addAuditCommand(rootCmd *cobra.Command, logger *zap.Logger) {
+	var configPath string
+
+	auditCmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Inspect the audit log.",
+	}
+
+	auditCmd.PersistentFlags().StringVarP(&configPath, "config", "c", "config.json", "Path to the configuration file.")
+
+	addAuditVerifyCommand(auditCmd, &configPath, logger)
+	addAuditExportCommand(auditCmd, &configPath, logger)
+
+	rootCmd.AddCommand(auditCmd)
+}
+
+func addAuditVerifyCommand(auditCmd *cobra.Command, configPath *string, logger *zap.Logger) {
+	verifyCmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Verify the hash chain of the audit log.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, key, err := openAuditLog(*configPath, logger)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			count, head, err := db.VerifyAuditLog(key)
+			if err != nil {
+				return fmt.Errorf("failed to verify audit log after %d entries: %w", count, err)
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "Entries: %d\nHead:    %s\n\nThe audit log is intact. Record the head hash to detect entries removed from the end later.\n", count, head)
+
+			return nil
+		},
+	}
+
+	auditCmd.AddCommand(verifyCmd)
+}
+
+func addAuditExportCommand(auditCmd *cobra.Command, configPath *string, logger *zap.Logger) {
+	var output string
+
+	exportCmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export the audit log as JSON Lines.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, _, err := openAuditLog(*configPath, logger)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			var out io.Writer = cmd.OutOrStdout()
+
+			if output != "" {
+				file, err := os.OpenFile(output, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+				if err != nil {
+					return fmt.Errorf("failed to create export file: %w", err)
+				}
+				defer file.Close()
+
+				out = file
+			}
+
+			var (
+				writer  = bufio.NewWriter(out)
+				encoder = json.NewEncoder(writer)
+			)
+
+			err = db.AuditEntries(func(entry *database.AuditEntry) error {
+				return encoder.Encode(entry) //nolint:wrapcheck // wrapped below
+			})
+			if err != nil {
+				return fmt.Errorf("failed to export audit log: %w", err)
+			}
+
+			if err := writer.Flush(); err != nil {
+				return fmt.Errorf("failed to export audit log: %w", err)
+			}
+
+			return nil
+		},
+	}
+
+	exportCmd.Flags().StringVarP(&output, "output", "o", "", "File to write the export to, instead of standard output.")
+
+	auditCmd.AddCommand(exportCmd)
+}
+
+// openAuditLog loads the configuration file at the given path, opens the
+// database it points to, and decodes the audit log key.
+func openAuditLog(configPath string, logger *zap.Logger) (*database.DB, []byte, error) {
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(cfg.Audit.Key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode audit key: %w", err)
+	}
+
+	db, err := database.Open(logger, cfg.Database.DSN)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	return db, key, nil
+}
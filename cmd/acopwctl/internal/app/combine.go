@@ -0,0 +1,70 @@
+package app
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/secmem"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/shamir"
+	"github.com/spf13/cobra"
+)
+
+func addCombineCommand(rootCmd *cobra.Command) {
+	combineCmd := &cobra.Command{
+		Use:   "combine [share...]",
+		Short: "Recover a secret split with Shamir's Secret Sharing.",
+		Long: "Recover a secret split with Shamir's Secret Sharing from at least as many shares as its threshold. " +
+			"Shares are read from the arguments or, if none are given, from standard input, one per line. " +
+			"The secret is checked for integrity before it is printed.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			encoded := args
+
+			if len(encoded) == 0 {
+				scanner := bufio.NewScanner(cmd.InOrStdin())
+				scanner.Buffer(nil, 1<<20)
+
+				for scanner.Scan() {
+					if line := strings.TrimSpace(scanner.Text()); line != "" {
+						encoded = append(encoded, line)
+					}
+				}
+
+				if err := scanner.Err(); err != nil {
+					return fmt.Errorf("failed to read shares: %w", err)
+				}
+			}
+
+			shares := make([]*shamir.Share, 0, len(encoded))
+
+			defer func() {
+				for _, share := range shares {
+					secmem.Zero(share.Value)
+				}
+			}()
+
+			for i, s := range encoded {
+				share, err := shamir.ParseShare([]byte(s))
+				if err != nil {
+					return fmt.Errorf("failed to parse share %d: %w", i+1, err)
+				}
+
+				shares = append(shares, share)
+			}
+
+			secret, err := shamir.Combine(shares)
+			if err != nil {
+				return fmt.Errorf("failed to combine shares: %w", err)
+			}
+			defer secmem.Zero(secret)
+
+			if _, err := cmd.OutOrStdout().Write(secret); err != nil {
+				return fmt.Errorf("failed to write secret: %w", err)
+			}
+
+			return nil
+		},
+	}
+
+	rootCmd.AddCommand(combineCmd)
+}
@@ -0,0 +1,199 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/config"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/database"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/endpoint"
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+// ErrInvalidScope is returned when an API key is created with an unknown
+// scope.
+const ErrInvalidScope xerrors.Error = "invalid scope"
+
+func addKeyCommand(rootCmd *cobra.Command, logger *zap.Logger) {
+	var configPath string
+
+	keyCmd := &cobra.Command{
+		Use:   "key",
+		Short: "Manage API keys.",
+	}
+
+	keyCmd.PersistentFlags().StringVarP(&configPath, "config", "c", "config.json", "Path to the configuration file.")
+
+	addKeyCreateCommand(keyCmd, &configPath, logger)
+	addKeyListCommand(keyCmd, &configPath, logger)
+	addKeyRevokeCommand(keyCmd, &configPath, logger)
+
+	rootCmd.AddCommand(keyCmd)
+}
+
+func addKeyCreateCommand(keyCmd *cobra.Command, configPath *string, logger *zap.Logger) {
+	var (
+		owner      string
+		scopes     []string
+		dailyQuota int64
+		expiresIn  time.Duration
+	)
+
+	createCmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create an API key.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, scope := range scopes {
+				if !validScope(scope) {
+					return fmt.Errorf("%w: %s", ErrInvalidScope, scope)
+				}
+			}
+
+			db, err := openDatabase(*configPath, logger)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			var expiresAt time.Time
+
+			if expiresIn > 0 {
+				expiresAt = time.Now().Add(expiresIn)
+			}
+
+			key, apiKey, err := db.CreateAPIKey(owner, scopes, dailyQuota, expiresAt)
+			if err != nil {
+				return fmt.Errorf("failed to create API key: %w", err)
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "Key ID: %s\nKey:    %s\n\nStore the key now; it cannot be retrieved later.\n", apiKey.KeyID, key)
+
+			return nil
+		},
+	}
+
+	createCmd.Flags().StringVarP(&owner, "owner", "o", "", "Person or team the key is issued to.")
+	createCmd.Flags().StringSliceVarP(&scopes, "scope", "s", []string{database.ScopeAll}, "Generators the key grants access to, or * for all of them.")
+	createCmd.Flags().Int64VarP(&dailyQuota, "quota", "q", 0, "Number of requests allowed per UTC day, or 0 for no limit.")
+	createCmd.Flags().DurationVarP(&expiresIn, "expires-in", "e", 0, "Time until the key expires, or 0 for never.")
+
+	_ = createCmd.MarkFlagRequired("owner")
+
+	keyCmd.AddCommand(createCmd)
+}
+
+func addKeyListCommand(keyCmd *cobra.Command, configPath *string, logger *zap.Logger) {
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List API keys.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := openDatabase(*configPath, logger)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			keys, err := db.APIKeys()
+			if err != nil {
+				return fmt.Errorf("failed to list API keys: %w", err)
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+
+			fmt.Fprintln(w, "KEY ID\tOWNER\tSCOPES\tUSAGE\tEXPIRES\tSTATUS")
+
+			for _, apiKey := range keys {
+				usage, err := db.APIKeyUsage(apiKey)
+				if err != nil {
+					return fmt.Errorf("failed to list API keys: %w", err)
+				}
+
+				var (
+					quota   = "unlimited"
+					expires = "never"
+					status  = "active"
+				)
+
+				if apiKey.DailyQuota > 0 {
+					quota = fmt.Sprint(apiKey.DailyQuota)
+				}
+
+				if !apiKey.ExpiresAt.IsZero() {
+					expires = apiKey.ExpiresAt.Format(time.RFC3339)
+
+					if time.Now().After(apiKey.ExpiresAt) {
+						status = "expired"
+					}
+				}
+
+				if apiKey.Revoked {
+					status = "revoked"
+				}
+
+				fmt.Fprintf(w, "%s\t%s\t%s\t%d/%s\t%s\t%s\n", apiKey.KeyID, apiKey.Owner, strings.Join(apiKey.Scopes, ","), usage, quota, expires, status)
+			}
+
+			return w.Flush() //nolint:wrapcheck // error from the terminal
+		},
+	}
+
+	keyCmd.AddCommand(listCmd)
+}
+
+func addKeyRevokeCommand(keyCmd *cobra.Command, configPath *string, logger *zap.Logger) {
+	revokeCmd := &cobra.Command{
+		Use:   "revoke <key-id>",
+		Short: "Revoke an API key.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := openDatabase(*configPath, logger)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			if err := db.RevokeAPIKey(args[0]); err != nil {
+				return fmt.Errorf("failed to revoke API key: %w", err)
+			}
+
+			return nil
+		},
+	}
+
+	keyCmd.AddCommand(revokeCmd)
+}
+
+// openDatabase loads the configuration file at the given path and opens the
+// database it points to.
+func openDatabase(configPath string, logger *zap.Logger) (*database.DB, error) {
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	db, err := database.Open(logger, cfg.Database.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	return db, nil
+}
+
+// validScope reports whether the given scope can be granted to an API key.
+func validScope(scope string) bool {
+	if scope == database.ScopeAll {
+		return true
+	}
+
+	for _, s := range endpoint.Scopes() {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
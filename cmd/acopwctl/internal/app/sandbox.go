@@ -0,0 +1,48 @@
+package app
+
+import (
+	"path/filepath"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/config"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/database"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/sandbox"
+)
+
+// sandboxPolicy returns the sandbox policy of the server, which may only read
+// its configuration, TLS files, and IP filter rules, and only write to the
+// database directory, the PID file, and the access log.
+func sandboxPolicy(cfg *config.Config, configPath string) (*sandbox.Policy, error) {
+	credentials, err := sandbox.LookupCredentials(cfg.Sandbox.User, cfg.Sandbox.Group)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // the error is already descriptive
+	}
+
+	policy := &sandbox.Policy{
+		Credentials: credentials,
+		ReadOnly: []string{
+			configPath,
+			cfg.Server.TLS.Certificate,
+			cfg.Server.TLS.Key,
+			"/etc/localtime",
+		},
+		ReadWrite: []string{
+			cfg.Server.PID,
+		},
+	}
+
+	for _, path := range []string{cfg.Server.TLS.ClientCA, cfg.Server.TLS.CRL, cfg.IPFilter.File} {
+		if path != "" {
+			policy.ReadOnly = append(policy.ReadOnly, path)
+		}
+	}
+
+	if path := database.Path(cfg.Database.DSN); path != "" {
+		policy.ReadWrite = append(policy.ReadWrite, filepath.Dir(path))
+	}
+
+	if cfg.AccessLog.Path != "" {
+		policy.ReadWrite = append(policy.ReadWrite, cfg.AccessLog.Path)
+	}
+
+	return policy, nil
+}
@@ -0,0 +1,123 @@
+package app
+
+import (
+	"encoding/base64"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func addSigningKeyCommand(rootCmd *cobra.Command, logger *zap.Logger) {
+	var configPath string
+
+	signingKeyCmd := &cobra.Command{
+		Use:   "signing-key",
+		Short: "Manage request signing keys.",
+	}
+
+	signingKeyCmd.PersistentFlags().StringVarP(&configPath, "config", "c", "config.json", "Path to the configuration file.")
+
+	addSigningKeyCreateCommand(signingKeyCmd, &configPath, logger)
+	addSigningKeyListCommand(signingKeyCmd, &configPath, logger)
+	addSigningKeyRevokeCommand(signingKeyCmd, &configPath, logger)
+
+	rootCmd.AddCommand(signingKeyCmd)
+}
+
+func addSigningKeyCreateCommand(signingKeyCmd *cobra.Command, configPath *string, logger *zap.Logger) {
+	var owner string
+
+	createCmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create a signing key.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := openDatabase(*configPath, logger)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			signingKey, err := db.CreateSigningKey(owner)
+			if err != nil {
+				return fmt.Errorf("failed to create signing key: %w", err)
+			}
+
+			fmt.Fprintf(
+				cmd.OutOrStdout(),
+				"Key ID: %s\nSecret: %s\n\nShare the secret with its owner over a secure channel.\n",
+				signingKey.KeyID,
+				base64.StdEncoding.EncodeToString(signingKey.Secret),
+			)
+
+			return nil
+		},
+	}
+
+	createCmd.Flags().StringVarP(&owner, "owner", "o", "", "Person or service the key is issued to.")
+
+	_ = createCmd.MarkFlagRequired("owner")
+
+	signingKeyCmd.AddCommand(createCmd)
+}
+
+func addSigningKeyListCommand(signingKeyCmd *cobra.Command, configPath *string, logger *zap.Logger) {
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List signing keys.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := openDatabase(*configPath, logger)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			keys, err := db.SigningKeys()
+			if err != nil {
+				return fmt.Errorf("failed to list signing keys: %w", err)
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+
+			fmt.Fprintln(w, "KEY ID\tOWNER\tCREATED\tSTATUS")
+
+			for _, signingKey := range keys {
+				status := "active"
+				if signingKey.Revoked {
+					status = "revoked"
+				}
+
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", signingKey.KeyID, signingKey.Owner, signingKey.CreatedAt.Format(time.RFC3339), status)
+			}
+
+			return w.Flush() //nolint:wrapcheck // error from the terminal
+		},
+	}
+
+	signingKeyCmd.AddCommand(listCmd)
+}
+
+func addSigningKeyRevokeCommand(signingKeyCmd *cobra.Command, configPath *string, logger *zap.Logger) {
+	revokeCmd := &cobra.Command{
+		Use:   "revoke <key-id>",
+		Short: "Revoke a signing key.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := openDatabase(*configPath, logger)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			if err := db.RevokeSigningKey(args[0]); err != nil {
+				return fmt.Errorf("failed to revoke signing key: %w", err)
+			}
+
+			return nil
+		},
+	}
+
+	signingKeyCmd.AddCommand(revokeCmd)
+}
@@ -3,20 +3,24 @@ module git.sr.ht/~jamesponddotco/acciopassword
 go 1.20
 
 require (
+	filippo.io/age v1.1.1
 	git.sr.ht/~jamesponddotco/acopw-go v0.1.0
 	git.sr.ht/~jamesponddotco/xstd-go v0.0.0-20230602124145-693a263541a3
+	github.com/ProtonMail/go-crypto v0.0.0-20230426101702-58e86b294756
 	github.com/mattn/go-sqlite3 v1.14.17
 	github.com/spf13/cobra v1.7.0
 	go.uber.org/zap v1.24.0
+	golang.org/x/crypto v0.8.0
+	golang.org/x/sys v0.7.0
+	golang.org/x/text v0.13.0
 )
 
 require (
+	github.com/cloudflare/circl v1.3.3 // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
 	github.com/stretchr/testify v1.8.1 // indirect
 	go.uber.org/atomic v1.7.0 // indirect
 	go.uber.org/multierr v1.6.0 // indirect
-	golang.org/x/crypto v0.8.0 // indirect
-	golang.org/x/sys v0.7.0 // indirect
 )
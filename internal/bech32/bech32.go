@@ -0,0 +1,226 @@
+// Package bech32 implements the Bech32 encoding described in BIP 173, as used
+// by age for its identities and recipients.
+//
+// Unlike BIP 173, the package does not enforce the 90 character limit on the
+// length of encoded strings, since age identities and recipients do not follow
+// it.
+package bech32
+
+import (
+	"fmt"
+	"strings"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+)
+
+const (
+	// ErrInvalidHRP is returned when the human-readable part of a string is
+	// empty or contains invalid characters.
+	ErrInvalidHRP xerrors.Error = "invalid human-readable part"
+
+	// ErrMixedCase is returned when a string mixes uppercase and lowercase
+	// characters.
+	ErrMixedCase xerrors.Error = "mixed case string"
+
+	// ErrMissingSeparator is returned when a string does not contain the "1"
+	// separator or has no room for a checksum after it.
+	ErrMissingSeparator xerrors.Error = "missing separator or checksum"
+
+	// ErrInvalidCharacter is returned when the data part of a string contains
+	// a character outside the Bech32 alphabet.
+	ErrInvalidCharacter xerrors.Error = "invalid data character"
+
+	// ErrInvalidChecksum is returned when the checksum of a string does not
+	// match its contents.
+	ErrInvalidChecksum xerrors.Error = "invalid checksum"
+
+	// ErrInvalidPadding is returned when converting between bit groups leaves
+	// non-zero padding behind.
+	ErrInvalidPadding xerrors.Error = "invalid padding"
+)
+
+// charset is the Bech32 alphabet.
+const charset string = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// generator holds the constants of the BCH code used for the checksum.
+var generator = [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3} //nolint:gochecknoglobals // arrays cannot be constants
+
+// Encode encodes data as a Bech32 string with the given human-readable part.
+// The output uses the same case as hrp.
+func Encode(hrp string, data []byte) (string, error) {
+	if hrp == "" {
+		return "", ErrInvalidHRP
+	}
+
+	upper := strings.ToUpper(hrp) == hrp
+	if strings.ToLower(hrp) != hrp && !upper {
+		return "", ErrMixedCase
+	}
+
+	for _, c := range hrp {
+		if c < 33 || c > 126 {
+			return "", ErrInvalidHRP
+		}
+	}
+
+	lowerHRP := strings.ToLower(hrp)
+
+	values, err := convertBits(data, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+
+	checksum := createChecksum(lowerHRP, values)
+
+	var builder strings.Builder
+
+	builder.Grow(len(hrp) + 1 + len(values) + len(checksum))
+	builder.WriteString(lowerHRP)
+	builder.WriteByte('1')
+
+	for _, v := range values {
+		builder.WriteByte(charset[v])
+	}
+
+	for _, v := range checksum {
+		builder.WriteByte(charset[v])
+	}
+
+	if upper {
+		return strings.ToUpper(builder.String()), nil
+	}
+
+	return builder.String(), nil
+}
+
+// Decode decodes a Bech32 string, returning its lowercase human-readable part
+// and the data it encodes.
+func Decode(s string) (hrp string, data []byte, err error) {
+	if strings.ToLower(s) != s && strings.ToUpper(s) != s {
+		return "", nil, ErrMixedCase
+	}
+
+	s = strings.ToLower(s)
+
+	pos := strings.LastIndexByte(s, '1')
+	if pos < 1 || pos+7 > len(s) {
+		return "", nil, ErrMissingSeparator
+	}
+
+	hrp = s[:pos]
+
+	for _, c := range hrp {
+		if c < 33 || c > 126 {
+			return "", nil, ErrInvalidHRP
+		}
+	}
+
+	values := make([]byte, 0, len(s)-pos-1)
+
+	for _, c := range s[pos+1:] {
+		idx := strings.IndexRune(charset, c)
+		if idx < 0 {
+			return "", nil, fmt.Errorf("%w: %q", ErrInvalidCharacter, c)
+		}
+
+		values = append(values, byte(idx))
+	}
+
+	if polymod(append(expandHRP(hrp), values...)) != 1 {
+		return "", nil, ErrInvalidChecksum
+	}
+
+	data, err = convertBits(values[:len(values)-6], 5, 8, false)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return hrp, data, nil
+}
+
+// polymod computes the Bech32 checksum over the given values.
+func polymod(values []byte) uint32 {
+	chk := uint32(1)
+
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+
+		for i := 0; i < 5; i++ {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= generator[i]
+			}
+		}
+	}
+
+	return chk
+}
+
+// expandHRP expands the human-readable part for use in checksum computation.
+func expandHRP(hrp string) []byte {
+	expanded := make([]byte, 0, len(hrp)*2+1)
+
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]>>5)
+	}
+
+	expanded = append(expanded, 0)
+
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]&31)
+	}
+
+	return expanded
+}
+
+// createChecksum returns the six checksum values for the given data.
+func createChecksum(hrp string, data []byte) []byte {
+	values := make([]byte, 0, len(hrp)*2+1+len(data)+6)
+	values = append(values, expandHRP(hrp)...)
+	values = append(values, data...)
+	values = append(values, 0, 0, 0, 0, 0, 0)
+
+	mod := polymod(values) ^ 1
+	checksum := make([]byte, 6)
+
+	for i := range checksum {
+		checksum[i] = byte(mod>>uint(5*(5-i))) & 31
+	}
+
+	return checksum
+}
+
+// convertBits regroups data from groups of fromBits bits into groups of
+// toBits bits.
+func convertBits(data []byte, fromBits, toBits uint, pad bool) ([]byte, error) {
+	var (
+		acc    uint32
+		bits   uint
+		maxv   = uint32(1)<<toBits - 1
+		output = make([]byte, 0, len(data)*int(fromBits)/int(toBits)+1)
+	)
+
+	for _, value := range data {
+		if uint32(value)>>fromBits != 0 {
+			return nil, ErrInvalidCharacter
+		}
+
+		acc = acc<<fromBits | uint32(value)
+		bits += fromBits
+
+		for bits >= toBits {
+			bits -= toBits
+			output = append(output, byte(acc>>bits&maxv))
+		}
+	}
+
+	if pad {
+		if bits > 0 {
+			output = append(output, byte(acc<<(toBits-bits)&maxv))
+		}
+	} else if bits >= fromBits || acc<<(toBits-bits)&maxv != 0 {
+		return nil, ErrInvalidPadding
+	}
+
+	return output, nil
+}
@@ -13,12 +13,24 @@ type ErrorResponse struct {
 	// Message is a human-readable message describing the error.
 	Message string `json:"message"`
 
+	// RequestID is the ID of the request that failed, to quote when reporting
+	// the error.
+	RequestID string `json:"requestId,omitempty"`
+
 	// Code is a machine-readable code describing the error.
 	Code uint `json:"code"`
 }
 
-// JSON sends an ErrorResponse to the HTTP response writer as JSON.
+// RequestIDHeader is the header carrying the ID of a request.
+const RequestIDHeader string = "X-Request-ID"
+
+// JSON sends an ErrorResponse to the HTTP response writer as JSON. The request
+// ID is taken from the response headers if not set.
 func JSON(w http.ResponseWriter, logger *zap.Logger, response ErrorResponse) {
+	if response.RequestID == "" {
+		response.RequestID = w.Header().Get(RequestIDHeader)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(int(response.Code))
 
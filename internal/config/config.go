@@ -2,12 +2,18 @@
 package config
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"net/url"
 	"os"
+	"runtime"
+	"strings"
 
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/endpoint"
 	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
 )
 
 const (
@@ -28,6 +34,211 @@ const (
 	// DefaultMinTLSVersion is the default minimum TLS version supported by the
 	// server.
 	DefaultMinTLSVersion string = "TLS13"
+
+	// DefaultClientAuth is the default client certificate verify mode.
+	DefaultClientAuth string = "off"
+
+	// DefaultClientIdentity is the default part of a client certificate used
+	// as the client's identity.
+	DefaultClientIdentity string = "subject"
+)
+
+const (
+	// DefaultRateLimitRequests is the default number of requests a client
+	// regains every rate limit period.
+	DefaultRateLimitRequests int = 60
+
+	// DefaultRateLimitPeriod is the default rate limit period in seconds.
+	DefaultRateLimitPeriod int = 60
+
+	// DefaultRateLimitBurst is the default number of requests a client can
+	// make in quick succession.
+	DefaultRateLimitBurst int = 30
+
+	// DefaultRateLimitIdleTimeout is the default number of seconds after which
+	// the state of an idle client is discarded.
+	DefaultRateLimitIdleTimeout int = 600
+
+	// MinAuditKeySize is the minimum size in bytes of the audit log key.
+	MinAuditKeySize int = 32
+
+	// MinUniquenessKeySize is the minimum size in bytes of the key
+	// fingerprinting issued secrets.
+	MinUniquenessKeySize int = 32
+
+	// DefaultAuthFailureRequests is the default number of failed
+	// authentication attempts an IP address regains every period.
+	DefaultAuthFailureRequests int = 10
+
+	// DefaultAuthFailurePeriod is the default failed authentication period in
+	// seconds.
+	DefaultAuthFailurePeriod int = 60
+
+	// DefaultAuthFailureBurst is the default number of failed authentication
+	// attempts an IP address can make in quick succession.
+	DefaultAuthFailureBurst int = 10
+
+	// DefaultCacheControl is the default Cache-Control header, which keeps
+	// generated secrets out of every cache.
+	DefaultCacheControl string = "no-store"
+
+	// DefaultHealthCacheControl is the default Cache-Control header of the
+	// health and ping endpoints, which may be cached but must be revalidated.
+	DefaultHealthCacheControl string = "no-cache"
+
+	// DefaultPragma is the default Pragma header, for HTTP/1.0 caches.
+	DefaultPragma string = "no-cache"
+
+	// DefaultStrictTransportSecurity is the default Strict-Transport-Security
+	// header.
+	DefaultStrictTransportSecurity string = "max-age=63072000; includeSubDomains"
+
+	// DefaultContentTypeOptions is the default X-Content-Type-Options header.
+	DefaultContentTypeOptions string = "nosniff"
+
+	// DefaultReferrerPolicy is the default Referrer-Policy header.
+	DefaultReferrerPolicy string = "no-referrer"
+
+	// DefaultContentSecurityPolicy is the default Content-Security-Policy
+	// header. The API serves no documents, so nothing may be loaded or framed.
+	DefaultContentSecurityPolicy string = "default-src 'none'; frame-ancestors 'none'; base-uri 'none'; form-action 'none'"
+
+	// DefaultPermissionsPolicy is the default Permissions-Policy header.
+	DefaultPermissionsPolicy string = "accelerometer=(), camera=(), geolocation=(), gyroscope=(), magnetometer=(), microphone=(), payment=(), usb=()"
+
+	// DefaultCORSMaxAge is the default number of seconds browsers may cache
+	// a CORS preflight response.
+	DefaultCORSMaxAge int = 600
+
+	// DefaultAccessLogFormat is the default format of the access log.
+	DefaultAccessLogFormat string = "json"
+
+	// DefaultProofOfWorkDifficulty is the default number of leading zero bits
+	// required by proof-of-work challenges under normal load.
+	DefaultProofOfWorkDifficulty int = 18
+
+	// DefaultProofOfWorkMaxDifficulty is the default highest number of leading
+	// zero bits required under load.
+	DefaultProofOfWorkMaxDifficulty int = 24
+
+	// DefaultProofOfWorkTTL is the default number of seconds a challenge is
+	// valid for.
+	DefaultProofOfWorkTTL int = 300
+
+	// DefaultProofOfWorkWindow is the default number of seconds over which
+	// request rates are measured.
+	DefaultProofOfWorkWindow int = 60
+
+	// DefaultProofOfWorkThreshold is the default number of anonymous requests
+	// per window above which the difficulty is raised.
+	DefaultProofOfWorkThreshold int = 600
+
+	// DefaultSigningClockSkew is the default number of seconds a request
+	// signature may be off from the server's clock.
+	DefaultSigningClockSkew int = 300
+
+	// DefaultSigningMaxBodySize is the default maximum size in bytes of the
+	// body of a signed request.
+	DefaultSigningMaxBodySize int64 = 1 << 20
+
+	// DisabledHeader is the value that turns a security header off.
+	DisabledHeader string = "-"
+
+	// DefaultIPFilterReloadInterval is the default number of seconds between
+	// checks for changes to the IP filter file.
+	DefaultIPFilterReloadInterval int = 30
+
+	// DefaultShareTTL is the default number of seconds a shared secret can be
+	// read for.
+	DefaultShareTTL int = 86400
+
+	// DefaultShareMaxTTL is the default highest number of seconds clients may
+	// ask a shared secret to be kept for.
+	DefaultShareMaxTTL int = 604800
+
+	// DefaultShareSweepInterval is the default number of seconds between
+	// purges of expired shared secrets.
+	DefaultShareSweepInterval int = 60
+
+	// DefaultShareMaxSize is the default maximum size in bytes of a shared
+	// secret.
+	DefaultShareMaxSize int64 = 64 << 10
+
+	// DefaultUniquenessRetention is the default number of seconds the
+	// fingerprints of issued secrets are kept for.
+	DefaultUniquenessRetention int = 31536000
+
+	// DefaultUniquenessSweepInterval is the default number of seconds between
+	// purges of fingerprints older than the retention window.
+	DefaultUniquenessSweepInterval int = 3600
+
+	// DefaultUniquenessMaxAttempts is the default number of times a secret is
+	// generated before giving up on finding one that was never issued.
+	DefaultUniquenessMaxAttempts int = 3
+
+	// DefaultUniquenessMinEntropy is the default number of bits of entropy
+	// below which secrets are not checked for uniqueness.
+	DefaultUniquenessMinEntropy int = 40
+
+	// DefaultRNGHealthSampleInterval is the default number of seconds between
+	// samples of the randomness source.
+	DefaultRNGHealthSampleInterval int = 60
+
+	// DefaultRNGHealthSampleSize is the default size in bytes of every sample
+	// of the randomness source.
+	DefaultRNGHealthSampleSize int = 4096
+)
+
+const (
+	// DefaultMaxBcryptCost is the default maximum bcrypt cost clients may
+	// request.
+	DefaultMaxBcryptCost int = 14
+
+	// DefaultMaxArgon2Time is the default maximum number of Argon2id passes
+	// clients may request.
+	DefaultMaxArgon2Time uint32 = 10
+
+	// DefaultMaxArgon2Memory is the default maximum amount of Argon2id memory,
+	// in KiB, clients may request.
+	DefaultMaxArgon2Memory uint32 = 64 * 1024
+
+	// DefaultMaxArgon2Threads is the default maximum Argon2id degree of
+	// parallelism clients may request.
+	DefaultMaxArgon2Threads uint8 = 4
+
+	// DefaultMaxScryptLogN is the default maximum base 2 logarithm of the
+	// scrypt cost clients may request.
+	DefaultMaxScryptLogN int = 16
+
+	// DefaultMaxScryptR is the default maximum scrypt block size clients may
+	// request. Together with DefaultMaxScryptLogN, it keeps a single scrypt
+	// hash under 64 MiB of memory.
+	DefaultMaxScryptR int = 8
+
+	// DefaultMaxScryptP is the default maximum scrypt parallelization clients
+	// may request.
+	DefaultMaxScryptP int = 4
+
+	// DefaultMaxPBKDF2Iterations is the default maximum number of PBKDF2
+	// iterations clients may request.
+	DefaultMaxPBKDF2Iterations int = 2000000
+
+	// DefaultMaxSHACryptRounds is the default maximum number of SHA-512 crypt
+	// rounds clients may request.
+	DefaultMaxSHACryptRounds int = 1000000
+
+	// DefaultMaxSCRAMIterations is the default maximum number of SCRAM-SHA-256
+	// iterations clients may request.
+	DefaultMaxSCRAMIterations int = 100000
+
+	// DefaultMaxMySQLIterations is the default maximum number of
+	// caching_sha2_password iterations, in thousands of rounds, clients may
+	// request.
+	DefaultMaxMySQLIterations int = 50
+
+	// DefaultMaxHashCost is the default maximum cost of the hashes a single
+	// request may ask for, in the units of passhash.Params.Cost.
+	DefaultMaxHashCost int = 3000
 )
 
 // TLS represents the TLS configuration.
@@ -40,6 +251,22 @@ type TLS struct {
 
 	// Version is the TLS version to use.
 	Version string `json:"version"`
+
+	// ClientCA is the path to the PEM bundle of CA certificates used to verify
+	// client certificates.
+	ClientCA string `json:"clientCA"`
+
+	// ClientAuth controls whether clients must present a certificate. It is
+	// one of off, optional, or required.
+	ClientAuth string `json:"clientAuth"`
+
+	// ClientIdentity is the part of a client certificate used as the client's
+	// identity in logs, metrics, and rate limits. It is either subject or san.
+	ClientIdentity string `json:"clientIdentity"`
+
+	// CRL is the path to a certificate revocation list, in PEM or DER format,
+	// signed by one of the client CAs. The file is reloaded when it changes.
+	CRL string `json:"crl"`
 }
 
 // Server represents the server configuration.
@@ -47,6 +274,9 @@ type Server struct {
 	// TLS is the TLS configuration.
 	TLS *TLS `json:"tls"`
 
+	// Proxy is the reverse proxy configuration.
+	Proxy *Proxy `json:"proxy"`
+
 	// Address is the address of the application.
 	Address string `json:"address"`
 
@@ -54,12 +284,566 @@ type Server struct {
 	PID string `json:"pid"`
 }
 
+// Proxy represents the reverse proxy configuration.
+type Proxy struct {
+	// Trusted lists the CIDRs of the proxies allowed to set forwarding headers
+	// and send PROXY protocol headers.
+	Trusted []string `json:"trusted"`
+
+	// ProxyProtocol enables HAProxy PROXY protocol version 1 and 2 headers on
+	// connections from trusted proxies.
+	ProxyProtocol bool `json:"proxyProtocol"`
+}
+
 // Database represents the database configuration.
 type Database struct {
 	// DSN is the data source name.
 	DSN string `json:"dsn"`
 }
 
+// RateLimit represents a token bucket rate limit.
+type RateLimit struct {
+	// Requests is the number of requests a client regains every Period.
+	Requests int `json:"requests"`
+
+	// Period is the length of the refill period, in seconds.
+	Period int `json:"period"`
+
+	// Burst is the number of requests a client can make in quick succession.
+	Burst int `json:"burst"`
+
+	// Disabled turns rate limiting off.
+	Disabled bool `json:"disabled"`
+}
+
+// RateLimiting represents the rate limiting configuration.
+type RateLimiting struct {
+	// Default is the limit applied to endpoints without a limit of their own.
+	Default *RateLimit `json:"default"`
+
+	// Endpoints maps endpoint paths, such as /v1/random/, to their limit.
+	Endpoints map[string]*RateLimit `json:"endpoints"`
+
+	// IdleTimeout is the number of seconds after which the state of an idle
+	// client is discarded.
+	IdleTimeout int `json:"idleTimeout"`
+}
+
+// Limit returns the rate limit for the given endpoint.
+func (rl *RateLimiting) Limit(endpoint string) *RateLimit {
+	if limit, ok := rl.Endpoints[endpoint]; ok && limit != nil {
+		return limit
+	}
+
+	return rl.Default
+}
+
+// IPRule lists the networks allowed and denied access to an endpoint.
+type IPRule struct {
+	// Allow lists the CIDRs allowed access. An empty list allows every
+	// network not denied.
+	Allow []string `json:"allow"`
+
+	// Deny lists the CIDRs denied access. It takes precedence over Allow.
+	Deny []string `json:"deny"`
+}
+
+// IPFilter represents the IP allowlist and denylist configuration.
+type IPFilter struct {
+	// Default is the rule applied to endpoints without a rule of their own.
+	Default *IPRule `json:"default"`
+
+	// Endpoints maps endpoint paths, such as /v1/metrics/, to their rule.
+	Endpoints map[string]*IPRule `json:"endpoints"`
+
+	// File is the path to a JSON file holding the default and endpoints
+	// rules. When set, it replaces the rules above and is reloaded whenever
+	// it changes.
+	File string `json:"file"`
+
+	// ReloadInterval is the number of seconds between checks for changes to
+	// File.
+	ReloadInterval int `json:"reloadInterval"`
+}
+
+// Enabled reports whether any rule is configured.
+func (f *IPFilter) Enabled() bool {
+	return f.File != "" || f.Default != nil || len(f.Endpoints) > 0
+}
+
+// SecurityHeaders represents the security headers added to every response.
+// Set a header to "-" to leave it out.
+type SecurityHeaders struct {
+	// CacheControls maps endpoint paths to their Cache-Control header,
+	// overriding CacheControl.
+	CacheControls map[string]string `json:"cacheControls"`
+
+	// CacheControl is the Cache-Control header.
+	CacheControl string `json:"cacheControl"`
+
+	// Pragma is the Pragma header. It is only sent alongside Cache-Control
+	// headers that forbid caching or require revalidation.
+	Pragma string `json:"pragma"`
+
+	// StrictTransportSecurity is the Strict-Transport-Security header.
+	StrictTransportSecurity string `json:"strictTransportSecurity"`
+
+	// ContentTypeOptions is the X-Content-Type-Options header.
+	ContentTypeOptions string `json:"contentTypeOptions"`
+
+	// ReferrerPolicy is the Referrer-Policy header.
+	ReferrerPolicy string `json:"referrerPolicy"`
+
+	// ContentSecurityPolicy is the Content-Security-Policy header.
+	ContentSecurityPolicy string `json:"contentSecurityPolicy"`
+
+	// PermissionsPolicy is the Permissions-Policy header.
+	PermissionsPolicy string `json:"permissionsPolicy"`
+}
+
+// Headers returns the security headers for the given endpoint, keyed by header
+// name.
+func (sh *SecurityHeaders) Headers(path string) map[string]string {
+	cacheControl := sh.CacheControl
+	if value, ok := sh.CacheControls[path]; ok {
+		cacheControl = value
+	}
+
+	headers := map[string]string{
+		xhttp.CacheControl:            cacheControl,
+		xhttp.StrictTransportSecurity: sh.StrictTransportSecurity,
+		xhttp.XContentTypeOptions:     sh.ContentTypeOptions,
+		"Referrer-Policy":             sh.ReferrerPolicy,
+		"Content-Security-Policy":     sh.ContentSecurityPolicy,
+		"Permissions-Policy":          sh.PermissionsPolicy,
+	}
+
+	if strings.Contains(cacheControl, "no-store") || strings.Contains(cacheControl, "no-cache") {
+		headers[xhttp.Pragma] = sh.Pragma
+	}
+
+	for name, value := range headers {
+		if value == "" || value == DisabledHeader {
+			delete(headers, name)
+		}
+	}
+
+	return headers
+}
+
+// setDefaults sets the default value of every header left unset.
+func (sh *SecurityHeaders) setDefaults() {
+	if sh.CacheControl == "" {
+		sh.CacheControl = DefaultCacheControl
+	}
+
+	if sh.CacheControls == nil {
+		sh.CacheControls = make(map[string]string)
+	}
+
+	for _, path := range []string{endpoint.Health, endpoint.Ping} {
+		if _, ok := sh.CacheControls[path]; !ok {
+			sh.CacheControls[path] = DefaultHealthCacheControl
+		}
+	}
+
+	if sh.Pragma == "" {
+		sh.Pragma = DefaultPragma
+	}
+
+	if sh.StrictTransportSecurity == "" {
+		sh.StrictTransportSecurity = DefaultStrictTransportSecurity
+	}
+
+	if sh.ContentTypeOptions == "" {
+		sh.ContentTypeOptions = DefaultContentTypeOptions
+	}
+
+	if sh.ReferrerPolicy == "" {
+		sh.ReferrerPolicy = DefaultReferrerPolicy
+	}
+
+	if sh.ContentSecurityPolicy == "" {
+		sh.ContentSecurityPolicy = DefaultContentSecurityPolicy
+	}
+
+	if sh.PermissionsPolicy == "" {
+		sh.PermissionsPolicy = DefaultPermissionsPolicy
+	}
+}
+
+// CORSRule represents the cross-origin requests an endpoint accepts.
+type CORSRule struct {
+	// AllowedOrigins lists the origins allowed to make requests. An origin
+	// may contain a single *, such as https://*.example.com, and a lone *
+	// allows every origin.
+	AllowedOrigins []string `json:"allowedOrigins"`
+
+	// AllowedMethods lists the methods allowed in cross-origin requests.
+	AllowedMethods []string `json:"allowedMethods"`
+
+	// AllowedHeaders lists the request headers allowed in cross-origin
+	// requests. A lone * allows every header.
+	AllowedHeaders []string `json:"allowedHeaders"`
+
+	// ExposedHeaders lists the response headers scripts are allowed to read.
+	ExposedHeaders []string `json:"exposedHeaders"`
+
+	// MaxAge is the number of seconds browsers may cache a preflight
+	// response.
+	MaxAge int `json:"maxAge"`
+
+	// AllowCredentials allows requests with cookies or HTTP authentication.
+	// It cannot be combined with an origin of *.
+	AllowCredentials bool `json:"allowCredentials"`
+}
+
+// CORS represents the cross-origin resource sharing configuration.
+type CORS struct {
+	// Default is the rule applied to endpoints without a rule of their own.
+	Default *CORSRule `json:"default"`
+
+	// Endpoints maps endpoint paths, such as /v1/random/, to their rule.
+	// Options left unset are inherited from Default.
+	Endpoints map[string]*CORSRule `json:"endpoints"`
+}
+
+// Rule returns the CORS rule for the given endpoint.
+func (c *CORS) Rule(path string) *CORSRule {
+	rule, ok := c.Endpoints[path]
+	if !ok || rule == nil {
+		return c.Default
+	}
+
+	merged := *rule
+
+	if merged.AllowedOrigins == nil {
+		merged.AllowedOrigins = c.Default.AllowedOrigins
+	}
+
+	if merged.AllowedMethods == nil {
+		merged.AllowedMethods = c.Default.AllowedMethods
+	}
+
+	if merged.AllowedHeaders == nil {
+		merged.AllowedHeaders = c.Default.AllowedHeaders
+	}
+
+	if merged.ExposedHeaders == nil {
+		merged.ExposedHeaders = c.Default.ExposedHeaders
+	}
+
+	if merged.MaxAge == 0 {
+		merged.MaxAge = c.Default.MaxAge
+	}
+
+	return &merged
+}
+
+// setDefaults sets the default value of every option left unset.
+func (c *CORS) setDefaults() {
+	if c.Default == nil {
+		c.Default = &CORSRule{}
+	}
+
+	if c.Default.AllowedOrigins == nil {
+		c.Default.AllowedOrigins = []string{"*"}
+	}
+
+	if c.Default.AllowedMethods == nil {
+		c.Default.AllowedMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+	}
+
+	if c.Default.AllowedHeaders == nil {
+		c.Default.AllowedHeaders = []string{xhttp.Accept, xhttp.Authorization, xhttp.ContentType, "X-Proof-Of-Work", "X-Request-ID", "X-Share-Key"}
+	}
+
+	if c.Default.ExposedHeaders == nil {
+		c.Default.ExposedHeaders = []string{
+			"RateLimit-Limit",
+			"RateLimit-Remaining",
+			"RateLimit-Reset",
+			"Quota-Remaining",
+			xhttp.RetryAfter,
+			"Privacy-Policy",
+			"Terms-Of-Service",
+			"X-Request-ID",
+		}
+	}
+
+	if c.Default.MaxAge == 0 {
+		c.Default.MaxAge = DefaultCORSMaxAge
+	}
+}
+
+// ProofOfWork represents the proof-of-work gate configuration.
+type ProofOfWork struct {
+	// Secret is the base64-encoded key challenges are signed with. Instances
+	// behind the same load balancer must share it. A random secret is
+	// generated at startup if empty.
+	Secret string `json:"secret"`
+
+	// Difficulty is the number of leading zero bits required under normal
+	// load.
+	Difficulty int `json:"difficulty"`
+
+	// MaxDifficulty is the highest number of leading zero bits required under
+	// load.
+	MaxDifficulty int `json:"maxDifficulty"`
+
+	// TTL is the number of seconds a challenge is valid for.
+	TTL int `json:"ttl"`
+
+	// Window is the number of seconds over which request rates are measured.
+	Window int `json:"window"`
+
+	// Threshold is the number of anonymous requests per window above which
+	// the difficulty is raised by one bit every time the rate doubles.
+	Threshold int `json:"threshold"`
+
+	// Enabled turns the proof-of-work gate on for generator endpoints.
+	Enabled bool `json:"enabled"`
+}
+
+// setDefaults sets the default value of every option left unset.
+func (p *ProofOfWork) setDefaults() {
+	if p.Difficulty == 0 {
+		p.Difficulty = DefaultProofOfWorkDifficulty
+	}
+
+	if p.MaxDifficulty == 0 {
+		p.MaxDifficulty = DefaultProofOfWorkMaxDifficulty
+	}
+
+	if p.MaxDifficulty < p.Difficulty {
+		p.MaxDifficulty = p.Difficulty
+	}
+
+	if p.TTL == 0 {
+		p.TTL = DefaultProofOfWorkTTL
+	}
+
+	if p.Window == 0 {
+		p.Window = DefaultProofOfWorkWindow
+	}
+
+	if p.Threshold == 0 {
+		p.Threshold = DefaultProofOfWorkThreshold
+	}
+}
+
+// Authentication represents the API key authentication configuration.
+type Authentication struct {
+	// Required lists the endpoints, such as /v1/random/, that can only be
+	// accessed with an API key.
+	Required []string `json:"required"`
+
+	// Failures limits the rate of failed API key authentications per IP
+	// address. Once exhausted, requests carrying an API key are rejected
+	// without looking the key up.
+	Failures *RateLimit `json:"failures"`
+}
+
+// Requires reports whether the given endpoint can only be accessed with an API
+// key.
+func (a *Authentication) Requires(endpoint string) bool {
+	for _, required := range a.Required {
+		if required == endpoint {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AccessLog represents the access log configuration.
+type AccessLog struct {
+	// Format is the format of the access log, either json, to write entries
+	// through the application logger, or combined, for the Combined Log
+	// Format.
+	Format string `json:"format"`
+
+	// Path is the file the access log is appended to in the combined format.
+	// Entries go to standard output if empty.
+	Path string `json:"path"`
+
+	// Disabled turns the access log off.
+	Disabled bool `json:"disabled"`
+}
+
+// Signing represents the request signing configuration.
+type Signing struct {
+	// ClockSkew is the number of seconds a request signature may be off from
+	// the server's clock.
+	ClockSkew int `json:"clockSkew"`
+
+	// MaxBodySize is the maximum size in bytes of the body of a signed
+	// request.
+	MaxBodySize int64 `json:"maxBodySize"`
+}
+
+// Memory represents how the server protects generated secrets held in
+// memory.
+type Memory struct {
+	// LockMemory locks the buffers holding random passwords, PINs, derived
+	// passwords, and secrets read from request bodies into memory, so they
+	// are never written to swap. Secrets from the other generators are built
+	// as strings and are not covered. Locking may fail if RLIMIT_MEMLOCK is
+	// too low, in which case buffers are used unlocked.
+	LockMemory bool `json:"lockMemory"`
+
+	// DisableCoreDumps disables core dumps and marks the process as not
+	// dumpable at startup. Only supported on Linux.
+	DisableCoreDumps bool `json:"disableCoreDumps"`
+}
+
+// Audit represents the audit log configuration.
+type Audit struct {
+	// Key is the base64-encoded key of at least 32 bytes authenticating the
+	// hash chain of the audit log with HMAC-SHA256, so that the log cannot be
+	// rewritten without it. Required when the audit log is enabled.
+	Key string `json:"key"`
+
+	// Enabled records every generated secret in the audit log.
+	Enabled bool `json:"enabled"`
+}
+
+// Share represents the one-time secret sharing configuration.
+type Share struct {
+	// BaseURL is the URL share links are built on, such as
+	// https://example.com. Defaults to the scheme and host of the request,
+	// which should be set when the server runs behind a reverse proxy.
+	BaseURL string `json:"baseURL"`
+
+	// TTL is the number of seconds a shared secret can be read for, unless
+	// the client asks for less.
+	TTL int `json:"ttl"`
+
+	// MaxTTL is the highest number of seconds clients may ask a shared secret
+	// to be kept for.
+	MaxTTL int `json:"maxTTL"`
+
+	// SweepInterval is the number of seconds between purges of expired
+	// shared secrets.
+	SweepInterval int `json:"sweepInterval"`
+
+	// MaxSize is the maximum size in bytes of a shared secret.
+	MaxSize int64 `json:"maxSize"`
+
+	// Enabled turns on the one-time secret sharing endpoint.
+	Enabled bool `json:"enabled"`
+}
+
+// Uniqueness represents the configuration of the guarantee that no secret is
+// issued twice.
+type Uniqueness struct {
+	// Key is the base64-encoded key of at least 32 bytes fingerprinting
+	// issued secrets with HMAC-SHA256. Secrets themselves are never stored.
+	Key string `json:"key"`
+
+	// Retention is the number of seconds the fingerprints of issued secrets
+	// are kept for, during which the same secret is never issued again. A
+	// negative retention keeps them forever.
+	Retention int `json:"retention"`
+
+	// SweepInterval is the number of seconds between purges of fingerprints
+	// older than the retention window.
+	SweepInterval int `json:"sweepInterval"`
+
+	// MaxAttempts is the number of times a secret is generated before giving
+	// up on finding one that was never issued.
+	MaxAttempts int `json:"maxAttempts"`
+
+	// MinEntropy is the number of bits of entropy below which secrets, such
+	// as short PINs, are issued without being checked for uniqueness. There
+	// are too few of them to never issue one twice, and which ones were
+	// issued would tell clients what secrets others were given.
+	MinEntropy int `json:"minEntropy"`
+
+	// Enabled regenerates secrets that were issued before, instead of
+	// issuing them twice.
+	Enabled bool `json:"enabled"`
+}
+
+// RNGHealth represents the configuration of the health tests run on the
+// randomness source.
+type RNGHealth struct {
+	// SampleInterval is the number of seconds between samples of the
+	// randomness source.
+	SampleInterval int `json:"sampleInterval"`
+
+	// SampleSize is the size in bytes of every sample of the randomness
+	// source.
+	SampleSize int `json:"sampleSize"`
+
+	// FailClosed refuses to generate secrets once the randomness source fails
+	// its health tests, until the server is restarted, instead of only
+	// reporting it in the health check.
+	FailClosed bool `json:"failClosed"`
+}
+
+// Sandbox represents the sandboxing configuration of the server process.
+type Sandbox struct {
+	// User is the name or ID of the user the server switches to after
+	// binding its listener. The database and PID file must be writable by
+	// this user.
+	User string `json:"user"`
+
+	// Group is the name or ID of the group the server switches to after
+	// binding its listener. Defaults to the primary group of User.
+	Group string `json:"group"`
+
+	// Enabled restricts filesystem access with Landlock and system calls with
+	// seccomp once the server has initialized. Only supported on Linux.
+	Enabled bool `json:"enabled"`
+}
+
+// Hashing represents the limits on the cost of the hashes clients may request
+// alongside generated secrets.
+type Hashing struct {
+	// MaxBcryptCost is the maximum bcrypt cost.
+	MaxBcryptCost int `json:"maxBcryptCost"`
+
+	// MaxArgon2Time is the maximum number of Argon2id passes.
+	MaxArgon2Time uint32 `json:"maxArgon2Time"`
+
+	// MaxArgon2Memory is the maximum amount of Argon2id memory in KiB.
+	MaxArgon2Memory uint32 `json:"maxArgon2Memory"`
+
+	// MaxArgon2Threads is the maximum Argon2id degree of parallelism.
+	MaxArgon2Threads uint8 `json:"maxArgon2Threads"`
+
+	// MaxScryptLogN is the maximum base 2 logarithm of the scrypt cost.
+	MaxScryptLogN int `json:"maxScryptLogN"`
+
+	// MaxScryptR is the maximum scrypt block size.
+	MaxScryptR int `json:"maxScryptR"`
+
+	// MaxScryptP is the maximum scrypt parallelization.
+	MaxScryptP int `json:"maxScryptP"`
+
+	// MaxPBKDF2Iterations is the maximum number of PBKDF2 iterations.
+	MaxPBKDF2Iterations int `json:"maxPBKDF2Iterations"`
+
+	// MaxSHACryptRounds is the maximum number of SHA-512 crypt rounds.
+	MaxSHACryptRounds int `json:"maxSHACryptRounds"`
+
+	// MaxSCRAMIterations is the maximum number of SCRAM-SHA-256 iterations.
+	MaxSCRAMIterations int `json:"maxSCRAMIterations"`
+
+	// MaxMySQLIterations is the maximum number of caching_sha2_password
+	// iterations, in thousands of rounds.
+	MaxMySQLIterations int `json:"maxMySQLIterations"`
+
+	// MaxCost is the maximum total cost of the hashes a single request may ask
+	// for, across algorithms and secrets, roughly in milliseconds of CPU time.
+	MaxCost int `json:"maxCost"`
+
+	// MaxConcurrent is the maximum number of hashes computed at the same time
+	// across the server. Defaults to the number of CPUs.
+	MaxConcurrent int `json:"maxConcurrent"`
+}
+
 // Config represents the application configuration.
 type Config struct {
 	// Server is the server configuration.
@@ -68,6 +852,56 @@ type Config struct {
 	// Database is the database configuration.
 	Database *Database `json:"database"`
 
+	// RateLimiting is the rate limiting configuration.
+	RateLimiting *RateLimiting `json:"rateLimiting"`
+
+	// Authentication is the API key authentication configuration. Endpoints
+	// requiring an API key also accept signed requests.
+	Authentication *Authentication `json:"authentication"`
+
+	// AccessLog is the access log configuration.
+	AccessLog *AccessLog `json:"accessLog"`
+
+	// Signing is the request signing configuration.
+	Signing *Signing `json:"signing"`
+
+	// IPFilter is the IP allowlist and denylist configuration.
+	IPFilter *IPFilter `json:"ipFilter"`
+
+	// ProofOfWork is the proof-of-work gate configuration.
+	ProofOfWork *ProofOfWork `json:"proofOfWork"`
+
+	// CORS is the cross-origin resource sharing configuration.
+	CORS *CORS `json:"cors"`
+
+	// SecurityHeaders is the configuration of the security headers added to
+	// every response.
+	SecurityHeaders *SecurityHeaders `json:"securityHeaders"`
+
+	// Hashing is the configuration for hashes returned alongside generated
+	// secrets.
+	Hashing *Hashing `json:"hashing"`
+
+	// Memory is the configuration for the handling of secrets in memory.
+	Memory *Memory `json:"memory"`
+
+	// Sandbox is the sandboxing configuration of the server process.
+	Sandbox *Sandbox `json:"sandbox"`
+
+	// Audit is the audit log configuration.
+	Audit *Audit `json:"audit"`
+
+	// Share is the one-time secret sharing configuration.
+	Share *Share `json:"share"`
+
+	// Uniqueness is the configuration of the guarantee that no secret is
+	// issued twice.
+	Uniqueness *Uniqueness `json:"uniqueness"`
+
+	// RNGHealth is the configuration of the health tests run on the
+	// randomness source.
+	RNGHealth *RNGHealth `json:"rngHealth"`
+
 	// PrivacyPolicy is the link to the service's privacy policy.
 	PrivacyPolicy string `json:"privacyPolicy"`
 
@@ -104,6 +938,146 @@ func LoadConfig(path string) (*Config, error) {
 		cfg.Server.TLS.Version = DefaultMinTLSVersion
 	}
 
+	if cfg.Server.Proxy == nil {
+		cfg.Server.Proxy = &Proxy{}
+	}
+
+	if cfg.Server.TLS.ClientAuth == "" {
+		cfg.Server.TLS.ClientAuth = DefaultClientAuth
+	}
+
+	if cfg.Server.TLS.ClientIdentity == "" {
+		cfg.Server.TLS.ClientIdentity = DefaultClientIdentity
+	}
+
+	if cfg.RateLimiting == nil {
+		cfg.RateLimiting = &RateLimiting{}
+	}
+
+	cfg.RateLimiting.setDefaults()
+
+	if cfg.Authentication == nil {
+		cfg.Authentication = &Authentication{}
+	}
+
+	cfg.Authentication.setDefaults()
+
+	if cfg.ProofOfWork == nil {
+		cfg.ProofOfWork = &ProofOfWork{}
+	}
+
+	cfg.ProofOfWork.setDefaults()
+
+	if cfg.CORS == nil {
+		cfg.CORS = &CORS{}
+	}
+
+	cfg.CORS.setDefaults()
+
+	if cfg.SecurityHeaders == nil {
+		cfg.SecurityHeaders = &SecurityHeaders{}
+	}
+
+	cfg.SecurityHeaders.setDefaults()
+
+	if cfg.Memory == nil {
+		cfg.Memory = &Memory{}
+	}
+
+	if cfg.Sandbox == nil {
+		cfg.Sandbox = &Sandbox{}
+	}
+
+	if cfg.Audit == nil {
+		cfg.Audit = &Audit{}
+	}
+
+	if cfg.Share == nil {
+		cfg.Share = &Share{}
+	}
+
+	if cfg.Share.TTL == 0 {
+		cfg.Share.TTL = DefaultShareTTL
+	}
+
+	if cfg.Share.MaxTTL == 0 {
+		cfg.Share.MaxTTL = DefaultShareMaxTTL
+	}
+
+	if cfg.Share.SweepInterval == 0 {
+		cfg.Share.SweepInterval = DefaultShareSweepInterval
+	}
+
+	if cfg.Share.MaxSize == 0 {
+		cfg.Share.MaxSize = DefaultShareMaxSize
+	}
+
+	if cfg.Uniqueness == nil {
+		cfg.Uniqueness = &Uniqueness{}
+	}
+
+	if cfg.Uniqueness.Retention == 0 {
+		cfg.Uniqueness.Retention = DefaultUniquenessRetention
+	}
+
+	if cfg.Uniqueness.SweepInterval == 0 {
+		cfg.Uniqueness.SweepInterval = DefaultUniquenessSweepInterval
+	}
+
+	if cfg.Uniqueness.MaxAttempts == 0 {
+		cfg.Uniqueness.MaxAttempts = DefaultUniquenessMaxAttempts
+	}
+
+	if cfg.Uniqueness.MinEntropy == 0 {
+		cfg.Uniqueness.MinEntropy = DefaultUniquenessMinEntropy
+	}
+
+	if cfg.RNGHealth == nil {
+		cfg.RNGHealth = &RNGHealth{}
+	}
+
+	if cfg.RNGHealth.SampleInterval == 0 {
+		cfg.RNGHealth.SampleInterval = DefaultRNGHealthSampleInterval
+	}
+
+	if cfg.RNGHealth.SampleSize == 0 {
+		cfg.RNGHealth.SampleSize = DefaultRNGHealthSampleSize
+	}
+
+	if cfg.AccessLog == nil {
+		cfg.AccessLog = &AccessLog{}
+	}
+
+	if cfg.AccessLog.Format == "" {
+		cfg.AccessLog.Format = DefaultAccessLogFormat
+	}
+
+	if cfg.Signing == nil {
+		cfg.Signing = &Signing{}
+	}
+
+	if cfg.Signing.ClockSkew == 0 {
+		cfg.Signing.ClockSkew = DefaultSigningClockSkew
+	}
+
+	if cfg.Signing.MaxBodySize == 0 {
+		cfg.Signing.MaxBodySize = DefaultSigningMaxBodySize
+	}
+
+	if cfg.IPFilter == nil {
+		cfg.IPFilter = &IPFilter{}
+	}
+
+	if cfg.IPFilter.ReloadInterval == 0 {
+		cfg.IPFilter.ReloadInterval = DefaultIPFilterReloadInterval
+	}
+
+	if cfg.Hashing == nil {
+		cfg.Hashing = &Hashing{}
+	}
+
+	cfg.Hashing.setDefaults()
+
 	return cfg, nil
 }
 
@@ -125,6 +1099,10 @@ func (cfg *Config) Validate() error {
 		return fmt.Errorf("%w: missing TLS key", ErrInvalidConfigFile)
 	}
 
+	if cfg.Server.TLS.ClientAuth != DefaultClientAuth && cfg.Server.TLS.ClientCA == "" {
+		return fmt.Errorf("%w: missing client CA", ErrInvalidConfigFile)
+	}
+
 	if cfg.Database == nil {
 		return fmt.Errorf("%w: missing database configuration", ErrInvalidConfigFile)
 	}
@@ -149,5 +1127,356 @@ func (cfg *Config) Validate() error {
 		return fmt.Errorf("%w: invalid terms of service URL: %w", ErrInvalidConfigFile, err)
 	}
 
+	if cfg.RateLimiting != nil {
+		if err := cfg.RateLimiting.Default.validate("default"); err != nil {
+			return err
+		}
+
+		for endpoint, limit := range cfg.RateLimiting.Endpoints {
+			if err := limit.validate(endpoint); err != nil {
+				return err
+			}
+		}
+
+		if cfg.RateLimiting.IdleTimeout <= 0 {
+			return fmt.Errorf("%w: rate limiting idle timeout must be positive", ErrInvalidConfigFile)
+		}
+	}
+
+	if cfg.Authentication != nil && cfg.Authentication.Failures != nil {
+		if err := cfg.Authentication.Failures.validate("authentication failures"); err != nil {
+			return err
+		}
+	}
+
+	// Sections left unset are skipped by their validate methods.
+	validators := []func() error{
+		cfg.AccessLog.validate,
+		cfg.Audit.validate,
+		cfg.Signing.validate,
+		cfg.IPFilter.validate,
+		cfg.ProofOfWork.validate,
+		cfg.Hashing.validate,
+		cfg.Share.validate,
+		cfg.Uniqueness.validate,
+		cfg.RNGHealth.validate,
+	}
+
+	for _, validate := range validators {
+		if err := validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setDefaults sets the default value of every option left unset.
+func (rl *RateLimiting) setDefaults() {
+	if rl.Default == nil {
+		rl.Default = &RateLimit{}
+	}
+
+	if rl.Default.Requests == 0 {
+		rl.Default.Requests = DefaultRateLimitRequests
+	}
+
+	if rl.Default.Period == 0 {
+		rl.Default.Period = DefaultRateLimitPeriod
+	}
+
+	if rl.Default.Burst == 0 {
+		rl.Default.Burst = DefaultRateLimitBurst
+	}
+
+	for _, limit := range rl.Endpoints {
+		if limit == nil {
+			continue
+		}
+
+		if limit.Requests == 0 {
+			limit.Requests = rl.Default.Requests
+		}
+
+		if limit.Period == 0 {
+			limit.Period = rl.Default.Period
+		}
+
+		if limit.Burst == 0 {
+			limit.Burst = rl.Default.Burst
+		}
+	}
+
+	if rl.IdleTimeout == 0 {
+		rl.IdleTimeout = DefaultRateLimitIdleTimeout
+	}
+}
+
+// validate checks a rate limit for errors.
+func (limit *RateLimit) validate(name string) error {
+	if limit == nil {
+		return fmt.Errorf("%w: missing %s rate limit", ErrInvalidConfigFile, name)
+	}
+
+	if limit.Disabled {
+		return nil
+	}
+
+	if limit.Requests < 1 || limit.Period < 1 || limit.Burst < 1 {
+		return fmt.Errorf("%w: %s rate limit must have positive requests, period, and burst", ErrInvalidConfigFile, name)
+	}
+
+	return nil
+}
+
+// setDefaults sets the default value of every limit left unset.
+func (h *Hashing) setDefaults() {
+	if h.MaxBcryptCost == 0 {
+		h.MaxBcryptCost = DefaultMaxBcryptCost
+	}
+
+	if h.MaxArgon2Time == 0 {
+		h.MaxArgon2Time = DefaultMaxArgon2Time
+	}
+
+	if h.MaxArgon2Memory == 0 {
+		h.MaxArgon2Memory = DefaultMaxArgon2Memory
+	}
+
+	if h.MaxArgon2Threads == 0 {
+		h.MaxArgon2Threads = DefaultMaxArgon2Threads
+	}
+
+	if h.MaxScryptLogN == 0 {
+		h.MaxScryptLogN = DefaultMaxScryptLogN
+	}
+
+	if h.MaxScryptR == 0 {
+		h.MaxScryptR = DefaultMaxScryptR
+	}
+
+	if h.MaxScryptP == 0 {
+		h.MaxScryptP = DefaultMaxScryptP
+	}
+
+	if h.MaxPBKDF2Iterations == 0 {
+		h.MaxPBKDF2Iterations = DefaultMaxPBKDF2Iterations
+	}
+
+	if h.MaxSHACryptRounds == 0 {
+		h.MaxSHACryptRounds = DefaultMaxSHACryptRounds
+	}
+
+	if h.MaxSCRAMIterations == 0 {
+		h.MaxSCRAMIterations = DefaultMaxSCRAMIterations
+	}
+
+	if h.MaxMySQLIterations == 0 {
+		h.MaxMySQLIterations = DefaultMaxMySQLIterations
+	}
+
+	if h.MaxCost == 0 {
+		h.MaxCost = DefaultMaxHashCost
+	}
+
+	if h.MaxConcurrent == 0 {
+		h.MaxConcurrent = runtime.NumCPU()
+	}
+}
+
+// setDefaults sets the default limit on failed authentications if left unset.
+func (a *Authentication) setDefaults() {
+	if a.Failures == nil {
+		a.Failures = &RateLimit{}
+	}
+
+	if a.Failures.Requests == 0 {
+		a.Failures.Requests = DefaultAuthFailureRequests
+	}
+
+	if a.Failures.Period == 0 {
+		a.Failures.Period = DefaultAuthFailurePeriod
+	}
+
+	if a.Failures.Burst == 0 {
+		a.Failures.Burst = DefaultAuthFailureBurst
+	}
+}
+
+// validate checks that the access log format is supported.
+func (al *AccessLog) validate() error {
+	if al == nil || al.Disabled {
+		return nil
+	}
+
+	if al.Format != "json" && al.Format != "combined" {
+		return fmt.Errorf("%w: access log format must be json or combined", ErrInvalidConfigFile)
+	}
+
+	return nil
+}
+
+// validate checks that the audit log key is set and long enough when the audit
+// log is enabled.
+func (a *Audit) validate() error {
+	if a == nil || !a.Enabled {
+		return nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(a.Key)
+	if err != nil {
+		return fmt.Errorf("%w: invalid audit key: %w", ErrInvalidConfigFile, err)
+	}
+
+	if len(key) < MinAuditKeySize {
+		return fmt.Errorf("%w: audit key must be at least %d bytes", ErrInvalidConfigFile, MinAuditKeySize)
+	}
+
+	return nil
+}
+
+// validate checks that the signing limits are positive.
+func (s *Signing) validate() error {
+	if s == nil {
+		return nil
+	}
+
+	if s.ClockSkew <= 0 || s.MaxBodySize <= 0 {
+		return fmt.Errorf("%w: signing clock skew and maximum body size must be positive", ErrInvalidConfigFile)
+	}
+
+	return nil
+}
+
+// validate checks that the reload interval is positive.
+func (f *IPFilter) validate() error {
+	if f == nil {
+		return nil
+	}
+
+	if f.ReloadInterval <= 0 {
+		return fmt.Errorf("%w: IP filter reload interval must be positive", ErrInvalidConfigFile)
+	}
+
+	return nil
+}
+
+// validate checks that the difficulty and periods of the gate are positive
+// and that the secret, if any, is valid base64.
+func (p *ProofOfWork) validate() error {
+	if p == nil {
+		return nil
+	}
+
+	if p.Difficulty <= 0 || p.MaxDifficulty < p.Difficulty {
+		return fmt.Errorf("%w: proof-of-work difficulty must be positive and at most the maximum difficulty", ErrInvalidConfigFile)
+	}
+
+	if p.TTL <= 0 || p.Window <= 0 || p.Threshold <= 0 {
+		return fmt.Errorf("%w: proof-of-work TTL, window, and threshold must be positive", ErrInvalidConfigFile)
+	}
+
+	if _, err := base64.StdEncoding.DecodeString(p.Secret); err != nil {
+		return fmt.Errorf("%w: invalid proof-of-work secret: %w", ErrInvalidConfigFile, err)
+	}
+
+	return nil
+}
+
+// validate checks that every limit is positive.
+func (h *Hashing) validate() error {
+	if h == nil {
+		return nil
+	}
+
+	limits := []int{
+		h.MaxBcryptCost,
+		int(h.MaxArgon2Time),
+		int(h.MaxArgon2Memory),
+		int(h.MaxArgon2Threads),
+		h.MaxScryptLogN,
+		h.MaxScryptR,
+		h.MaxScryptP,
+		h.MaxPBKDF2Iterations,
+		h.MaxSHACryptRounds,
+		h.MaxSCRAMIterations,
+		h.MaxMySQLIterations,
+		h.MaxCost,
+		h.MaxConcurrent,
+	}
+
+	for _, limit := range limits {
+		if limit <= 0 {
+			return fmt.Errorf("%w: hashing limits must be positive", ErrInvalidConfigFile)
+		}
+	}
+
+	return nil
+}
+
+// validate checks that the lifetimes and sizes of shared secrets are positive,
+// and that the base URL, if any, is an absolute URL.
+func (s *Share) validate() error {
+	if s == nil {
+		return nil
+	}
+
+	if s.TTL <= 0 || s.MaxTTL < s.TTL {
+		return fmt.Errorf("%w: share TTL must be positive and at most the maximum TTL", ErrInvalidConfigFile)
+	}
+
+	if s.SweepInterval <= 0 || s.MaxSize <= 0 {
+		return fmt.Errorf("%w: share sweep interval and maximum size must be positive", ErrInvalidConfigFile)
+	}
+
+	if s.BaseURL != "" {
+		u, err := url.Parse(s.BaseURL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("%w: share base URL must be an absolute URL", ErrInvalidConfigFile)
+		}
+	}
+
+	return nil
+}
+
+// validate checks that the sweep interval, attempts, and minimum entropy are
+// positive, and that the fingerprint key is set and long enough when
+// uniqueness is enabled.
+func (u *Uniqueness) validate() error {
+	if u == nil {
+		return nil
+	}
+
+	if u.SweepInterval <= 0 || u.MaxAttempts <= 0 || u.MinEntropy <= 0 {
+		return fmt.Errorf("%w: uniqueness sweep interval, maximum attempts, and minimum entropy must be positive", ErrInvalidConfigFile)
+	}
+
+	if !u.Enabled {
+		return nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(u.Key)
+	if err != nil {
+		return fmt.Errorf("%w: invalid uniqueness key: %w", ErrInvalidConfigFile, err)
+	}
+
+	if len(key) < MinUniquenessKeySize {
+		return fmt.Errorf("%w: uniqueness key must be at least %d bytes", ErrInvalidConfigFile, MinUniquenessKeySize)
+	}
+
+	return nil
+}
+
+// validate checks that the randomness source is sampled at a positive
+// interval and size.
+func (h *RNGHealth) validate() error {
+	if h == nil {
+		return nil
+	}
+
+	if h.SampleInterval <= 0 || h.SampleSize <= 0 {
+		return fmt.Errorf("%w: RNG health sample interval and size must be positive", ErrInvalidConfigFile)
+	}
+
 	return nil
 }
@@ -0,0 +1,91 @@
+package config_test
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/config"
+)
+
+// minimalConfig is the smallest configuration that passes validation, with a
+// placeholder for the uniqueness key.
+const minimalConfig string = `{
+  "server": {
+    "address": "localhost:1997",
+    "tls": {
+      "certificate": "/path/to/certificate.pem",
+      "key": "/path/to/key.pem"
+    }
+  },
+  "database": {
+    "dsn": "/path/to/database.db"
+  },
+  "uniqueness": {
+    "enabled": true,
+    "key": "KEY"
+  },
+  "privacyPolicy": "https://example.com/privacy",
+  "termsOfService": "https://example.com/terms"
+}`
+
+func TestUniquenessKeySize(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		size    int
+		wantErr bool
+	}{
+		{
+			name:    "missing key",
+			size:    0,
+			wantErr: true,
+		},
+		{
+			name:    "short key",
+			size:    config.MinUniquenessKeySize - 1,
+			wantErr: true,
+		},
+		{
+			name:    "minimum key",
+			size:    config.MinUniquenessKeySize,
+			wantErr: false,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				key  = base64.StdEncoding.EncodeToString(make([]byte, tt.size))
+				path = filepath.Join(t.TempDir(), "config.json")
+			)
+
+			if err := os.WriteFile(path, []byte(strings.Replace(minimalConfig, "KEY", key, 1)), 0o600); err != nil {
+				t.Fatalf("failed to write config: %v", err)
+			}
+
+			cfg, err := config.LoadConfig(path)
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v", err)
+			}
+
+			err = cfg.Validate()
+
+			if tt.wantErr && !errors.Is(err, config.ErrInvalidConfigFile) {
+				t.Errorf("Validate() error = %v, want %v", err, config.ErrInvalidConfigFile)
+			}
+
+			if !tt.wantErr && err != nil {
+				t.Errorf("Validate() error = %v", err)
+			}
+		})
+	}
+}
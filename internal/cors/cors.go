@@ -0,0 +1,236 @@
+// Package cors implements cross-origin resource sharing policies as described
+// in the Fetch standard.
+package cors
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+)
+
+const (
+	// ErrInvalidOrigin is returned when an allowed origin pattern is
+	// malformed.
+	ErrInvalidOrigin xerrors.Error = "invalid allowed origin"
+
+	// ErrWildcardCredentials is returned when a policy allows credentials from
+	// any origin, which would let every website act on behalf of users.
+	ErrWildcardCredentials xerrors.Error = "credentials cannot be allowed from any origin"
+)
+
+// Wildcard allows any origin or request header.
+const Wildcard string = "*"
+
+// Rule describes which cross-origin requests an endpoint accepts.
+type Rule struct {
+	// AllowedOrigins lists the origins allowed to make requests, such as
+	// https://example.com. An origin may contain a single * standing for one
+	// or more host name characters, such as https://*.example.com, and a lone
+	// * allows every origin.
+	AllowedOrigins []string
+
+	// AllowedMethods lists the methods allowed in cross-origin requests.
+	AllowedMethods []string
+
+	// AllowedHeaders lists the request headers allowed in cross-origin
+	// requests. A lone * allows every header.
+	AllowedHeaders []string
+
+	// ExposedHeaders lists the response headers scripts are allowed to read.
+	ExposedHeaders []string
+
+	// MaxAge is the number of seconds browsers may cache a preflight
+	// response.
+	MaxAge int
+
+	// AllowCredentials allows requests with cookies or HTTP authentication.
+	AllowCredentials bool
+}
+
+// originPattern is an allowed origin with an optional wildcard.
+type originPattern struct {
+	prefix   string
+	suffix   string
+	wildcard bool
+}
+
+// match reports whether the origin matches the pattern.
+func (p originPattern) match(origin string) bool {
+	if !p.wildcard {
+		return origin == p.prefix
+	}
+
+	if len(origin) <= len(p.prefix)+len(p.suffix) || !strings.HasPrefix(origin, p.prefix) || !strings.HasSuffix(origin, p.suffix) {
+		return false
+	}
+
+	for _, r := range origin[len(p.prefix) : len(origin)-len(p.suffix)] {
+		if !isHostChar(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Policy is a compiled Rule.
+type Policy struct {
+	allowedMethods map[string]struct{}
+	allowedHeaders map[string]struct{}
+	methods        string
+	exposedHeaders string
+	origins        []originPattern
+	maxAge         int
+	anyOrigin      bool
+	anyHeader      bool
+	credentials    bool
+}
+
+// New returns a Policy enforcing the given rule.
+func New(rule *Rule) (*Policy, error) {
+	p := &Policy{
+		allowedMethods: make(map[string]struct{}, len(rule.AllowedMethods)),
+		allowedHeaders: make(map[string]struct{}, len(rule.AllowedHeaders)),
+		methods:        strings.Join(rule.AllowedMethods, ", "),
+		exposedHeaders: strings.Join(rule.ExposedHeaders, ", "),
+		maxAge:         rule.MaxAge,
+		credentials:    rule.AllowCredentials,
+	}
+
+	for _, origin := range rule.AllowedOrigins {
+		if origin == Wildcard {
+			p.anyOrigin = true
+
+			continue
+		}
+
+		origin = strings.ToLower(strings.TrimSuffix(origin, "/"))
+
+		switch strings.Count(origin, Wildcard) {
+		case 0:
+			p.origins = append(p.origins, originPattern{prefix: origin})
+		case 1:
+			prefix, suffix, _ := strings.Cut(origin, Wildcard)
+
+			if !strings.Contains(prefix, "://") {
+				return nil, fmt.Errorf("%w: %q", ErrInvalidOrigin, origin)
+			}
+
+			p.origins = append(p.origins, originPattern{prefix: prefix, suffix: suffix, wildcard: true})
+		default:
+			return nil, fmt.Errorf("%w: %q", ErrInvalidOrigin, origin)
+		}
+	}
+
+	if p.anyOrigin && p.credentials {
+		return nil, ErrWildcardCredentials
+	}
+
+	for _, method := range rule.AllowedMethods {
+		p.allowedMethods[strings.ToUpper(method)] = struct{}{}
+	}
+
+	for _, header := range rule.AllowedHeaders {
+		if header == Wildcard {
+			p.anyHeader = true
+
+			continue
+		}
+
+		p.allowedHeaders[http.CanonicalHeaderKey(header)] = struct{}{}
+	}
+
+	return p, nil
+}
+
+// AllowsOrigin reports whether the origin may make requests.
+func (p *Policy) AllowsOrigin(origin string) bool {
+	if p.anyOrigin {
+		return true
+	}
+
+	origin = strings.ToLower(origin)
+
+	for _, pattern := range p.origins {
+		if pattern.match(origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AllowsMethod reports whether the method may be used. Simple methods are
+// always allowed.
+func (p *Policy) AllowsMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost:
+		return true
+	}
+
+	_, ok := p.allowedMethods[method]
+
+	return ok
+}
+
+// AllowsHeaders reports whether every one of the given headers may be sent.
+func (p *Policy) AllowsHeaders(headers []string) bool {
+	if p.anyHeader {
+		return true
+	}
+
+	for _, header := range headers {
+		if _, ok := p.allowedHeaders[http.CanonicalHeaderKey(header)]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// AllowOrigin returns the value of the Access-Control-Allow-Origin header for
+// an allowed origin. Policies allowing every origin answer with *, others echo
+// the origin back.
+func (p *Policy) AllowOrigin(origin string) string {
+	if p.anyOrigin {
+		return Wildcard
+	}
+
+	return origin
+}
+
+// Methods returns the value of the Access-Control-Allow-Methods header.
+func (p *Policy) Methods() string {
+	return p.methods
+}
+
+// ExposedHeaders returns the value of the Access-Control-Expose-Headers
+// header.
+func (p *Policy) ExposedHeaders() string {
+	return p.exposedHeaders
+}
+
+// MaxAge returns the number of seconds browsers may cache a preflight
+// response.
+func (p *Policy) MaxAge() int {
+	return p.maxAge
+}
+
+// Credentials reports whether credentials are allowed.
+func (p *Policy) Credentials() bool {
+	return p.credentials
+}
+
+// VaryOrigin reports whether responses depend on the Origin header, and so
+// must carry Vary: Origin.
+func (p *Policy) VaryOrigin() bool {
+	return !p.anyOrigin
+}
+
+// isHostChar reports whether r may appear in the part of a host name matched
+// by a wildcard.
+func isHostChar(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' || r == '.'
+}
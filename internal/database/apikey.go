@@ -0,0 +1,348 @@
+package database
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+)
+
+const (
+	// ErrAPIKeyNotFound is returned when an API key does not exist or does not
+	// match the stored hash.
+	ErrAPIKeyNotFound xerrors.Error = "api key not found"
+
+	// ErrAPIKeyRevoked is returned when an API key has been revoked.
+	ErrAPIKeyRevoked xerrors.Error = "api key revoked"
+
+	// ErrAPIKeyExpired is returned when an API key has expired.
+	ErrAPIKeyExpired xerrors.Error = "api key expired"
+
+	// ErrQuotaExceeded is returned when an API key has used up its daily
+	// quota.
+	ErrQuotaExceeded xerrors.Error = "daily quota exceeded"
+
+	// ErrEmptyOwner is returned when an API key is created without an owner.
+	ErrEmptyOwner xerrors.Error = "owner cannot be empty"
+)
+
+const (
+	// APIKeyPrefix is the prefix of every API key, which makes keys easy to
+	// spot in configuration files and by secret scanners.
+	APIKeyPrefix string = "acopw"
+
+	// ScopeAll is the scope granting access to every generator.
+	ScopeAll string = "*"
+
+	// apiKeyIDSize is the size in bytes of the public identifier of a key.
+	apiKeyIDSize int = 8
+
+	// apiKeySecretSize is the size in bytes of the secret part of a key.
+	apiKeySecretSize int = 32
+)
+
+// APIKey represents an API key, without its secret.
+type APIKey struct {
+	// CreatedAt is the time the key was created.
+	CreatedAt time.Time
+
+	// ExpiresAt is the time the key expires. The zero value means the key
+	// never expires.
+	ExpiresAt time.Time
+
+	// KeyID is the public identifier of the key, which is also part of the
+	// key itself.
+	KeyID string
+
+	// Owner is the person or team the key was issued to.
+	Owner string
+
+	// Scopes are the generators the key grants access to.
+	Scopes []string
+
+	// ID is the database identifier of the key.
+	ID int64
+
+	// DailyQuota is the number of requests the key can make per UTC day. Zero
+	// means unlimited.
+	DailyQuota int64
+
+	// Revoked indicates whether the key has been revoked.
+	Revoked bool
+}
+
+// HasScope reports whether the key grants the given scope. An empty scope is
+// granted to every key.
+func (k *APIKey) HasScope(scope string) bool {
+	if scope == "" {
+		return true
+	}
+
+	for _, s := range k.Scopes {
+		if s == ScopeAll || s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CreateAPIKey creates a new API key and returns it along with its metadata.
+// The key itself is only stored as a hash and cannot be retrieved later.
+func (d *DB) CreateAPIKey(owner string, scopes []string, dailyQuota int64, expiresAt time.Time) (string, *APIKey, error) {
+	if owner == "" {
+		return "", nil, ErrEmptyOwner
+	}
+
+	id := make([]byte, apiKeyIDSize)
+	if _, err := rand.Read(id); err != nil {
+		return "", nil, fmt.Errorf("failed to generate api key: %w", err)
+	}
+
+	secret := make([]byte, apiKeySecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, fmt.Errorf("failed to generate api key: %w", err)
+	}
+
+	var (
+		keyID  = hex.EncodeToString(id)
+		key    = APIKeyPrefix + "_" + keyID + "_" + strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(secret))
+		now    = time.Now().UTC()
+		apiKey = &APIKey{
+			CreatedAt:  now,
+			ExpiresAt:  expiresAt,
+			KeyID:      keyID,
+			Owner:      owner,
+			Scopes:     scopes,
+			DailyQuota: dailyQuota,
+		}
+	)
+
+	result, err := d.db.Exec(
+		"INSERT INTO api_key (key_id, hash, owner, scopes, daily_quota, expires_at, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		keyID,
+		hashAPIKey(key),
+		owner,
+		strings.Join(scopes, ","),
+		dailyQuota,
+		nullableTime(expiresAt),
+		now.Unix(),
+	)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to store api key: %w", err)
+	}
+
+	apiKey.ID, err = result.LastInsertId()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to store api key: %w", err)
+	}
+
+	return key, apiKey, nil
+}
+
+// AuthenticateAPIKey returns the API key matching the given key, or an error
+// if it does not exist, has been revoked, or has expired.
+func (d *DB) AuthenticateAPIKey(key string) (*APIKey, error) {
+	parts := strings.Split(key, "_")
+	if len(parts) != 3 || parts[0] != APIKeyPrefix {
+		return nil, ErrAPIKeyNotFound
+	}
+
+	apiKey, hash, err := d.apiKey(parts[1])
+	if err != nil {
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(hashAPIKey(key))) != 1 {
+		return nil, ErrAPIKeyNotFound
+	}
+
+	if apiKey.Revoked {
+		return nil, ErrAPIKeyRevoked
+	}
+
+	if !apiKey.ExpiresAt.IsZero() && time.Now().After(apiKey.ExpiresAt) {
+		return nil, ErrAPIKeyExpired
+	}
+
+	return apiKey, nil
+}
+
+// APIKeys returns every API key, revoked and expired ones included.
+func (d *DB) APIKeys() ([]*APIKey, error) {
+	rows, err := d.db.Query("SELECT id, key_id, owner, scopes, daily_quota, expires_at, revoked, created_at FROM api_key ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get api keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []*APIKey
+
+	for rows.Next() {
+		apiKey, _, err := scanAPIKey(rows, false)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, apiKey)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get api keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey revokes the API key with the given public identifier.
+func (d *DB) RevokeAPIKey(keyID string) error {
+	result, err := d.db.Exec("UPDATE api_key SET revoked = 1 WHERE key_id = ?", keyID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+
+	if affected == 0 {
+		return ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+// IncrementAPIKeyUsage records a request made with the given key and returns
+// the number of requests it made so far in the current UTC day. If the key has
+// used up its daily quota, the request is not recorded and ErrQuotaExceeded is
+// returned.
+func (d *DB) IncrementAPIKeyUsage(apiKey *APIKey) (int64, error) {
+	quota := apiKey.DailyQuota
+	if quota <= 0 {
+		quota = math.MaxInt64
+	}
+
+	var count int64
+
+	err := d.db.QueryRow(
+		`INSERT INTO api_key_usage (api_key_id, day, count) VALUES (?, ?, 1)
+		ON CONFLICT (api_key_id, day) DO UPDATE SET count = count + 1 WHERE count < ?
+		RETURNING count`,
+		apiKey.ID,
+		today(),
+		quota,
+	).Scan(&count)
+	if errors.Is(err, sql.ErrNoRows) {
+		return quota, ErrQuotaExceeded
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to increment api key usage: %w", err)
+	}
+
+	return count, nil
+}
+
+// APIKeyUsage returns the number of requests made with the given key in the
+// current UTC day.
+func (d *DB) APIKeyUsage(apiKey *APIKey) (int64, error) {
+	var count int64
+
+	err := d.db.QueryRow("SELECT count FROM api_key_usage WHERE api_key_id = ? AND day = ?", apiKey.ID, today()).Scan(&count)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to get api key usage: %w", err)
+	}
+
+	return count, nil
+}
+
+// apiKey returns the API key with the given public identifier and its hash.
+func (d *DB) apiKey(keyID string) (*APIKey, string, error) {
+	row := d.db.QueryRow("SELECT id, key_id, owner, scopes, daily_quota, expires_at, revoked, created_at, hash FROM api_key WHERE key_id = ?", keyID)
+
+	apiKey, hash, err := scanAPIKey(row, true)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, "", ErrAPIKeyNotFound
+	}
+
+	return apiKey, hash, err
+}
+
+// scanner is implemented by *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanAPIKey reads an API key from a row, along with its hash if withHash is
+// true.
+func scanAPIKey(row scanner, withHash bool) (*APIKey, string, error) {
+	var (
+		apiKey    APIKey
+		scopes    string
+		hash      string
+		expiresAt sql.NullInt64
+		createdAt int64
+		dest      = []any{&apiKey.ID, &apiKey.KeyID, &apiKey.Owner, &scopes, &apiKey.DailyQuota, &expiresAt, &apiKey.Revoked, &createdAt}
+	)
+
+	if withHash {
+		dest = append(dest, &hash)
+	}
+
+	if err := row.Scan(dest...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, "", err //nolint:wrapcheck // callers check for sql.ErrNoRows
+		}
+
+		return nil, "", fmt.Errorf("failed to scan api key: %w", err)
+	}
+
+	if scopes != "" {
+		apiKey.Scopes = strings.Split(scopes, ",")
+	}
+
+	if expiresAt.Valid {
+		apiKey.ExpiresAt = time.Unix(expiresAt.Int64, 0).UTC()
+	}
+
+	apiKey.CreatedAt = time.Unix(createdAt, 0).UTC()
+
+	return &apiKey, hash, nil
+}
+
+// hashAPIKey returns the hex-encoded SHA-256 hash of an API key. Keys carry
+// 256 bits of entropy, so a fast hash is enough.
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// nullableTime converts the zero time into NULL and any other time into a Unix
+// timestamp.
+func nullableTime(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+
+	return t.Unix()
+}
+
+// today returns the current UTC day in YYYY-MM-DD format.
+func today() string {
+	return time.Now().UTC().Format(time.DateOnly)
+}
@@ -0,0 +1,230 @@
+package database
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+)
+
+// ErrAuditLogTampered is returned when the hash chain of the audit log is
+// broken.
+const ErrAuditLogTampered xerrors.Error = "audit log has been tampered with"
+
+// ErrMissingAuditKey is returned when the audit log is used without a key.
+const ErrMissingAuditKey xerrors.Error = "missing audit log key"
+
+// AuditEntry represents a secret generation event in the audit log. It never
+// holds the generated secret.
+type AuditEntry struct {
+	// CreatedAt is the time the secret was generated.
+	CreatedAt time.Time `json:"createdAt"`
+
+	// Parameters are the non-secret query parameters of the request.
+	Parameters map[string]string `json:"parameters"`
+
+	// RequestID is the ID of the request.
+	RequestID string `json:"requestId"`
+
+	// Client is the identity of the client, as used for rate limiting.
+	Client string `json:"client"`
+
+	// Generator is the generator that was used.
+	Generator string `json:"generator"`
+
+	// PrevHash is the hash of the previous entry, or an empty string for the
+	// first entry.
+	PrevHash string `json:"prevHash"`
+
+	// Hash is the hash of the entry, chained to the previous one.
+	Hash string `json:"hash"`
+
+	// ID is the position of the entry in the log, starting at one.
+	ID int64 `json:"id"`
+
+	// Entropy is the entropy of the secret in bits, for generators that can
+	// account for it exactly.
+	Entropy float64 `json:"entropy,omitempty"`
+}
+
+// AppendAuditEntry appends an entry to the audit log, setting its ID, time,
+// and hashes. Entries are chained with HMAC-SHA256 under key, so the log
+// cannot be rewritten without it.
+func (d *DB) AppendAuditEntry(entry *AuditEntry, key []byte) error {
+	if len(key) == 0 {
+		return ErrMissingAuditKey
+	}
+
+	parameters, err := json.Marshal(entry.Parameters)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit entry parameters: %w", err)
+	}
+
+	d.auditMu.Lock()
+	defer d.auditMu.Unlock()
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to append audit entry: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // no-op once committed
+
+	var (
+		prevID   int64
+		prevHash string
+	)
+
+	err = tx.QueryRow("SELECT id, hash FROM audit_log ORDER BY id DESC LIMIT 1").Scan(&prevID, &prevHash)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to append audit entry: %w", err)
+	}
+
+	entry.ID = prevID + 1
+	entry.CreatedAt = time.Unix(time.Now().Unix(), 0).UTC()
+	entry.PrevHash = prevHash
+	entry.Hash = auditHash(key, entry, string(parameters))
+
+	_, err = tx.Exec(
+		`INSERT INTO audit_log (id, created_at, request_id, client, generator, parameters, entropy, prev_hash, hash)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.ID,
+		entry.CreatedAt.Unix(),
+		entry.RequestID,
+		entry.Client,
+		entry.Generator,
+		string(parameters),
+		entry.Entropy,
+		entry.PrevHash,
+		entry.Hash,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to append audit entry: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to append audit entry: %w", err)
+	}
+
+	return nil
+}
+
+// AuditEntries calls fn for every entry of the audit log, in order, stopping
+// at the first error.
+func (d *DB) AuditEntries(fn func(entry *AuditEntry) error) error {
+	return d.auditEntries(func(entry *AuditEntry, _ string) error {
+		return fn(entry)
+	})
+}
+
+// VerifyAuditLog checks the hash chain of the audit log and returns the number
+// of entries and the hash of the last one. Entries that were modified,
+// removed, inserted, or reordered make it return ErrAuditLogTampered.
+// Removing entries from the end of the log can only be detected by comparing
+// the returned hash with one recorded earlier.
+func (d *DB) VerifyAuditLog(key []byte) (int64, string, error) {
+	if len(key) == 0 {
+		return 0, "", ErrMissingAuditKey
+	}
+
+	var (
+		count    int64
+		prevHash string
+	)
+
+	err := d.auditEntries(func(entry *AuditEntry, parameters string) error {
+		switch {
+		case entry.ID != count+1:
+			return fmt.Errorf("%w: expected entry %d, found entry %d", ErrAuditLogTampered, count+1, entry.ID)
+		case entry.PrevHash != prevHash:
+			return fmt.Errorf("%w: entry %d is not chained to entry %d", ErrAuditLogTampered, entry.ID, count)
+		case !hmac.Equal([]byte(entry.Hash), []byte(auditHash(key, entry, parameters))):
+			return fmt.Errorf("%w: entry %d does not match its hash", ErrAuditLogTampered, entry.ID)
+		}
+
+		count++
+		prevHash = entry.Hash
+
+		return nil
+	})
+
+	return count, prevHash, err
+}
+
+// auditEntries calls fn for every entry of the audit log, in order, along with
+// its parameters as stored.
+func (d *DB) auditEntries(fn func(entry *AuditEntry, parameters string) error) error {
+	rows, err := d.db.Query(
+		"SELECT id, created_at, request_id, client, generator, parameters, entropy, prev_hash, hash FROM audit_log ORDER BY id",
+	)
+	if err != nil {
+		return fmt.Errorf("failed to get audit entries: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			entry      AuditEntry
+			createdAt  int64
+			parameters string
+		)
+
+		err := rows.Scan(
+			&entry.ID,
+			&createdAt,
+			&entry.RequestID,
+			&entry.Client,
+			&entry.Generator,
+			&parameters,
+			&entry.Entropy,
+			&entry.PrevHash,
+			&entry.Hash,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+
+		entry.CreatedAt = time.Unix(createdAt, 0).UTC()
+
+		if err := json.Unmarshal([]byte(parameters), &entry.Parameters); err != nil {
+			return fmt.Errorf("%w: entry %d has invalid parameters: %w", ErrAuditLogTampered, entry.ID, err)
+		}
+
+		if err := fn(&entry, parameters); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to get audit entries: %w", err)
+	}
+
+	return nil
+}
+
+// auditHash returns the hex-encoded HMAC-SHA256 of an audit entry, covering
+// every field but the hash itself, with the parameters as stored.
+func auditHash(key []byte, entry *AuditEntry, parameters string) string {
+	h := hmac.New(sha256.New, key)
+
+	// Encoding the fields as a JSON array keeps their boundaries unambiguous.
+	data, _ := json.Marshal([]any{
+		entry.ID,
+		entry.CreatedAt.Unix(),
+		entry.RequestID,
+		entry.Client,
+		entry.Generator,
+		parameters,
+		entry.Entropy,
+		entry.PrevHash,
+	})
+
+	h.Write(data)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,34 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// IncrementClient increments the request counter of the client with the given
+// identity.
+func (d *DB) IncrementClient(identity string) error {
+	_, err := d.db.Exec(
+		"INSERT INTO client_counter (identity, count) VALUES (?, 1) ON CONFLICT (identity) DO UPDATE SET count = count + 1",
+		identity,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to increment client counter: %w", err)
+	}
+
+	return nil
+}
+
+// ClientCount returns the request counter of the client with the given
+// identity, or zero if it has made no requests.
+func (d *DB) ClientCount(identity string) (uint64, error) {
+	var count uint64
+
+	err := d.db.QueryRow("SELECT count FROM client_counter WHERE identity = ?", identity).Scan(&count)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("failed to get client counter: %w", err)
+	}
+
+	return count, nil
+}
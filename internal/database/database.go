@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	_ "embed"
 	"fmt"
+	"strings"
 	"sync"
 
 	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
@@ -29,6 +30,24 @@ const (
 
 	// CounterTypePIN is the counter type for PINs.
 	CounterTypePIN = "PIN"
+
+	// CounterTypeWireGuard is the counter type for WireGuard keys.
+	CounterTypeWireGuard = "WireGuard"
+
+	// CounterTypeAge is the counter type for age keys.
+	CounterTypeAge = "Age"
+
+	// CounterTypeMnemonic is the counter type for mnemonic phrases.
+	CounterTypeMnemonic = "Mnemonic"
+
+	// CounterTypePronounceable is the counter type for pronounceable passwords.
+	CounterTypePronounceable = "Pronounceable"
+
+	// CounterTypeRecoveryCodes is the counter type for sets of recovery codes.
+	CounterTypeRecoveryCodes = "RecoveryCodes"
+
+	// CounterTypeDerived is the counter type for derived site passwords.
+	CounterTypeDerived = "Derived"
 )
 
 //go:embed schema.sql
@@ -36,10 +55,12 @@ var schema string
 
 // DB wraps the database connection and stores the access counter.
 type DB struct {
-	db     *sql.DB
-	logger *zap.Logger
-	count  map[string]uint64
-	mu     sync.Mutex
+	db            *sql.DB
+	logger        *zap.Logger
+	count         map[string]uint64
+	mu            sync.Mutex
+	auditMu       sync.Mutex
+	fingerprintMu sync.Mutex
 }
 
 // Open opens a database connection and returns a DB instance.
@@ -167,3 +188,19 @@ func (d *DB) Increment(counterType string) error {
 
 	return nil
 }
+
+// Path returns the path of the database file named by a data source name, or
+// an empty string for in-memory databases.
+func Path(dsn string) string {
+	path := strings.TrimPrefix(dsn, "file:")
+
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+
+	if path == ":memory:" {
+		return ""
+	}
+
+	return path
+}
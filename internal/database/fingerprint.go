@@ -0,0 +1,61 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// ClaimFingerprints stores the fingerprints of a set of issued secrets, unless
+// any of them is already stored, in which case nothing is stored and false is
+// returned. Claims are serialized, so two concurrent requests can never both
+// claim the same fingerprint.
+func (d *DB) ClaimFingerprints(fingerprints [][]byte) (bool, error) {
+	d.fingerprintMu.Lock()
+	defer d.fingerprintMu.Unlock()
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim fingerprints: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // no-op once committed
+
+	now := time.Now().Unix()
+
+	for _, fingerprint := range fingerprints {
+		result, err := tx.Exec("INSERT OR IGNORE INTO fingerprint (hash, created_at) VALUES (?, ?)", fingerprint, now)
+		if err != nil {
+			return false, fmt.Errorf("failed to claim fingerprints: %w", err)
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return false, fmt.Errorf("failed to claim fingerprints: %w", err)
+		}
+
+		if rows == 0 {
+			return false, nil
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to claim fingerprints: %w", err)
+	}
+
+	return true, nil
+}
+
+// DeleteFingerprintsBefore deletes every fingerprint stored before the given
+// time and returns how many were deleted.
+func (d *DB) DeleteFingerprintsBefore(before time.Time) (int64, error) {
+	result, err := d.db.Exec("DELETE FROM fingerprint WHERE created_at < ?", before.Unix())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete old fingerprints: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete old fingerprints: %w", err)
+	}
+
+	return rows, nil
+}
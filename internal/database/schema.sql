@@ -7,3 +7,80 @@ CREATE TABLE IF NOT EXISTS counter (
 INSERT OR IGNORE INTO counter (id, type, count) VALUES (1, 'Random', 0);
 INSERT OR IGNORE INTO counter (id, type, count) VALUES (2, 'Diceware', 0);
 INSERT OR IGNORE INTO counter (id, type, count) VALUES (3, 'PIN', 0);
+INSERT OR IGNORE INTO counter (id, type, count) VALUES (4, 'WireGuard', 0);
+INSERT OR IGNORE INTO counter (id, type, count) VALUES (5, 'Age', 0);
+INSERT OR IGNORE INTO counter (id, type, count) VALUES (6, 'Mnemonic', 0);
+INSERT OR IGNORE INTO counter (id, type, count) VALUES (7, 'Pronounceable', 0);
+INSERT OR IGNORE INTO counter (id, type, count) VALUES (8, 'RecoveryCodes', 0);
+INSERT OR IGNORE INTO counter (id, type, count) VALUES (9, 'Derived', 0);
+
+CREATE TABLE IF NOT EXISTS api_key (
+	id INTEGER PRIMARY KEY,
+	key_id TEXT NOT NULL UNIQUE,
+	hash TEXT NOT NULL,
+	owner TEXT NOT NULL,
+	scopes TEXT NOT NULL,
+	daily_quota INTEGER NOT NULL DEFAULT 0,
+	expires_at INTEGER,
+	revoked INTEGER NOT NULL DEFAULT 0,
+	created_at INTEGER NOT NULL
+) STRICT;
+
+CREATE TABLE IF NOT EXISTS api_key_usage (
+	api_key_id INTEGER NOT NULL REFERENCES api_key (id),
+	day TEXT NOT NULL,
+	count INTEGER NOT NULL,
+	PRIMARY KEY (api_key_id, day)
+) STRICT;
+
+CREATE TABLE IF NOT EXISTS client_counter (
+	identity TEXT PRIMARY KEY,
+	count INTEGER NOT NULL
+) STRICT;
+
+CREATE TABLE IF NOT EXISTS signing_key (
+	id INTEGER PRIMARY KEY,
+	key_id TEXT NOT NULL UNIQUE,
+	secret BLOB NOT NULL,
+	owner TEXT NOT NULL,
+	revoked INTEGER NOT NULL DEFAULT 0,
+	created_at INTEGER NOT NULL
+) STRICT;
+
+CREATE TABLE IF NOT EXISTS audit_log (
+	id INTEGER PRIMARY KEY,
+	created_at INTEGER NOT NULL,
+	request_id TEXT NOT NULL,
+	client TEXT NOT NULL,
+	generator TEXT NOT NULL,
+	parameters TEXT NOT NULL,
+	entropy REAL NOT NULL,
+	prev_hash TEXT NOT NULL,
+	hash TEXT NOT NULL
+) STRICT;
+
+CREATE TRIGGER IF NOT EXISTS audit_log_no_update BEFORE UPDATE ON audit_log
+BEGIN
+	SELECT RAISE(ABORT, 'audit log is append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS audit_log_no_delete BEFORE DELETE ON audit_log
+BEGIN
+	SELECT RAISE(ABORT, 'audit log is append-only');
+END;
+
+CREATE TABLE IF NOT EXISTS share (
+	id TEXT PRIMARY KEY,
+	ciphertext BLOB NOT NULL,
+	created_at INTEGER NOT NULL,
+	expires_at INTEGER NOT NULL
+) STRICT;
+
+CREATE INDEX IF NOT EXISTS share_expires_at ON share (expires_at);
+
+CREATE TABLE IF NOT EXISTS fingerprint (
+	hash BLOB PRIMARY KEY,
+	created_at INTEGER NOT NULL
+) STRICT, WITHOUT ROWID;
+
+CREATE INDEX IF NOT EXISTS fingerprint_created_at ON fingerprint (created_at);
@@ -0,0 +1,109 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+)
+
+// ErrShareNotFound is returned when a shared secret does not exist, has
+// expired, or was already read.
+const ErrShareNotFound xerrors.Error = "shared secret not found"
+
+// Share represents an encrypted secret waiting to be read once.
+type Share struct {
+	// CreatedAt is the time the secret was shared.
+	CreatedAt time.Time
+
+	// ExpiresAt is the time after which the secret can no longer be read.
+	ExpiresAt time.Time
+
+	// ID is the public identifier of the share.
+	ID string
+
+	// Ciphertext is the encrypted secret. The key is never stored.
+	Ciphertext []byte
+}
+
+// CreateShare stores an encrypted secret.
+func (d *DB) CreateShare(share *Share) error {
+	_, err := d.db.Exec(
+		"INSERT INTO share (id, ciphertext, created_at, expires_at) VALUES (?, ?, ?, ?)",
+		share.ID,
+		share.Ciphertext,
+		share.CreatedAt.Unix(),
+		share.ExpiresAt.Unix(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store share: %w", err)
+	}
+
+	return nil
+}
+
+// Share returns the unexpired share with the given ID.
+func (d *DB) Share(id string) (*Share, error) {
+	var (
+		share     = &Share{ID: id}
+		createdAt int64
+		expiresAt int64
+	)
+
+	err := d.db.QueryRow(
+		"SELECT ciphertext, created_at, expires_at FROM share WHERE id = ? AND expires_at > ?",
+		id,
+		time.Now().Unix(),
+	).Scan(&share.Ciphertext, &createdAt, &expiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrShareNotFound
+		}
+
+		return nil, fmt.Errorf("failed to get share: %w", err)
+	}
+
+	share.CreatedAt = time.Unix(createdAt, 0).UTC()
+	share.ExpiresAt = time.Unix(expiresAt, 0).UTC()
+
+	return share, nil
+}
+
+// DeleteShare deletes the share with the given ID. It returns
+// ErrShareNotFound if the share was already deleted, so that only one of
+// several concurrent readers may claim it.
+func (d *DB) DeleteShare(id string) error {
+	result, err := d.db.Exec("DELETE FROM share WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete share: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete share: %w", err)
+	}
+
+	if rows == 0 {
+		return ErrShareNotFound
+	}
+
+	return nil
+}
+
+// DeleteExpiredShares deletes every expired share and returns how many were
+// deleted.
+func (d *DB) DeleteExpiredShares() (int64, error) {
+	result, err := d.db.Exec("DELETE FROM share WHERE expires_at <= ?", time.Now().Unix())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired shares: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired shares: %w", err)
+	}
+
+	return rows, nil
+}
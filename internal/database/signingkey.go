@@ -0,0 +1,170 @@
+package database
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+)
+
+const (
+	// ErrSigningKeyNotFound is returned when a signing key does not exist.
+	ErrSigningKeyNotFound xerrors.Error = "signing key not found"
+
+	// ErrSigningKeyRevoked is returned when a signing key has been revoked.
+	ErrSigningKeyRevoked xerrors.Error = "signing key revoked"
+)
+
+// signingKeySecretSize is the size in bytes of signing key secrets.
+const signingKeySecretSize int = 32
+
+// SigningKey represents a secret shared with a client to sign requests.
+type SigningKey struct {
+	// CreatedAt is the time the key was created.
+	CreatedAt time.Time
+
+	// KeyID is the public identifier of the key.
+	KeyID string
+
+	// Owner is the person or service the key was issued to.
+	Owner string
+
+	// Secret is the shared secret. It is only set by CreateSigningKey and
+	// SigningKey.
+	Secret []byte
+
+	// ID is the database identifier of the key.
+	ID int64
+
+	// Revoked indicates whether the key has been revoked.
+	Revoked bool
+}
+
+// CreateSigningKey creates a new signing key with a random secret. Unlike API
+// keys, the secret is stored as is, since the server needs it to verify
+// signatures.
+func (d *DB) CreateSigningKey(owner string) (*SigningKey, error) {
+	if owner == "" {
+		return nil, ErrEmptyOwner
+	}
+
+	id := make([]byte, apiKeyIDSize)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+
+	secret := make([]byte, signingKeySecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+
+	signingKey := &SigningKey{
+		CreatedAt: time.Now().UTC(),
+		KeyID:     hex.EncodeToString(id),
+		Owner:     owner,
+		Secret:    secret,
+	}
+
+	result, err := d.db.Exec(
+		"INSERT INTO signing_key (key_id, secret, owner, created_at) VALUES (?, ?, ?, ?)",
+		signingKey.KeyID,
+		signingKey.Secret,
+		signingKey.Owner,
+		signingKey.CreatedAt.Unix(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store signing key: %w", err)
+	}
+
+	signingKey.ID, err = result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to store signing key: %w", err)
+	}
+
+	return signingKey, nil
+}
+
+// SigningKey returns the active signing key with the given public identifier,
+// secret included.
+func (d *DB) SigningKey(keyID string) (*SigningKey, error) {
+	var (
+		signingKey SigningKey
+		createdAt  int64
+	)
+
+	err := d.db.QueryRow(
+		"SELECT id, key_id, secret, owner, revoked, created_at FROM signing_key WHERE key_id = ?",
+		keyID,
+	).Scan(&signingKey.ID, &signingKey.KeyID, &signingKey.Secret, &signingKey.Owner, &signingKey.Revoked, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSigningKeyNotFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get signing key: %w", err)
+	}
+
+	if signingKey.Revoked {
+		return nil, ErrSigningKeyRevoked
+	}
+
+	signingKey.CreatedAt = time.Unix(createdAt, 0).UTC()
+
+	return &signingKey, nil
+}
+
+// SigningKeys returns every signing key, revoked ones included, without their
+// secrets.
+func (d *DB) SigningKeys() ([]*SigningKey, error) {
+	rows, err := d.db.Query("SELECT id, key_id, owner, revoked, created_at FROM signing_key ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get signing keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []*SigningKey
+
+	for rows.Next() {
+		var (
+			signingKey SigningKey
+			createdAt  int64
+		)
+
+		if err := rows.Scan(&signingKey.ID, &signingKey.KeyID, &signingKey.Owner, &signingKey.Revoked, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan signing key: %w", err)
+		}
+
+		signingKey.CreatedAt = time.Unix(createdAt, 0).UTC()
+
+		keys = append(keys, &signingKey)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get signing keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// RevokeSigningKey revokes the signing key with the given public identifier.
+func (d *DB) RevokeSigningKey(keyID string) error {
+	result, err := d.db.Exec("UPDATE signing_key SET revoked = 1 WHERE key_id = ?", keyID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke signing key: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to revoke signing key: %w", err)
+	}
+
+	if affected == 0 {
+		return ErrSigningKeyNotFound
+	}
+
+	return nil
+}
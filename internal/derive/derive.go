@@ -0,0 +1,132 @@
+// Package derive derives site passwords deterministically from a master
+// secret, in the style of stateless password managers such as LessPass and
+// Spectre. The same inputs give the same password on every instance, so no
+// password ever needs to be stored.
+//
+// A password is derived in two steps. First, the master secret is stretched
+// with Argon2id into a 32-byte key, salted with the SHA-256 digest of the
+// site and login:
+//
+//	salt = SHA-256("acopw-derive-v1" || 0x00 || len(site) || site || len(login) || login)
+//	key  = Argon2id(master, salt, time = 3, memory = 64 MiB, threads = 4, length = 32)
+//
+// Lengths and the counter are big-endian uint32 values, and the site is
+// trimmed and lowercased first, so that "Example.com" and "example.com " give
+// the same password. Second, the key is expanded with HKDF-SHA256 into a
+// stream of bytes, bound to the counter and the character set:
+//
+//	info   = "acopw-derive-v1" || 0x00 || counter || len(charset) || charset
+//	stream = HKDF-SHA256(key, salt = nil, info)
+//
+// Characters are then picked from the stream by rejection sampling, exactly as
+// random passwords are picked from crypto/rand by secmem.Fill: bytes are read
+// length + length/2 + 1 at a time, bytes at or above 256 - 256 % len(charset)
+// are skipped, every other byte picks charset[byte % len(charset)], and the
+// rest of a read is dropped once the password is full.
+//
+// The following test vectors use the default character set of every class,
+// lowercase, uppercase, numbers, and symbols, in that order:
+//
+//	master   site           login               counter  length  password
+//	"secret" "example.com"  "user@example.com"  1        16      "(hnC(\"lyds_yMcS;"
+//	"secret" "example.com"  "user@example.com"  2        16      "y=P,fwoihw,Snv2w"
+//	"secret" "example.org"  "user@example.com"  1        32      "^Jqt8E,(c}?=6\\=l*Mf{W|p*&w2p?aT$"
+//
+// Passwords are quoted as Go strings.
+//
+// Changing any step above changes every derived password, so the algorithm is
+// versioned, and any change must come with a new Version.
+package derive
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/secmem"
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// ErrEmptyMaster is returned when deriving a password from an empty
+	// master secret.
+	ErrEmptyMaster xerrors.Error = "master secret cannot be empty"
+
+	// ErrEmptySite is returned when deriving a password for an empty site.
+	ErrEmptySite xerrors.Error = "site cannot be empty"
+
+	// ErrInvalidCounter is returned when the counter is zero.
+	ErrInvalidCounter xerrors.Error = "counter must be at least 1"
+)
+
+const (
+	// Version identifies the derivation algorithm, and is mixed into both the
+	// salt and the HKDF info.
+	Version string = "acopw-derive-v1"
+
+	// DefaultCounter is the default counter, which is incremented to rotate a
+	// password without changing the master secret.
+	DefaultCounter uint32 = 1
+
+	// argon2Time is the number of Argon2id passes.
+	argon2Time uint32 = 3
+
+	// argon2Memory is the amount of Argon2id memory, in KiB.
+	argon2Memory uint32 = 64 * 1024
+
+	// argon2Threads is the Argon2id degree of parallelism.
+	argon2Threads uint8 = 4
+
+	// keySize is the size in bytes of the key stretched from the master
+	// secret.
+	keySize uint32 = 32
+)
+
+// Password fills b with the password derived from the master secret for the
+// given site, login, and counter, using characters from charset.
+func Password(b, master []byte, site, login string, counter uint32, charset string) error {
+	site = strings.ToLower(strings.TrimSpace(site))
+
+	switch {
+	case len(master) == 0:
+		return ErrEmptyMaster
+	case site == "":
+		return ErrEmptySite
+	case counter == 0:
+		return ErrInvalidCounter
+	}
+
+	key := argon2.IDKey(master, salt(site, login), argon2Time, argon2Memory, argon2Threads, keySize)
+	defer secmem.Zero(key)
+
+	info := binary.BigEndian.AppendUint32([]byte(Version+"\x00"), counter)
+	info = appendField(info, []byte(charset))
+
+	if err := secmem.FillFrom(hkdf.New(sha256.New, key, nil, info), b, charset); err != nil {
+		return fmt.Errorf("failed to derive password: %w", err)
+	}
+
+	return nil
+}
+
+// salt returns the Argon2id salt for the given site and login.
+func salt(site, login string) []byte {
+	data := []byte(Version + "\x00")
+	data = appendField(data, []byte(site))
+	data = appendField(data, []byte(login))
+
+	sum := sha256.Sum256(data)
+
+	return sum[:]
+}
+
+// appendField appends the length of field as a big-endian uint32, followed by
+// field itself, so that fields cannot run into each other.
+func appendField(b, field []byte) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(field)))
+
+	return append(b, field...)
+}
@@ -0,0 +1,79 @@
+package derive_test
+
+import (
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/derive"
+	"git.sr.ht/~jamesponddotco/acopw-go"
+)
+
+func TestPassword(t *testing.T) {
+	t.Parallel()
+
+	charset := (&acopw.Random{
+		UseLower:   true,
+		UseUpper:   true,
+		UseNumbers: true,
+		UseSymbols: true,
+	}).Charset()
+
+	tests := []struct {
+		name    string
+		master  string
+		site    string
+		login   string
+		want    string
+		counter uint32
+	}{
+		{
+			name:    "first counter",
+			master:  "secret",
+			site:    "example.com",
+			login:   "user@example.com",
+			counter: 1,
+			want:    "(hnC(\"lyds_yMcS;",
+		},
+		{
+			name:    "second counter",
+			master:  "secret",
+			site:    "example.com",
+			login:   "user@example.com",
+			counter: 2,
+			want:    "y=P,fwoihw,Snv2w",
+		},
+		{
+			name:    "other site",
+			master:  "secret",
+			site:    "example.org",
+			login:   "user@example.com",
+			counter: 1,
+			want:    "^Jqt8E,(c}?=6\\=l*Mf{W|p*&w2p?aT$",
+		},
+		{
+			name:    "normalized site",
+			master:  "secret",
+			site:    " Example.COM ",
+			login:   "user@example.com",
+			counter: 1,
+			want:    "(hnC(\"lyds_yMcS;",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			password := make([]byte, len(tt.want))
+
+			if err := derive.Password(password, []byte(tt.master), tt.site, tt.login, tt.counter, charset); err != nil {
+				t.Fatalf("Password() error = %v", err)
+			}
+
+			if string(password) != tt.want {
+				t.Errorf("Password() = %q, want %q", password, tt.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,11 @@
 package endpoint
 
-import "git.sr.ht/~jamesponddotco/acciopassword/internal/build"
+import (
+	"net/http"
+	"strings"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/build"
+)
 
 const (
 	// Root is the endpoint for the root handler.
@@ -15,6 +20,41 @@ const (
 	// PIN is the endpoint for the PIN handler.
 	PIN string = Root + build.APIVersion + "/pin/"
 
+	// WireGuard is the endpoint for the WireGuard handler.
+	WireGuard string = Root + build.APIVersion + "/wireguard/"
+
+	// WireGuardPSK is the endpoint for the WireGuard preshared key handler.
+	WireGuardPSK string = WireGuard + "psk/"
+
+	// Age is the endpoint for the age handler.
+	Age string = Root + build.APIVersion + "/age/"
+
+	// Mnemonic is the endpoint for the Mnemonic handler.
+	Mnemonic string = Root + build.APIVersion + "/mnemonic/"
+
+	// MnemonicValidation is the endpoint for the mnemonic validation handler.
+	MnemonicValidation string = Mnemonic + "validate/"
+
+	// Pronounceable is the endpoint for the Pronounceable handler.
+	Pronounceable string = Root + build.APIVersion + "/pronounceable/"
+
+	// RecoveryCodes is the endpoint for the RecoveryCodes handler.
+	RecoveryCodes string = Root + build.APIVersion + "/recovery-codes/"
+
+	// Derive is the endpoint for the Derive handler.
+	Derive string = Root + build.APIVersion + "/derive/"
+
+	// Share is the endpoint for the one-time secret sharing handler. Shared
+	// secrets are read from paths below it.
+	Share string = Root + build.APIVersion + "/share/"
+
+	// Combine is the endpoint for the handler recovering secrets split with
+	// Shamir's Secret Sharing.
+	Combine string = Root + build.APIVersion + "/combine/"
+
+	// Challenge is the endpoint for the proof-of-work Challenge handler.
+	Challenge string = Root + build.APIVersion + "/challenge/"
+
 	// Metrics is the endpoint for the Metrics handler.
 	Metrics string = Root + build.APIVersion + "/metrics/"
 
@@ -24,3 +64,77 @@ const (
 	// Ping is the endpoint for the Ping handler.
 	Ping string = Root + build.APIVersion + "/ping/"
 )
+
+// Paths returns every endpoint except Root.
+func Paths() []string {
+	return []string{
+		Random,
+		Diceware,
+		PIN,
+		WireGuard,
+		WireGuardPSK,
+		Age,
+		Mnemonic,
+		MnemonicValidation,
+		Pronounceable,
+		RecoveryCodes,
+		Derive,
+		Share,
+		Combine,
+		Challenge,
+		Metrics,
+		Health,
+		Ping,
+	}
+}
+
+// scopes maps generator endpoints to the API key scope granting access to
+// them.
+var scopes = map[string]string{
+	Random:        "random",
+	Diceware:      "diceware",
+	PIN:           "pin",
+	WireGuard:     "wireguard",
+	WireGuardPSK:  "wireguard",
+	Age:           "age",
+	Mnemonic:      "mnemonic",
+	Pronounceable: "pronounceable",
+	RecoveryCodes: "recovery-codes",
+	Derive:        "derive",
+	Share:         "share",
+}
+
+// methods maps endpoints to the HTTP methods they accept, for those accepting
+// methods other than GET, HEAD, and OPTIONS.
+var methods = map[string][]string{
+	Share:              {http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPost},
+	MnemonicValidation: {http.MethodOptions, http.MethodPost},
+	Derive:             {http.MethodOptions, http.MethodPost},
+	Combine:            {http.MethodOptions, http.MethodPost},
+}
+
+// Methods returns the HTTP methods accepted by the given endpoint.
+func Methods(path string) []string {
+	if m, ok := methods[path]; ok {
+		return m
+	}
+
+	return []string{http.MethodGet, http.MethodHead, http.MethodOptions}
+}
+
+// Scope returns the API key scope required to access the given endpoint, or an
+// empty string if the endpoint is open to every key.
+func Scope(path string) string {
+	return scopes[path]
+}
+
+// Name returns the name of an endpoint, which is its path without the API
+// version and surrounding slashes, such as wireguard/psk.
+func Name(path string) string {
+	return strings.Trim(strings.TrimPrefix(path, Root+build.APIVersion), "/")
+}
+
+// Scopes returns every API key scope.
+func Scopes() []string {
+	return []string{"random", "diceware", "pin", "wireguard", "age", "mnemonic", "pronounceable", "recovery-codes", "derive", "share"}
+}
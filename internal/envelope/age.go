@@ -0,0 +1,245 @@
+package envelope
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/bech32"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/keygen"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/secmem"
+	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// ageRecipientPrefix is the prefix of age X25519 recipients.
+	ageRecipientPrefix string = keygen.AgeRecipientHRP + "1"
+
+	// ageVersionLine is the first line of age files.
+	ageVersionLine string = "age-encryption.org/v1"
+
+	// ageX25519Label is the HKDF info string for X25519 stanzas.
+	ageX25519Label string = "age-encryption.org/v1/X25519"
+
+	// ageArmorHeader and ageArmorFooter surround armored age files.
+	ageArmorHeader string = "-----BEGIN AGE ENCRYPTED FILE-----"
+	ageArmorFooter string = "-----END AGE ENCRYPTED FILE-----"
+
+	// ageFileKeySize is the size in bytes of age file keys.
+	ageFileKeySize int = 16
+
+	// ageNonceSize is the size in bytes of the payload nonce.
+	ageNonceSize int = 16
+
+	// ageChunkSize is the size in bytes of payload chunks.
+	ageChunkSize int = 64 << 10
+
+	// ageColumns is the width of base64 lines in stanzas and armor.
+	ageColumns int = 64
+)
+
+// ageRecipient is an age X25519 recipient.
+type ageRecipient struct {
+	publicKey *ecdh.PublicKey
+}
+
+// parseAgeRecipient parses a Bech32-encoded age X25519 recipient.
+func parseAgeRecipient(recipient string) (*ageRecipient, error) {
+	hrp, data, err := bech32.Decode(recipient)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRecipient, err)
+	}
+
+	if hrp != keygen.AgeRecipientHRP {
+		return nil, fmt.Errorf("%w: unsupported age recipient type %q", ErrInvalidRecipient, hrp)
+	}
+
+	publicKey, err := ecdh.X25519().NewPublicKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRecipient, err)
+	}
+
+	return &ageRecipient{publicKey: publicKey}, nil
+}
+
+// ContentType implements Recipient.
+func (*ageRecipient) ContentType() string {
+	return xhttp.TextPlain
+}
+
+// Encrypt implements Recipient. It returns an armored age file, as described
+// in the age v1 specification at https://age-encryption.org/v1.
+func (a *ageRecipient) Encrypt(plaintext []byte) ([]byte, error) {
+	fileKey := make([]byte, ageFileKeySize)
+	defer secmem.Zero(fileKey)
+
+	if _, err := rand.Read(fileKey); err != nil {
+		return nil, fmt.Errorf("failed to generate age file key: %w", err)
+	}
+
+	stanza, err := a.wrap(fileKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var file bytes.Buffer
+
+	file.WriteString(ageVersionLine + "\n")
+	file.WriteString(stanza)
+	file.WriteString("---")
+
+	macKey, err := ageHKDF(fileKey, nil, "header")
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(file.Bytes())
+
+	file.WriteString(" " + base64.RawStdEncoding.EncodeToString(mac.Sum(nil)) + "\n")
+
+	nonce := make([]byte, ageNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate age payload nonce: %w", err)
+	}
+
+	file.Write(nonce)
+
+	payloadKey, err := ageHKDF(fileKey, nonce, "payload")
+	if err != nil {
+		return nil, err
+	}
+	defer secmem.Zero(payloadKey)
+
+	if err := ageSealPayload(&file, payloadKey, plaintext); err != nil {
+		return nil, err
+	}
+
+	return ageArmor(file.Bytes()), nil
+}
+
+// wrap wraps the file key in an X25519 recipient stanza.
+func (a *ageRecipient) wrap(fileKey []byte) (string, error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+
+	shared, err := ephemeral.ECDH(a.publicKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to compute shared secret: %w", err)
+	}
+	defer secmem.Zero(shared)
+
+	share := ephemeral.PublicKey().Bytes()
+	salt := append(append([]byte{}, share...), a.publicKey.Bytes()...)
+
+	wrapKey, err := ageHKDF(shared, salt, ageX25519Label)
+	if err != nil {
+		return "", err
+	}
+	defer secmem.Zero(wrapKey)
+
+	aead, err := chacha20poly1305.New(wrapKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to create age cipher: %w", err)
+	}
+
+	wrapped := aead.Seal(nil, make([]byte, chacha20poly1305.NonceSize), fileKey, nil)
+
+	return "-> X25519 " + base64.RawStdEncoding.EncodeToString(share) + "\n" +
+		ageWrapLines(base64.RawStdEncoding.EncodeToString(wrapped)), nil
+}
+
+// ageSealPayload encrypts plaintext with the STREAM construction used by age,
+// in chunks of 64 KiB, and writes it to w.
+func ageSealPayload(w io.Writer, key, plaintext []byte) error {
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return fmt.Errorf("failed to create age cipher: %w", err)
+	}
+
+	var (
+		nonce   = make([]byte, chacha20poly1305.NonceSize)
+		counter uint64
+	)
+
+	for {
+		chunk := plaintext
+		if len(chunk) > ageChunkSize {
+			chunk = chunk[:ageChunkSize]
+		}
+
+		plaintext = plaintext[len(chunk):]
+
+		// The nonce is a big-endian chunk counter followed by a byte set to
+		// one for the last chunk.
+		binary.BigEndian.PutUint64(nonce[3:11], counter)
+
+		if len(plaintext) == 0 {
+			nonce[11] = 1
+		}
+
+		if _, err := w.Write(aead.Seal(nil, nonce, chunk, nil)); err != nil {
+			return fmt.Errorf("failed to write age payload: %w", err)
+		}
+
+		if len(plaintext) == 0 {
+			return nil
+		}
+
+		counter++
+	}
+}
+
+// ageHKDF derives a 32-byte key with HKDF-SHA256.
+func ageHKDF(secret, salt []byte, info string) ([]byte, error) {
+	key := make([]byte, chacha20poly1305.KeySize)
+
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, []byte(info)), key); err != nil {
+		return nil, fmt.Errorf("failed to derive age key: %w", err)
+	}
+
+	return key, nil
+}
+
+// ageWrapLines splits a base64 string into lines of 64 columns. The last line
+// is always shorter than 64 columns, even if empty, to mark the end of the
+// body.
+func ageWrapLines(s string) string {
+	var lines string
+
+	for len(s) >= ageColumns {
+		lines += s[:ageColumns] + "\n"
+		s = s[ageColumns:]
+	}
+
+	return lines + s + "\n"
+}
+
+// ageArmor returns the ASCII armored form of an age file.
+func ageArmor(file []byte) []byte {
+	encoded := base64.StdEncoding.EncodeToString(file)
+
+	var armored bytes.Buffer
+
+	armored.WriteString(ageArmorHeader + "\n")
+
+	for len(encoded) > ageColumns {
+		armored.WriteString(encoded[:ageColumns] + "\n")
+		encoded = encoded[ageColumns:]
+	}
+
+	armored.WriteString(encoded + "\n")
+	armored.WriteString(ageArmorFooter + "\n")
+
+	return armored.Bytes()
+}
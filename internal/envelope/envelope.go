@@ -0,0 +1,47 @@
+// Package envelope encrypts generated secrets to a public key supplied by the
+// client, so they never exist in plaintext outside of it, not even in proxies
+// terminating TLS in front of the server.
+package envelope
+
+import (
+	"strings"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+)
+
+const (
+	// ErrUnknownRecipient is returned when a recipient is not an age
+	// recipient, an OpenPGP public key, or an X25519 JWK.
+	ErrUnknownRecipient xerrors.Error = "unknown recipient type"
+
+	// ErrInvalidRecipient is returned when a recipient cannot be parsed.
+	ErrInvalidRecipient xerrors.Error = "invalid recipient"
+)
+
+// Recipient encrypts secrets to a public key.
+type Recipient interface {
+	// Encrypt encrypts plaintext to the recipient.
+	Encrypt(plaintext []byte) ([]byte, error)
+
+	// ContentType returns the media type of the encrypted output.
+	ContentType() string
+}
+
+// Parse parses a recipient, which may be an age X25519 recipient, an armored
+// OpenPGP public key, or an X25519 public key in JWK format. Secrets are
+// encrypted to them as an armored age file, an armored OpenPGP message, or a
+// JWE in compact serialization, respectively.
+func Parse(recipient string) (Recipient, error) {
+	recipient = strings.TrimSpace(recipient)
+
+	switch {
+	case strings.HasPrefix(strings.ToLower(recipient), ageRecipientPrefix):
+		return parseAgeRecipient(recipient)
+	case strings.HasPrefix(recipient, openPGPArmorPrefix):
+		return parseOpenPGPRecipient(recipient)
+	case strings.HasPrefix(recipient, "{"):
+		return parseJWKRecipient(recipient)
+	default:
+		return nil, ErrUnknownRecipient
+	}
+}
@@ -0,0 +1,168 @@
+package envelope_test
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	ageArmor "filippo.io/age/armor"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/envelope"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+// plaintexts covers a single chunk, a payload filling exactly one age chunk,
+// and one spilling over into a second chunk.
+var plaintexts = map[string][]byte{
+	"short":          []byte("correct horse battery staple"),
+	"one chunk":      bytes.Repeat([]byte{'a'}, 64<<10),
+	"two chunks":     bytes.Repeat([]byte{'b'}, 64<<10+1),
+	"multiple lines": []byte("first line\nsecond line\n"),
+}
+
+func TestAgeRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("failed to generate age identity: %v", err)
+	}
+
+	recipient, err := envelope.Parse(identity.Recipient().String())
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	for name, plaintext := range plaintexts {
+		encrypted, err := recipient.Encrypt(plaintext)
+		if err != nil {
+			t.Fatalf("%s: Encrypt() error = %v", name, err)
+		}
+
+		r, err := age.Decrypt(ageArmor.NewReader(bytes.NewReader(encrypted)), identity)
+		if err != nil {
+			t.Fatalf("%s: age cannot decrypt the output: %v", name, err)
+		}
+
+		decrypted, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("%s: age cannot decrypt the payload: %v", name, err)
+		}
+
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Errorf("%s: decrypted %d bytes, want %d", name, len(decrypted), len(plaintext))
+		}
+	}
+}
+
+func TestJWEKnownAnswer(t *testing.T) {
+	t.Parallel()
+
+	// The keys of Alice, as the ephemeral key, and Bob, as the recipient, from
+	// RFC 7748 section 6.1, which RFC 8037 appendix A.6 uses for ECDH-ES. The
+	// expected JWE decrypts with independent implementations.
+	const (
+		ephemeralKey = "77076d0a7318a57d3c16c17251b26645df4c2f87ebc0992ab177fba51db92c2a"
+		recipientJWK = `{"kty":"OKP","crv":"X25519","kid":"Bob","x":"3p7bfXt9wbTTW2HC7OQ1Nz-DQ8hbeGdNrfx-FG-IK08"}`
+		iv           = "000102030405060708090a0b"
+		want         = "eyJhbGciOiJFQ0RILUVTIiwiZW5jIjoiQTI1NkdDTSIsImVwayI6eyJrdHkiOiJPS1AiLCJjcnYiOiJYMjU1MTkiLCJ4IjoiaFNEd0NZa3dwMVIwaTMzY3RENzNXZzJfT2cwbU9CcjA2NlNwanFxYlRtbyJ9LCJraWQiOiJCb2IifQ" +
+			"..AAECAwQFBgcICQoL" +
+			".BB0ZKG9_HMWAzsokHKUeP7A3BJ6qqJojFVNQvg" +
+			".bcsO0xa2xiscDNzLe4l_mg"
+	)
+
+	recipient, err := envelope.Parse(recipientJWK)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	seed, err := hex.DecodeString(ephemeralKey)
+	if err != nil {
+		t.Fatalf("failed to decode ephemeral key: %v", err)
+	}
+
+	ephemeral, err := ecdh.X25519().NewPrivateKey(seed)
+	if err != nil {
+		t.Fatalf("failed to parse ephemeral key: %v", err)
+	}
+
+	nonce, err := hex.DecodeString(iv)
+	if err != nil {
+		t.Fatalf("failed to decode IV: %v", err)
+	}
+
+	got, err := envelope.EncryptJWE(recipient, []byte("correct horse battery staple"), ephemeral, nonce)
+	if err != nil {
+		t.Fatalf("EncryptJWE() error = %v", err)
+	}
+
+	if string(got) != want {
+		t.Errorf("EncryptJWE() = %s, want %s", got, want)
+	}
+
+	if recipient.ContentType() != "application/jose" {
+		t.Errorf("ContentType() = %q, want %q", recipient.ContentType(), "application/jose")
+	}
+}
+
+func TestOpenPGPRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	entity, err := openpgp.NewEntity("Alice", "", "alice@example.com", &packet.Config{
+		Algorithm: packet.PubKeyAlgoEdDSA,
+	})
+	if err != nil {
+		t.Fatalf("failed to generate OpenPGP key: %v", err)
+	}
+
+	var publicKey strings.Builder
+
+	w, err := armor.Encode(&publicKey, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatalf("failed to armor OpenPGP key: %v", err)
+	}
+
+	if err := entity.Serialize(w); err != nil {
+		t.Fatalf("failed to serialize OpenPGP key: %v", err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("failed to armor OpenPGP key: %v", err)
+	}
+
+	recipient, err := envelope.Parse(publicKey.String())
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	for name, plaintext := range plaintexts {
+		encrypted, err := recipient.Encrypt(plaintext)
+		if err != nil {
+			t.Fatalf("%s: Encrypt() error = %v", name, err)
+		}
+
+		block, err := armor.Decode(bytes.NewReader(encrypted))
+		if err != nil {
+			t.Fatalf("%s: cannot read the armored message: %v", name, err)
+		}
+
+		message, err := openpgp.ReadMessage(block.Body, openpgp.EntityList{entity}, nil, nil)
+		if err != nil {
+			t.Fatalf("%s: cannot decrypt the message: %v", name, err)
+		}
+
+		decrypted, err := io.ReadAll(message.UnverifiedBody)
+		if err != nil {
+			t.Fatalf("%s: cannot read the message: %v", name, err)
+		}
+
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Errorf("%s: decrypted %d bytes, want %d", name, len(decrypted), len(plaintext))
+		}
+	}
+}
@@ -0,0 +1,9 @@
+package envelope
+
+import "crypto/ecdh"
+
+// EncryptJWE encrypts plaintext to a JWK recipient with the given ephemeral key
+// and IV, so that the output can be checked against known answers.
+func EncryptJWE(recipient Recipient, plaintext []byte, ephemeral *ecdh.PrivateKey, iv []byte) ([]byte, error) {
+	return recipient.(*jwkRecipient).encrypt(plaintext, ephemeral, iv)
+}
@@ -0,0 +1,197 @@
+package envelope
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/secmem"
+)
+
+const (
+	// jweContentType is the media type of JWEs in compact serialization, as
+	// registered by RFC 7515.
+	jweContentType string = "application/jose"
+
+	// jweAlgorithm is the key management algorithm, ECDH-ES in direct key
+	// agreement mode.
+	jweAlgorithm string = "ECDH-ES"
+
+	// jweEncryption is the content encryption algorithm.
+	jweEncryption string = "A256GCM"
+
+	// jweKeySize is the size in bytes of A256GCM content encryption keys.
+	jweKeySize int = 32
+
+	// jweIVSize is the size in bytes of A256GCM initialization vectors.
+	jweIVSize int = 12
+)
+
+// jwk is a JSON Web Key holding an X25519 public key, as described in RFC
+// 8037.
+type jwk struct {
+	KeyType string `json:"kty"`
+	Curve   string `json:"crv"`
+	X       string `json:"x"`
+	D       string `json:"d,omitempty"`
+	KeyID   string `json:"kid,omitempty"`
+	Use     string `json:"use,omitempty"`
+}
+
+// jweHeader is the protected header of a JWE.
+type jweHeader struct {
+	Algorithm    string `json:"alg"`
+	Encryption   string `json:"enc"`
+	EphemeralKey *jwk   `json:"epk"`
+	KeyID        string `json:"kid,omitempty"`
+}
+
+// jwkRecipient is an X25519 JWK recipient.
+type jwkRecipient struct {
+	publicKey *ecdh.PublicKey
+	keyID     string
+}
+
+// parseJWKRecipient parses an X25519 public key in JWK format.
+func parseJWKRecipient(recipient string) (*jwkRecipient, error) {
+	var key jwk
+
+	if err := json.Unmarshal([]byte(recipient), &key); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRecipient, err)
+	}
+
+	if key.KeyType != "OKP" || key.Curve != "X25519" {
+		return nil, fmt.Errorf("%w: JWK must be an OKP key on the X25519 curve", ErrInvalidRecipient)
+	}
+
+	if key.D != "" {
+		return nil, fmt.Errorf("%w: JWK must be a public key", ErrInvalidRecipient)
+	}
+
+	if key.Use != "" && key.Use != "enc" {
+		return nil, fmt.Errorf("%w: JWK is not meant for encryption", ErrInvalidRecipient)
+	}
+
+	x, err := base64.RawURLEncoding.DecodeString(key.X)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRecipient, err)
+	}
+
+	publicKey, err := ecdh.X25519().NewPublicKey(x)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRecipient, err)
+	}
+
+	return &jwkRecipient{
+		publicKey: publicKey,
+		keyID:     key.KeyID,
+	}, nil
+}
+
+// ContentType implements Recipient.
+func (*jwkRecipient) ContentType() string {
+	return jweContentType
+}
+
+// Encrypt implements Recipient. It returns a JWE in compact serialization,
+// encrypted with ECDH-ES and A256GCM as described in RFC 7516, RFC 7518, and
+// RFC 8037.
+func (j *jwkRecipient) Encrypt(plaintext []byte) ([]byte, error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+
+	iv := make([]byte, jweIVSize)
+	if _, err := rand.Read(iv); err != nil {
+		return nil, fmt.Errorf("failed to generate JWE IV: %w", err)
+	}
+
+	return j.encrypt(plaintext, ephemeral, iv)
+}
+
+// encrypt encrypts plaintext with the given ephemeral key and IV, which must
+// never be reused.
+func (j *jwkRecipient) encrypt(plaintext []byte, ephemeral *ecdh.PrivateKey, iv []byte) ([]byte, error) {
+	shared, err := ephemeral.ECDH(j.publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute shared secret: %w", err)
+	}
+	defer secmem.Zero(shared)
+
+	header, err := json.Marshal(&jweHeader{
+		Algorithm:  jweAlgorithm,
+		Encryption: jweEncryption,
+		EphemeralKey: &jwk{
+			KeyType: "OKP",
+			Curve:   "X25519",
+			X:       base64.RawURLEncoding.EncodeToString(ephemeral.PublicKey().Bytes()),
+		},
+		KeyID: j.keyID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode JWE header: %w", err)
+	}
+
+	key := concatKDF(shared, jweEncryption, jweKeySize)
+	defer secmem.Zero(key)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create JWE cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create JWE cipher: %w", err)
+	}
+
+	var (
+		protected  = base64.RawURLEncoding.EncodeToString(header)
+		sealed     = aead.Seal(nil, iv, plaintext, []byte(protected))
+		ciphertext = sealed[:len(sealed)-aead.Overhead()]
+		tag        = sealed[len(sealed)-aead.Overhead():]
+	)
+
+	// The encrypted key is left empty, since the content encryption key is
+	// agreed on directly.
+	return []byte(protected + ".." +
+		base64.RawURLEncoding.EncodeToString(iv) + "." +
+		base64.RawURLEncoding.EncodeToString(ciphertext) + "." +
+		base64.RawURLEncoding.EncodeToString(tag)), nil
+}
+
+// concatKDF derives a key from a shared secret with the Concat KDF described
+// in NIST SP 800-56A, using SHA-256 and empty party information, as required
+// by ECDH-ES in RFC 7518 section 4.6.2.
+func concatKDF(shared []byte, algorithm string, size int) []byte {
+	var (
+		key       = make([]byte, 0, size+sha256.Size)
+		otherInfo = make([]byte, 0, 4+len(algorithm)+4+4+4)
+	)
+
+	otherInfo = binary.BigEndian.AppendUint32(otherInfo, uint32(len(algorithm)))
+	otherInfo = append(otherInfo, algorithm...)
+	otherInfo = binary.BigEndian.AppendUint32(otherInfo, 0) // PartyUInfo
+	otherInfo = binary.BigEndian.AppendUint32(otherInfo, 0) // PartyVInfo
+	otherInfo = binary.BigEndian.AppendUint32(otherInfo, uint32(size*8))
+
+	for counter := uint32(1); len(key) < size; counter++ {
+		h := sha256.New()
+
+		_ = binary.Write(h, binary.BigEndian, counter)
+
+		h.Write(shared)
+		h.Write(otherInfo)
+
+		key = h.Sum(key)
+	}
+
+	return key[:size]
+}
@@ -0,0 +1,82 @@
+package envelope
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+)
+
+const (
+	// openPGPArmorPrefix is the first line of armored OpenPGP public keys.
+	openPGPArmorPrefix string = "-----BEGIN PGP PUBLIC KEY BLOCK-----"
+
+	// openPGPMessageType is the armor type of OpenPGP messages.
+	openPGPMessageType string = "PGP MESSAGE"
+)
+
+// openPGPRecipient is a set of OpenPGP public keys.
+type openPGPRecipient struct {
+	entities openpgp.EntityList
+}
+
+// parseOpenPGPRecipient parses one or more armored OpenPGP public keys.
+func parseOpenPGPRecipient(recipient string) (*openPGPRecipient, error) {
+	entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(recipient))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRecipient, err)
+	}
+
+	for _, entity := range entities {
+		if entity.PrivateKey != nil {
+			return nil, fmt.Errorf("%w: OpenPGP key must be a public key", ErrInvalidRecipient)
+		}
+
+		if _, ok := entity.EncryptionKey(time.Now()); !ok {
+			return nil, fmt.Errorf("%w: OpenPGP key %X has no valid encryption key", ErrInvalidRecipient, entity.PrimaryKey.Fingerprint)
+		}
+	}
+
+	return &openPGPRecipient{entities: entities}, nil
+}
+
+// ContentType implements Recipient.
+func (*openPGPRecipient) ContentType() string {
+	return xhttp.TextPlain
+}
+
+// Encrypt implements Recipient. It returns an armored OpenPGP message
+// encrypted to every key of the recipient.
+func (o *openPGPRecipient) Encrypt(plaintext []byte) ([]byte, error) {
+	var message bytes.Buffer
+
+	armored, err := armor.Encode(&message, openPGPMessageType, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to armor OpenPGP message: %w", err)
+	}
+
+	w, err := openpgp.Encrypt(armored, o.entities, nil, &openpgp.FileHints{IsBinary: true}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt OpenPGP message: %w", err)
+	}
+
+	if _, err := w.Write(plaintext); err != nil {
+		return nil, fmt.Errorf("failed to encrypt OpenPGP message: %w", err)
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encrypt OpenPGP message: %w", err)
+	}
+
+	if err := armored.Close(); err != nil {
+		return nil, fmt.Errorf("failed to armor OpenPGP message: %w", err)
+	}
+
+	message.WriteByte('\n')
+
+	return message.Bytes(), nil
+}
@@ -0,0 +1,226 @@
+// Package ipfilter implements allow and deny lists of networks, with optional
+// per-endpoint overrides, that can be reloaded from a file while the server is
+// running.
+package ipfilter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/proxy"
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+	"go.uber.org/zap"
+)
+
+// ErrInvalidRules is returned when a set of rules cannot be parsed.
+const ErrInvalidRules xerrors.Error = "invalid IP filter rules"
+
+// Rule lists the networks allowed and denied access to an endpoint, as CIDRs
+// or plain IP addresses. Denied networks take precedence over allowed ones, and
+// an empty allow list allows every network not denied.
+type Rule struct {
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
+}
+
+// Rules is the set of rules of a Filter.
+type Rules struct {
+	// Default is the rule applied to endpoints without a rule of their own.
+	Default *Rule `json:"default"`
+
+	// Endpoints maps endpoint paths, such as /v1/metrics/, to their rule,
+	// which replaces the default rule entirely.
+	Endpoints map[string]*Rule `json:"endpoints"`
+}
+
+// rule is a parsed Rule.
+type rule struct {
+	allow []netip.Prefix
+	deny  []netip.Prefix
+}
+
+// allowed reports whether the rule allows the given address.
+func (r *rule) allowed(addr netip.Addr) bool {
+	if proxy.Contains(r.deny, addr) {
+		return false
+	}
+
+	return len(r.allow) == 0 || proxy.Contains(r.allow, addr)
+}
+
+// policy is a parsed set of Rules.
+type policy struct {
+	defaultRule *rule
+	endpoints   map[string]*rule
+}
+
+// Filter decides which addresses may access which endpoints.
+type Filter struct {
+	modTime   time.Time
+	policy    atomic.Pointer[policy]
+	logger    *zap.Logger
+	done      chan struct{}
+	path      string
+	closeOnce sync.Once
+}
+
+// New returns a Filter applying the given rules.
+func New(rules *Rules) (*Filter, error) {
+	p, err := compile(rules)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &Filter{
+		done: make(chan struct{}),
+	}
+
+	f.policy.Store(p)
+
+	return f, nil
+}
+
+// Load returns a Filter applying the rules in the JSON file at the given path.
+// The file is checked for changes every interval and reloaded when it changes;
+// if the new rules are invalid, the error is logged and the previous rules are
+// kept. Call Close to stop watching the file.
+func Load(path string, interval time.Duration, logger *zap.Logger) (*Filter, error) {
+	f := &Filter{
+		logger: logger,
+		done:   make(chan struct{}),
+		path:   path,
+	}
+
+	if _, err := f.reload(); err != nil {
+		return nil, err
+	}
+
+	go f.watch(interval)
+
+	return f, nil
+}
+
+// Allowed reports whether the given address may access the given endpoint.
+func (f *Filter) Allowed(endpoint string, addr netip.Addr) bool {
+	p := f.policy.Load()
+
+	if r, ok := p.endpoints[endpoint]; ok {
+		return r.allowed(addr)
+	}
+
+	return p.defaultRule.allowed(addr)
+}
+
+// Close stops watching the rules file.
+func (f *Filter) Close() {
+	f.closeOnce.Do(func() {
+		close(f.done)
+	})
+}
+
+// watch periodically reloads the rules file.
+func (f *Filter) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-f.done:
+			return
+		case <-ticker.C:
+			reloaded, err := f.reload()
+			if err != nil {
+				f.logger.Error("failed to reload IP filter rules", zap.String("path", f.path), zap.Error(err))
+
+				continue
+			}
+
+			if reloaded {
+				f.logger.Info("reloaded IP filter rules", zap.String("path", f.path))
+			}
+		}
+	}
+}
+
+// reload reads the rules file again if it changed since it was last read, and
+// reports whether it did.
+func (f *Filter) reload() (bool, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return false, fmt.Errorf("%w: %w", ErrInvalidRules, err)
+	}
+
+	if info.ModTime().Equal(f.modTime) {
+		return false, nil
+	}
+
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return false, fmt.Errorf("%w: %w", ErrInvalidRules, err)
+	}
+
+	var rules Rules
+
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return false, fmt.Errorf("%w: %w", ErrInvalidRules, err)
+	}
+
+	p, err := compile(&rules)
+	if err != nil {
+		return false, err
+	}
+
+	f.policy.Store(p)
+	f.modTime = info.ModTime()
+
+	return true, nil
+}
+
+// compile parses a set of rules.
+func compile(rules *Rules) (*policy, error) {
+	defaultRule, err := compileRule(rules.Default)
+	if err != nil {
+		return nil, fmt.Errorf("%w: default: %w", ErrInvalidRules, err)
+	}
+
+	p := &policy{
+		defaultRule: defaultRule,
+		endpoints:   make(map[string]*rule, len(rules.Endpoints)),
+	}
+
+	for endpoint, r := range rules.Endpoints {
+		p.endpoints[endpoint], err = compileRule(r)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrInvalidRules, endpoint, err)
+		}
+	}
+
+	return p, nil
+}
+
+// compileRule parses a rule. A nil rule allows every address.
+func compileRule(r *Rule) (*rule, error) {
+	if r == nil {
+		return &rule{}, nil
+	}
+
+	allow, err := proxy.ParsePrefixes(r.Allow)
+	if err != nil {
+		return nil, fmt.Errorf("allow: %w", err)
+	}
+
+	deny, err := proxy.ParsePrefixes(r.Deny)
+	if err != nil {
+		return nil, fmt.Errorf("deny: %w", err)
+	}
+
+	return &rule{
+		allow: allow,
+		deny:  deny,
+	}, nil
+}
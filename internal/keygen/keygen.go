@@ -0,0 +1,95 @@
+// Package keygen generates key material for WireGuard and age.
+package keygen
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/bech32"
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+)
+
+const (
+	// ErrKeyGeneration is returned when a key cannot be generated.
+	ErrKeyGeneration xerrors.Error = "failed to generate key"
+)
+
+const (
+	// KeySize is the size in bytes of Curve25519 keys and WireGuard preshared
+	// keys.
+	KeySize int = 32
+
+	// KeyEntropy is the entropy in bits of age identities and WireGuard
+	// preshared keys.
+	KeyEntropy float64 = 256
+
+	// ClampedKeyEntropy is the entropy in bits of WireGuard private keys, as
+	// clamping fixes five of their bits.
+	ClampedKeyEntropy float64 = 251
+
+	// AgeIdentityHRP is the human-readable part of age identities.
+	AgeIdentityHRP string = "AGE-SECRET-KEY-"
+
+	// AgeRecipientHRP is the human-readable part of age recipients.
+	AgeRecipientHRP string = "age"
+)
+
+// WireGuard generates a Curve25519 private key and derives its public key,
+// returning both encoded as base64, the same format used by wg(8).
+func WireGuard() (privateKey, publicKey string, err error) {
+	key := make([]byte, KeySize)
+
+	if _, err = rand.Read(key); err != nil {
+		return "", "", fmt.Errorf("%w: %w", ErrKeyGeneration, err)
+	}
+
+	// Clamp the scalar the same way wg genkey does.
+	key[0] &= 248
+	key[31] = (key[31] & 127) | 64
+
+	private, err := ecdh.X25519().NewPrivateKey(key)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %w", ErrKeyGeneration, err)
+	}
+
+	privateKey = base64.StdEncoding.EncodeToString(private.Bytes())
+	publicKey = base64.StdEncoding.EncodeToString(private.PublicKey().Bytes())
+
+	return privateKey, publicKey, nil
+}
+
+// WireGuardPresharedKey generates a random symmetric key suitable for use as a
+// WireGuard preshared key, encoded as base64.
+func WireGuardPresharedKey() (string, error) {
+	key := make([]byte, KeySize)
+
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("%w: %w", ErrKeyGeneration, err)
+	}
+
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// Age generates an X25519 age identity and its recipient, encoded with Bech32
+// the same way age-keygen(1) does.
+func Age() (identity, recipient string, err error) {
+	private, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %w", ErrKeyGeneration, err)
+	}
+
+	identity, err = bech32.Encode(AgeIdentityHRP, private.Bytes())
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %w", ErrKeyGeneration, err)
+	}
+
+	recipient, err = bech32.Encode(AgeRecipientHRP, private.PublicKey().Bytes())
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %w", ErrKeyGeneration, err)
+	}
+
+	return strings.ToUpper(identity), recipient, nil
+}
@@ -0,0 +1,26 @@
+// Package logging carries request-scoped loggers through request contexts.
+package logging
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// loggerContextKey is the context key for the request-scoped logger.
+type loggerContextKey struct{}
+
+// WithLogger returns a copy of the context carrying the given logger.
+func WithLogger(ctx context.Context, logger *zap.Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, logger)
+}
+
+// FromContext returns the logger carried by the context, or fallback if there
+// is none.
+func FromContext(ctx context.Context, fallback *zap.Logger) *zap.Logger {
+	if logger, ok := ctx.Value(loggerContextKey{}).(*zap.Logger); ok {
+		return logger
+	}
+
+	return fallback
+}
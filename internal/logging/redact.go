@@ -0,0 +1,120 @@
+package logging
+
+import (
+	"reflect"
+	"strings"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/server/model"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// sensitiveKeys lists the field keys and query parameters whose values are
+// secret and never written to a log sink.
+var sensitiveKeys = map[string]struct{}{
+	"password":   {},
+	"passphrase": {},
+	"phrase":     {},
+	"secret":     {},
+	"token":      {},
+	"key":        {},
+	"master":     {},
+	"login":      {},
+}
+
+// sensitiveTypes lists the response models carrying secrets, which are
+// redacted as a whole when logged.
+var sensitiveTypes = map[reflect.Type]struct{}{
+	reflect.TypeOf(model.Secret("")):          {},
+	reflect.TypeOf(model.Password{}):          {},
+	reflect.TypeOf(model.Key{}):               {},
+	reflect.TypeOf(model.RecoveryCodes{}):     {},
+	reflect.TypeOf(model.Hash{}):              {},
+	reflect.TypeOf([]model.Hash(nil)):         {},
+	reflect.TypeOf([]model.Secret(nil)):       {},
+	reflect.TypeOf(map[string]model.Secret{}): {},
+}
+
+// Sensitive reports whether the value of a log field or query parameter with
+// the given name is secret.
+func Sensitive(name string) bool {
+	_, ok := sensitiveKeys[strings.ToLower(name)]
+
+	return ok
+}
+
+// Redact returns a copy of the logger that redacts secrets from every entry.
+func Redact(logger *zap.Logger) *zap.Logger {
+	return logger.WithOptions(zap.WrapCore(NewRedactingCore))
+}
+
+// redactingCore is a zapcore.Core that redacts secrets from fields before
+// passing them to the wrapped core.
+type redactingCore struct {
+	zapcore.Core
+}
+
+// NewRedactingCore wraps a zapcore.Core so that fields with sensitive keys,
+// Secret values, and response models carrying secrets are written as
+// model.Redacted.
+func NewRedactingCore(core zapcore.Core) zapcore.Core {
+	return &redactingCore{
+		Core: core,
+	}
+}
+
+// With implements the zapcore.Core interface.
+func (c *redactingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactingCore{
+		Core: c.Core.With(redact(fields)),
+	}
+}
+
+// Check implements the zapcore.Core interface.
+func (c *redactingCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+
+	return checked
+}
+
+// Write implements the zapcore.Core interface.
+func (c *redactingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(entry, redact(fields)) //nolint:wrapcheck // returned as is to zap
+}
+
+// redact returns a copy of the fields with secrets replaced by model.Redacted.
+func redact(fields []zapcore.Field) []zapcore.Field {
+	redacted := make([]zapcore.Field, len(fields))
+
+	for i, field := range fields {
+		redacted[i] = field
+
+		if field.Type == zapcore.SkipType {
+			continue
+		}
+
+		if Sensitive(field.Key) || sensitiveValue(field.Interface) {
+			redacted[i] = zap.String(field.Key, model.Redacted)
+		}
+	}
+
+	return redacted
+}
+
+// sensitiveValue reports whether a field value is, or points to, a secret.
+func sensitiveValue(v any) bool {
+	if v == nil {
+		return false
+	}
+
+	t := reflect.TypeOf(v)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	_, ok := sensitiveTypes[t]
+
+	return ok
+}
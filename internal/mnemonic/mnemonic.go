@@ -0,0 +1,307 @@
+// Package mnemonic implements BIP 39 mnemonic phrases, which encode random
+// entropy as a list of words with a built-in checksum.
+package mnemonic
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	_ "embed"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/secmem"
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// ErrInvalidEntropy is returned when the entropy size is not a multiple of
+	// 32 bits between 128 and 256 bits.
+	ErrInvalidEntropy xerrors.Error = "entropy must be a multiple of 32 bits between 128 and 256 bits"
+
+	// ErrUnknownLanguage is returned when a word list is requested for a
+	// language that is not supported.
+	ErrUnknownLanguage xerrors.Error = "unknown language"
+
+	// ErrInvalidWordCount is returned when a phrase does not have 12, 15, 18,
+	// 21, or 24 words.
+	ErrInvalidWordCount xerrors.Error = "mnemonic must have 12, 15, 18, 21, or 24 words"
+
+	// ErrUnknownWord is returned when a phrase contains a word that is not in
+	// the word list.
+	ErrUnknownWord xerrors.Error = "word is not in the word list"
+
+	// ErrInvalidChecksum is returned when the checksum of a phrase does not
+	// match its contents.
+	ErrInvalidChecksum xerrors.Error = "invalid checksum"
+)
+
+const (
+	// MinEntropyBits is the minimum number of bits of entropy in a mnemonic.
+	MinEntropyBits int = 128
+
+	// MaxEntropyBits is the maximum number of bits of entropy in a mnemonic.
+	MaxEntropyBits int = 256
+
+	// DefaultEntropyBits is the default number of bits of entropy in a
+	// mnemonic.
+	DefaultEntropyBits int = 128
+
+	// wordBits is the number of bits encoded by each word.
+	wordBits int = 11
+)
+
+// Language identifies a BIP 39 word list.
+type Language string
+
+// Languages with an official BIP 39 word list.
+const (
+	English            Language = "english"
+	Spanish            Language = "spanish"
+	French             Language = "french"
+	Italian            Language = "italian"
+	Czech              Language = "czech"
+	Japanese           Language = "japanese"
+	Korean             Language = "korean"
+	ChineseSimplified  Language = "chinese-simplified"
+	ChineseTraditional Language = "chinese-traditional"
+)
+
+// DefaultLanguage is the language used when none is given.
+const DefaultLanguage = English
+
+var (
+	//go:embed wordlists/english.txt
+	_english string
+
+	//go:embed wordlists/spanish.txt
+	_spanish string
+
+	//go:embed wordlists/french.txt
+	_french string
+
+	//go:embed wordlists/italian.txt
+	_italian string
+
+	//go:embed wordlists/czech.txt
+	_czech string
+
+	//go:embed wordlists/japanese.txt
+	_japanese string
+
+	//go:embed wordlists/korean.txt
+	_korean string
+
+	//go:embed wordlists/chinese_simplified.txt
+	_chineseSimplified string
+
+	//go:embed wordlists/chinese_traditional.txt
+	_chineseTraditional string
+)
+
+// _wordlists maps each language to its word list.
+var _wordlists = map[Language]*wordlist{ //nolint:gochecknoglobals // we want this initialized with the package
+	English:            newWordlist(_english),
+	Spanish:            newWordlist(_spanish),
+	French:             newWordlist(_french),
+	Italian:            newWordlist(_italian),
+	Czech:              newWordlist(_czech),
+	Japanese:           newWordlist(_japanese),
+	Korean:             newWordlist(_korean),
+	ChineseSimplified:  newWordlist(_chineseSimplified),
+	ChineseTraditional: newWordlist(_chineseTraditional),
+}
+
+// InvalidWordError is returned when a phrase contains a word that is not in
+// the word list.
+type InvalidWordError struct {
+	// Word is the unknown word.
+	Word string
+
+	// Position is the one-based position of the word in the phrase.
+	Position int
+}
+
+// Error implements the error interface.
+func (e *InvalidWordError) Error() string {
+	return "word " + strconv.Itoa(e.Position) + " (" + strconv.Quote(e.Word) + "): " + ErrUnknownWord.Error()
+}
+
+// Unwrap returns ErrUnknownWord, so the error can be matched with errors.Is.
+func (e *InvalidWordError) Unwrap() error {
+	return ErrUnknownWord
+}
+
+// wordlist holds the words of a language along with a reverse index.
+type wordlist struct {
+	index map[string]int
+	words []string
+}
+
+// newWordlist parses a newline-separated list of words.
+func newWordlist(data string) *wordlist {
+	words := strings.Split(strings.TrimSpace(data), "\n")
+	index := make(map[string]int, len(words))
+
+	for i, word := range words {
+		index[word] = i
+	}
+
+	return &wordlist{
+		index: index,
+		words: words,
+	}
+}
+
+// ParseLanguage returns the Language with the given name, or
+// ErrUnknownLanguage if there is no word list for it.
+func ParseLanguage(name string) (Language, error) {
+	language := Language(strings.ToLower(strings.TrimSpace(name)))
+
+	if _, ok := _wordlists[language]; !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownLanguage, name)
+	}
+
+	return language, nil
+}
+
+// Separator returns the string used to separate words in phrases of the given
+// language. Japanese uses an ideographic space; every other language uses a
+// regular space.
+func (l Language) Separator() string {
+	if l == Japanese {
+		return "　"
+	}
+
+	return " "
+}
+
+// Generate returns a new mnemonic with the given number of bits of entropy
+// using the word list for the given language.
+func Generate(bits int, language Language) (string, error) {
+	if bits < MinEntropyBits || bits > MaxEntropyBits || bits%32 != 0 {
+		return "", ErrInvalidEntropy
+	}
+
+	entropy := make([]byte, bits/8)
+
+	if _, err := rand.Read(entropy); err != nil {
+		return "", fmt.Errorf("failed to read entropy: %w", err)
+	}
+
+	return FromEntropy(entropy, language)
+}
+
+// FromEntropy encodes the given entropy as a mnemonic using the word list for
+// the given language.
+func FromEntropy(entropy []byte, language Language) (string, error) {
+	list, ok := _wordlists[language]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownLanguage, language)
+	}
+
+	bits := len(entropy) * 8
+
+	if bits < MinEntropyBits || bits > MaxEntropyBits || bits%32 != 0 {
+		return "", ErrInvalidEntropy
+	}
+
+	var (
+		checksum = sha256.Sum256(entropy)
+		data     = append(append(make([]byte, 0, len(entropy)+1), entropy...), checksum[0])
+		count    = (bits + bits/32) / wordBits
+		words    = make([]string, 0, count)
+	)
+
+	for i := 0; i < count; i++ {
+		words = append(words, list.words[readBits(data, i*wordBits, wordBits)])
+	}
+
+	return strings.Join(words, language.Separator()), nil
+}
+
+// Validate checks that a phrase only contains words from the word list of the
+// given language and that its checksum is valid. The phrase is read as bytes,
+// and the copies made to normalize it are wiped before returning.
+//
+// If a word is unknown, the returned error is an *InvalidWordError. Otherwise
+// it wraps ErrInvalidWordCount or ErrInvalidChecksum.
+func Validate(phrase []byte, language Language) error {
+	list, ok := _wordlists[language]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownLanguage, language)
+	}
+
+	// Word lists are stored in NFKD, as BIP 39 requires, so phrases typed with
+	// precomposed characters must be decomposed before they are looked up.
+	decomposed := norm.NFKD.Append(nil, phrase...)
+	defer secmem.Zero(decomposed)
+
+	lower := bytes.ToLower(decomposed)
+	defer secmem.Zero(lower)
+
+	words := bytes.Fields(lower)
+
+	if len(words) < 12 || len(words) > 24 || len(words)%3 != 0 {
+		return fmt.Errorf("%w: got %d", ErrInvalidWordCount, len(words))
+	}
+
+	var (
+		totalBits    = len(words) * wordBits
+		checksumBits = totalBits / 33
+		entropyBits  = totalBits - checksumBits
+		data         = make([]byte, (totalBits+7)/8)
+	)
+
+	defer secmem.Zero(data)
+
+	for i, word := range words {
+		idx, ok := list.index[string(word)]
+		if !ok {
+			return &InvalidWordError{
+				Word:     string(word),
+				Position: i + 1,
+			}
+		}
+
+		writeBits(data, i*wordBits, wordBits, idx)
+	}
+
+	var (
+		entropy  = data[:entropyBits/8]
+		checksum = sha256.Sum256(entropy)
+		want     = int(checksum[0]) >> (8 - checksumBits)
+		got      = readBits(data, entropyBits, checksumBits)
+	)
+
+	if want != got {
+		return ErrInvalidChecksum
+	}
+
+	return nil
+}
+
+// readBits reads n bits from data starting at the given bit offset.
+func readBits(data []byte, offset, n int) int {
+	var value int
+
+	for i := 0; i < n; i++ {
+		bit := offset + i
+		value = value<<1 | int(data[bit/8]>>(7-bit%8)&1)
+	}
+
+	return value
+}
+
+// writeBits writes the n lowest bits of value into data starting at the given
+// bit offset.
+func writeBits(data []byte, offset, n, value int) {
+	for i := 0; i < n; i++ {
+		if value>>(n-1-i)&1 == 1 {
+			bit := offset + i
+			data[bit/8] |= 1 << (7 - bit%8)
+		}
+	}
+}
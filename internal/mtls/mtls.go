@@ -0,0 +1,285 @@
+// Package mtls implements client certificate authentication for mutual TLS.
+package mtls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+)
+
+const (
+	// ErrInvalidVerifyMode is returned when parsing an unknown verify mode.
+	ErrInvalidVerifyMode xerrors.Error = "invalid client certificate verify mode"
+
+	// ErrInvalidIdentitySource is returned when parsing an unknown identity
+	// source.
+	ErrInvalidIdentitySource xerrors.Error = "invalid client identity source"
+
+	// ErrInvalidClientCA is returned when the client CA bundle contains no
+	// certificates or cannot be parsed.
+	ErrInvalidClientCA xerrors.Error = "invalid client CA bundle"
+
+	// ErrInvalidCRL is returned when a certificate revocation list cannot be
+	// parsed or is not signed by a certificate in the client CA bundle.
+	ErrInvalidCRL xerrors.Error = "invalid certificate revocation list"
+
+	// ErrCertificateRevoked is returned when a client presents a revoked
+	// certificate.
+	ErrCertificateRevoked xerrors.Error = "client certificate revoked"
+)
+
+// VerifyMode controls whether clients must present a certificate.
+type VerifyMode string
+
+const (
+	// VerifyOff disables client certificates.
+	VerifyOff VerifyMode = "off"
+
+	// VerifyOptional verifies client certificates when clients present one.
+	VerifyOptional VerifyMode = "optional"
+
+	// VerifyRequired rejects clients that do not present a valid certificate.
+	VerifyRequired VerifyMode = "required"
+)
+
+// ParseVerifyMode returns the VerifyMode matching the given string.
+func ParseVerifyMode(s string) (VerifyMode, error) {
+	switch mode := VerifyMode(s); mode {
+	case VerifyOff, VerifyOptional, VerifyRequired:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrInvalidVerifyMode, s)
+	}
+}
+
+// ClientAuth returns the tls.ClientAuthType matching the verify mode.
+func (m VerifyMode) ClientAuth() tls.ClientAuthType {
+	switch m {
+	case VerifyOptional:
+		return tls.VerifyClientCertIfGiven
+	case VerifyRequired:
+		return tls.RequireAndVerifyClientCert
+	default:
+		return tls.NoClientCert
+	}
+}
+
+// IdentitySource controls which part of a client certificate identifies the
+// client.
+type IdentitySource string
+
+const (
+	// IdentitySubject identifies clients by the common name of their
+	// certificate's subject, or the whole subject if it has no common name.
+	IdentitySubject IdentitySource = "subject"
+
+	// IdentitySAN identifies clients by the first subject alternative name of
+	// their certificate, in order of URI, DNS name, email address, and IP
+	// address.
+	IdentitySAN IdentitySource = "san"
+)
+
+// ParseIdentitySource returns the IdentitySource matching the given string.
+func ParseIdentitySource(s string) (IdentitySource, error) {
+	switch source := IdentitySource(s); source {
+	case IdentitySubject, IdentitySAN:
+		return source, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrInvalidIdentitySource, s)
+	}
+}
+
+// Identity returns the identity of the client holding the given certificate,
+// or an empty string if the certificate has no identity of the given kind.
+func Identity(cert *x509.Certificate, source IdentitySource) string {
+	if source == IdentitySAN {
+		switch {
+		case len(cert.URIs) > 0:
+			return cert.URIs[0].String()
+		case len(cert.DNSNames) > 0:
+			return cert.DNSNames[0]
+		case len(cert.EmailAddresses) > 0:
+			return cert.EmailAddresses[0]
+		case len(cert.IPAddresses) > 0:
+			return cert.IPAddresses[0].String()
+		default:
+			return ""
+		}
+	}
+
+	if cert.Subject.CommonName != "" {
+		return cert.Subject.CommonName
+	}
+
+	return cert.Subject.String()
+}
+
+// LoadCertificates reads a PEM bundle of CA certificates from the given path.
+func LoadCertificates(path string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidClientCA, err)
+	}
+
+	var certs []*x509.Certificate
+
+	for {
+		var block *pem.Block
+
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidClientCA, err)
+		}
+
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("%w: no certificates found in %s", ErrInvalidClientCA, path)
+	}
+
+	return certs, nil
+}
+
+// CertPool returns a certificate pool holding the given certificates.
+func CertPool(certs []*x509.Certificate) *x509.CertPool {
+	pool := x509.NewCertPool()
+
+	for _, cert := range certs {
+		pool.AddCert(cert)
+	}
+
+	return pool
+}
+
+// RevocationChecker rejects client certificates listed in a local certificate
+// revocation list. The list is reloaded whenever the file changes, so it can be
+// updated without restarting the server.
+type RevocationChecker struct {
+	modTime time.Time
+	revoked map[string]struct{}
+	path    string
+	issuers []*x509.Certificate
+	mu      sync.Mutex
+}
+
+// NewRevocationChecker returns a RevocationChecker reading the CRL at the given
+// path. The CRL, in PEM or DER format, must be signed by one of the given
+// issuers.
+func NewRevocationChecker(path string, issuers []*x509.Certificate) (*RevocationChecker, error) {
+	c := &RevocationChecker{
+		path:    path,
+		issuers: issuers,
+	}
+
+	if err := c.reload(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// VerifyConnection implements the tls.Config.VerifyConnection callback. It
+// rejects connections whose verified chains contain a revoked certificate.
+func (c *RevocationChecker) VerifyConnection(state tls.ConnectionState) error {
+	if len(state.VerifiedChains) == 0 {
+		return nil
+	}
+
+	if err := c.reload(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, chain := range state.VerifiedChains {
+		for _, cert := range chain {
+			if _, ok := c.revoked[revocationKey(cert.RawIssuer, cert.SerialNumber.String())]; ok {
+				return fmt.Errorf("%w: serial %s", ErrCertificateRevoked, cert.SerialNumber)
+			}
+		}
+	}
+
+	return nil
+}
+
+// reload reads the CRL again if the file changed since it was last read.
+func (c *RevocationChecker) reload() error {
+	info, err := os.Stat(c.path)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidCRL, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if info.ModTime().Equal(c.modTime) && c.revoked != nil {
+		return nil
+	}
+
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidCRL, err)
+	}
+
+	if block, _ := pem.Decode(data); block != nil {
+		data = block.Bytes
+	}
+
+	crl, err := x509.ParseRevocationList(data)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidCRL, err)
+	}
+
+	if err := c.checkSignature(crl); err != nil {
+		return err
+	}
+
+	revoked := make(map[string]struct{}, len(crl.RevokedCertificates))
+
+	for _, entry := range crl.RevokedCertificates {
+		revoked[revocationKey(crl.RawIssuer, entry.SerialNumber.String())] = struct{}{}
+	}
+
+	c.revoked = revoked
+	c.modTime = info.ModTime()
+
+	return nil
+}
+
+// checkSignature verifies that the CRL is signed by one of the issuers.
+func (c *RevocationChecker) checkSignature(crl *x509.RevocationList) error {
+	for _, issuer := range c.issuers {
+		if string(issuer.RawSubject) != string(crl.RawIssuer) {
+			continue
+		}
+
+		if err := crl.CheckSignatureFrom(issuer); err == nil {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: not signed by a client CA", ErrInvalidCRL)
+}
+
+// revocationKey returns the key identifying a certificate in the revocation
+// list.
+func revocationKey(rawIssuer []byte, serial string) string {
+	return string(rawIssuer) + ":" + serial
+}
@@ -0,0 +1,88 @@
+package passhash
+
+import (
+	"context"
+	"fmt"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+)
+
+// ErrCostExceeded is returned when the hashes requested at once would cost more
+// than the budget of a Limiter.
+const ErrCostExceeded xerrors.Error = "requested hashes are too expensive"
+
+// Limiter bounds the hashes computed for untrusted callers: the parameters of
+// each algorithm, the total cost of the hashes requested at once, and how many
+// hashes are computed concurrently.
+type Limiter struct {
+	limits  *Params
+	slots   chan struct{}
+	maxCost int
+}
+
+// NewLimiter returns a new Limiter accepting parameters up to limits, at most
+// maxCost worth of hashes at once, as estimated by Params.Cost, and computing
+// at most maxConcurrent hashes at the same time. A maxCost or maxConcurrent of
+// zero or less disables the corresponding limit.
+func NewLimiter(limits *Params, maxCost, maxConcurrent int) *Limiter {
+	l := &Limiter{
+		limits:  limits,
+		maxCost: maxCost,
+	}
+
+	if maxConcurrent > 0 {
+		l.slots = make(chan struct{}, maxConcurrent)
+	}
+
+	return l
+}
+
+// Validate checks that the parameters of every algorithm are within the
+// limits, and that hashing count secrets with each of them fits within the
+// cost budget.
+func (l *Limiter) Validate(params *Params, algorithms []Algorithm, count int) error {
+	var cost int
+
+	for _, algorithm := range algorithms {
+		if err := params.Validate(algorithm, l.limits); err != nil {
+			return err
+		}
+
+		cost += params.Cost(algorithm)
+	}
+
+	if l.maxCost > 0 && cost*count > l.maxCost {
+		return fmt.Errorf("%w: at most %d cost units may be requested at once, but %d were", ErrCostExceeded, l.maxCost, cost*count)
+	}
+
+	return nil
+}
+
+// Hash is like the package-level Hash, but waits for a slot with Acquire
+// first.
+func (l *Limiter) Hash(ctx context.Context, algorithm Algorithm, secret []byte, params *Params) (string, error) {
+	release, err := l.Acquire(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	return Hash(algorithm, secret, params)
+}
+
+// Acquire waits until fewer than the maximum number of hashes are being
+// computed, or until ctx is done, and takes a slot for the caller. Callers
+// doing expensive work other than Hash, such as deriving keys, use it to
+// share the same slots. The returned function releases the slot.
+func (l *Limiter) Acquire(ctx context.Context) (func(), error) {
+	if l.slots == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return func() { <-l.slots }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%w: %w", ErrHash, ctx.Err())
+	}
+}
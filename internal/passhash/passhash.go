@@ -0,0 +1,456 @@
+// Package passhash hashes generated secrets so callers can store a verifier
+// instead of the secret itself.
+package passhash
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"strings"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// ErrHash is returned when a secret cannot be hashed.
+	ErrHash xerrors.Error = "failed to hash secret"
+
+	// ErrUnknownAlgorithm is returned when a hashing algorithm is not
+	// supported.
+	ErrUnknownAlgorithm xerrors.Error = "unknown hashing algorithm"
+
+	// ErrInvalidParams is returned when hashing parameters are out of range.
+	ErrInvalidParams xerrors.Error = "invalid hashing parameters"
+
+	// ErrSecretTooLong is returned when a secret is longer than an algorithm
+	// can hash without truncating it.
+	ErrSecretTooLong xerrors.Error = "secret is too long for the hashing algorithm"
+)
+
+// Algorithm identifies a hashing algorithm.
+type Algorithm string
+
+const (
+	// Argon2id is the Argon2id key derivation function, encoded as a PHC
+	// string.
+	Argon2id Algorithm = "argon2id"
+
+	// Bcrypt is the bcrypt hashing function, encoded in Modular Crypt Format.
+	Bcrypt Algorithm = "bcrypt"
+
+	// Scrypt is the scrypt key derivation function, encoded as a PHC string
+	// in the format used by passlib.
+	Scrypt Algorithm = "scrypt"
+
+	// PBKDF2SHA256 is PBKDF2 with HMAC-SHA256, encoded as a PHC string.
+	PBKDF2SHA256 Algorithm = "pbkdf2-sha256"
+
+	// SHA512Crypt is the SHA-512 based crypt(3) scheme, as used in
+	// /etc/shadow.
+	SHA512Crypt Algorithm = "sha512-crypt"
+
+	// SCRAMSHA256 is a SCRAM-SHA-256 verifier in the format PostgreSQL stores
+	// in pg_authid.
+	SCRAMSHA256 Algorithm = "scram-sha-256"
+
+	// MySQLCachingSHA2 is a verifier for the caching_sha2_password
+	// authentication plugin of MySQL.
+	MySQLCachingSHA2 Algorithm = "mysql-caching-sha2"
+)
+
+// Algorithms lists every supported algorithm.
+func Algorithms() []Algorithm {
+	return []Algorithm{
+		Argon2id,
+		Bcrypt,
+		Scrypt,
+		PBKDF2SHA256,
+		SHA512Crypt,
+		SCRAMSHA256,
+		MySQLCachingSHA2,
+	}
+}
+
+const (
+	// DefaultArgon2Time is the default number of Argon2id passes.
+	DefaultArgon2Time uint32 = 2
+
+	// DefaultArgon2Memory is the default amount of Argon2id memory in KiB.
+	DefaultArgon2Memory uint32 = 19 * 1024
+
+	// DefaultArgon2Threads is the default Argon2id degree of parallelism.
+	DefaultArgon2Threads uint8 = 1
+
+	// DefaultBcryptCost is the default bcrypt cost.
+	DefaultBcryptCost int = 12
+
+	// DefaultScryptLogN is the default base 2 logarithm of the scrypt CPU and
+	// memory cost.
+	DefaultScryptLogN int = 15
+
+	// DefaultScryptR is the default scrypt block size.
+	DefaultScryptR int = 8
+
+	// DefaultScryptP is the default scrypt parallelization.
+	DefaultScryptP int = 1
+
+	// DefaultPBKDF2Iterations is the default number of PBKDF2 iterations.
+	DefaultPBKDF2Iterations int = 600000
+
+	// DefaultSHACryptRounds is the default number of SHA-512 crypt rounds.
+	DefaultSHACryptRounds int = 656000
+
+	// DefaultSCRAMIterations is the default number of SCRAM-SHA-256
+	// iterations, matching PostgreSQL.
+	DefaultSCRAMIterations int = 4096
+
+	// DefaultMySQLIterations is the default number of caching_sha2_password
+	// iterations, in thousands of rounds, matching MySQL.
+	DefaultMySQLIterations int = 5
+
+	// SaltSize is the size in bytes of the salts generated by the package.
+	SaltSize int = 16
+
+	// argon2KeySize is the size in bytes of Argon2id hashes.
+	argon2KeySize uint32 = 32
+
+	// keySize is the size in bytes of scrypt and PBKDF2 hashes.
+	keySize int = 32
+
+	// bcryptMaxSecretSize is the number of bytes past which bcrypt silently
+	// truncates its input.
+	bcryptMaxSecretSize int = 72
+)
+
+// Rough costs of each algorithm, used by Params.Cost, measured with the default
+// parameters: about 32ms for Argon2id, 280ms for bcrypt and SHA-512 crypt,
+// 80ms for scrypt, 115ms for PBKDF2, and 0.15ms per thousand rounds of
+// caching_sha2_password.
+const (
+	argon2CostDivisor   float64 = 1216
+	bcryptCostFactor    float64 = 280.0 / 4096
+	scryptCostDivisor   float64 = 3277
+	pbkdf2CostDivisor   float64 = 5200
+	shaCryptCostDivisor float64 = 2340
+	mysqlCostFactor     float64 = 0.15
+)
+
+const (
+	// MinIterations is the minimum number of iterations or rounds accepted by
+	// PBKDF2, SHA-512 crypt, and SCRAM-SHA-256.
+	MinIterations int = 1000
+
+	// MinMySQLIterations is the minimum number of caching_sha2_password
+	// iterations accepted by MySQL.
+	MinMySQLIterations int = 5
+
+	// MaxMySQLIterations is the maximum number of caching_sha2_password
+	// iterations that fit in its encoding.
+	MaxMySQLIterations int = 0xfff
+)
+
+// Params holds the cost parameters of every supported algorithm.
+type Params struct {
+	// Argon2Time is the number of Argon2id passes.
+	Argon2Time uint32
+
+	// Argon2Memory is the amount of Argon2id memory in KiB.
+	Argon2Memory uint32
+
+	// Argon2Threads is the Argon2id degree of parallelism.
+	Argon2Threads uint8
+
+	// BcryptCost is the bcrypt cost.
+	BcryptCost int
+
+	// ScryptLogN is the base 2 logarithm of the scrypt CPU and memory cost.
+	ScryptLogN int
+
+	// ScryptR is the scrypt block size.
+	ScryptR int
+
+	// ScryptP is the scrypt parallelization.
+	ScryptP int
+
+	// PBKDF2Iterations is the number of PBKDF2 iterations.
+	PBKDF2Iterations int
+
+	// SHACryptRounds is the number of SHA-512 crypt rounds.
+	SHACryptRounds int
+
+	// SCRAMIterations is the number of SCRAM-SHA-256 iterations.
+	SCRAMIterations int
+
+	// MySQLIterations is the number of caching_sha2_password iterations, in
+	// thousands of rounds.
+	MySQLIterations int
+}
+
+// DefaultParams returns the default parameters for every algorithm.
+func DefaultParams() *Params {
+	return &Params{
+		Argon2Time:       DefaultArgon2Time,
+		Argon2Memory:     DefaultArgon2Memory,
+		Argon2Threads:    DefaultArgon2Threads,
+		BcryptCost:       DefaultBcryptCost,
+		ScryptLogN:       DefaultScryptLogN,
+		ScryptR:          DefaultScryptR,
+		ScryptP:          DefaultScryptP,
+		PBKDF2Iterations: DefaultPBKDF2Iterations,
+		SHACryptRounds:   DefaultSHACryptRounds,
+		SCRAMIterations:  DefaultSCRAMIterations,
+		MySQLIterations:  DefaultMySQLIterations,
+	}
+}
+
+// Validate checks that the parameters of the given algorithm are within the
+// range the algorithm accepts and do not exceed the given limits. A nil limits
+// disables the upper bounds.
+func (p *Params) Validate(algorithm Algorithm, limits *Params) error {
+	if limits == nil {
+		limits = &Params{}
+	}
+
+	switch algorithm {
+	case Argon2id:
+		if p.Argon2Time < 1 || exceeds(int(p.Argon2Time), int(limits.Argon2Time)) {
+			return invalidParam("argon2 time", 1, int(limits.Argon2Time))
+		}
+
+		if p.Argon2Threads < 1 || exceeds(int(p.Argon2Threads), int(limits.Argon2Threads)) {
+			return invalidParam("argon2 threads", 1, int(limits.Argon2Threads))
+		}
+
+		if p.Argon2Memory < 8*uint32(p.Argon2Threads) || exceeds(int(p.Argon2Memory), int(limits.Argon2Memory)) {
+			return invalidParam("argon2 memory", 8*int(p.Argon2Threads), int(limits.Argon2Memory))
+		}
+	case Bcrypt:
+		if p.BcryptCost < bcrypt.MinCost || p.BcryptCost > bcrypt.MaxCost || exceeds(p.BcryptCost, limits.BcryptCost) {
+			return invalidParam("bcrypt cost", bcrypt.MinCost, minLimit(bcrypt.MaxCost, limits.BcryptCost))
+		}
+	case Scrypt:
+		if p.ScryptLogN < 1 || p.ScryptLogN > 62 || exceeds(p.ScryptLogN, limits.ScryptLogN) {
+			return invalidParam("scrypt log N", 1, minLimit(62, limits.ScryptLogN))
+		}
+
+		if p.ScryptR < 1 || exceeds(p.ScryptR, limits.ScryptR) {
+			return invalidParam("scrypt r", 1, limits.ScryptR)
+		}
+
+		if p.ScryptP < 1 || exceeds(p.ScryptP, limits.ScryptP) || p.ScryptR*p.ScryptP >= 1<<30 {
+			return invalidParam("scrypt p", 1, limits.ScryptP)
+		}
+	case PBKDF2SHA256:
+		if p.PBKDF2Iterations < MinIterations || exceeds(p.PBKDF2Iterations, limits.PBKDF2Iterations) {
+			return invalidParam("pbkdf2 iterations", MinIterations, limits.PBKDF2Iterations)
+		}
+	case SHA512Crypt:
+		if p.SHACryptRounds < MinIterations || p.SHACryptRounds > shaCryptMaxRounds || exceeds(p.SHACryptRounds, limits.SHACryptRounds) {
+			return invalidParam("sha512-crypt rounds", MinIterations, minLimit(shaCryptMaxRounds, limits.SHACryptRounds))
+		}
+	case SCRAMSHA256:
+		if p.SCRAMIterations < MinIterations || exceeds(p.SCRAMIterations, limits.SCRAMIterations) {
+			return invalidParam("scram iterations", MinIterations, limits.SCRAMIterations)
+		}
+	case MySQLCachingSHA2:
+		if p.MySQLIterations < MinMySQLIterations || p.MySQLIterations > MaxMySQLIterations || exceeds(p.MySQLIterations, limits.MySQLIterations) {
+			return invalidParam("mysql iterations", MinMySQLIterations, minLimit(MaxMySQLIterations, limits.MySQLIterations))
+		}
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownAlgorithm, algorithm)
+	}
+
+	return nil
+}
+
+// Cost returns an estimate of the work needed to hash a secret with the given
+// algorithm and parameters, in milliseconds of CPU time on a typical server
+// core. It is meant for comparing and budgeting hashes across algorithms, not
+// for predicting how long a hash takes.
+func (p *Params) Cost(algorithm Algorithm) int {
+	var cost float64
+
+	switch algorithm {
+	case Argon2id:
+		cost = float64(p.Argon2Time) * float64(p.Argon2Memory) / argon2CostDivisor
+	case Bcrypt:
+		cost = math.Ldexp(bcryptCostFactor, p.BcryptCost)
+	case Scrypt:
+		cost = math.Ldexp(float64(p.ScryptR)*float64(p.ScryptP), p.ScryptLogN) / scryptCostDivisor
+	case PBKDF2SHA256:
+		cost = float64(p.PBKDF2Iterations) / pbkdf2CostDivisor
+	case SHA512Crypt:
+		cost = float64(p.SHACryptRounds) / shaCryptCostDivisor
+	case SCRAMSHA256:
+		// SCRAM-SHA-256 derives its keys with PBKDF2-HMAC-SHA256.
+		cost = float64(p.SCRAMIterations) / pbkdf2CostDivisor
+	case MySQLCachingSHA2:
+		cost = float64(p.MySQLIterations) * mysqlCostFactor
+	}
+
+	return int(math.Ceil(cost))
+}
+
+// ParseAlgorithm returns the Algorithm with the given name.
+func ParseAlgorithm(name string) (Algorithm, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	for _, a := range Algorithms() {
+		if Algorithm(name) == a {
+			return a, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: %q", ErrUnknownAlgorithm, name)
+}
+
+// Hash hashes secret with the given algorithm and parameters. If params is
+// nil, the default parameters are used. The parameters are not checked
+// against any limits; call Params.Validate first for untrusted input.
+func Hash(algorithm Algorithm, secret []byte, params *Params) (string, error) {
+	if params == nil {
+		params = DefaultParams()
+	}
+
+	if err := params.Validate(algorithm, nil); err != nil {
+		return "", err
+	}
+
+	switch algorithm {
+	case Argon2id:
+		return HashArgon2id(secret, params.Argon2Time, params.Argon2Memory, params.Argon2Threads)
+	case Bcrypt:
+		return HashBcrypt(secret, params.BcryptCost)
+	case Scrypt:
+		return HashScrypt(secret, params.ScryptLogN, params.ScryptR, params.ScryptP)
+	case PBKDF2SHA256:
+		return HashPBKDF2SHA256(secret, params.PBKDF2Iterations)
+	case SHA512Crypt:
+		return HashSHA512Crypt(secret, params.SHACryptRounds)
+	case SCRAMSHA256:
+		return HashSCRAMSHA256(secret, params.SCRAMIterations)
+	case MySQLCachingSHA2:
+		return HashMySQLCachingSHA2(secret, params.MySQLIterations)
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnknownAlgorithm, algorithm)
+	}
+}
+
+// HashArgon2id hashes secret with Argon2id and returns it as a PHC string.
+func HashArgon2id(secret []byte, time, memory uint32, threads uint8) (string, error) {
+	salt, err := newSalt(SaltSize)
+	if err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey(secret, salt, time, memory, threads, argon2KeySize)
+
+	return fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		memory,
+		time,
+		threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// HashBcrypt hashes secret with bcrypt using the given cost. Secrets longer
+// than 72 bytes are rejected instead of being silently truncated.
+func HashBcrypt(secret []byte, cost int) (string, error) {
+	if len(secret) > bcryptMaxSecretSize {
+		return "", fmt.Errorf("%w: bcrypt accepts at most %d bytes", ErrSecretTooLong, bcryptMaxSecretSize)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword(secret, cost)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrHash, err)
+	}
+
+	return string(hash), nil
+}
+
+// HashScrypt hashes secret with scrypt and returns it as a PHC string in the
+// format used by passlib.
+func HashScrypt(secret []byte, logN, r, p int) (string, error) {
+	salt, err := newSalt(SaltSize)
+	if err != nil {
+		return "", err
+	}
+
+	key, err := scrypt.Key(secret, salt, 1<<logN, r, p, keySize)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrHash, err)
+	}
+
+	return fmt.Sprintf(
+		"$scrypt$ln=%d,r=%d,p=%d$%s$%s",
+		logN,
+		r,
+		p,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// HashPBKDF2SHA256 hashes secret with PBKDF2-HMAC-SHA256 and returns it as a
+// PHC string.
+func HashPBKDF2SHA256(secret []byte, iterations int) (string, error) {
+	salt, err := newSalt(SaltSize)
+	if err != nil {
+		return "", err
+	}
+
+	key := pbkdf2.Key(secret, salt, iterations, keySize, sha256.New)
+
+	return fmt.Sprintf(
+		"$pbkdf2-sha256$i=%d,l=%d$%s$%s",
+		iterations,
+		keySize,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// newSalt returns size random bytes.
+func newSalt(size int) ([]byte, error) {
+	salt := make([]byte, size)
+
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrHash, err)
+	}
+
+	return salt, nil
+}
+
+// exceeds reports whether value is above limit. A limit of zero or less means
+// there is no limit.
+func exceeds(value, limit int) bool {
+	return limit > 0 && value > limit
+}
+
+// invalidParam returns an error describing the accepted range of a parameter.
+func invalidParam(name string, lower, upper int) error {
+	if upper <= 0 {
+		return fmt.Errorf("%w: %s must be at least %d", ErrInvalidParams, name, lower)
+	}
+
+	return fmt.Errorf("%w: %s must be between %d and %d", ErrInvalidParams, name, lower, upper)
+}
+
+// minLimit returns the smallest positive of a and b, treating zero or less as
+// no limit.
+func minLimit(a, b int) int {
+	if b <= 0 || a < b {
+		return a
+	}
+
+	return b
+}
@@ -0,0 +1,249 @@
+package passhash
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// shaCryptAlphabet is the alphabet used by crypt(3) for salts and hashes.
+	shaCryptAlphabet string = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+	// shaCryptMaxRounds is the maximum number of SHA crypt rounds.
+	shaCryptMaxRounds int = 999999999
+
+	// shaCryptSaltSize is the size of the salt used by SHA-512 crypt.
+	shaCryptSaltSize int = 16
+
+	// mysqlSaltSize is the size of the salt used by caching_sha2_password.
+	mysqlSaltSize int = 20
+
+	// mysqlRoundsMultiplier converts caching_sha2_password iterations into
+	// SHA-256 crypt rounds.
+	mysqlRoundsMultiplier int = 1000
+)
+
+// shaCrypt512Order is the order in which the bytes of the final SHA-512 crypt
+// digest are encoded.
+var shaCrypt512Order = [...]int{ //nolint:gochecknoglobals // arrays cannot be constants
+	0, 21, 42, 22, 43, 1, 44, 2, 23, 3, 24, 45, 25, 46, 4, 47, 5, 26, 6, 27, 48,
+	28, 49, 7, 50, 8, 29, 9, 30, 51, 31, 52, 10, 53, 11, 32, 12, 33, 54, 34, 55,
+	13, 56, 14, 35, 15, 36, 57, 37, 58, 16, 59, 17, 38, 18, 39, 60, 40, 61, 19,
+	62, 20, 41,
+}
+
+// shaCrypt256Order is the order in which the bytes of the final SHA-256 crypt
+// digest are encoded.
+var shaCrypt256Order = [...]int{ //nolint:gochecknoglobals // arrays cannot be constants
+	0, 10, 20, 21, 1, 11, 12, 22, 2, 3, 13, 23, 24, 4, 14, 15, 25, 5, 6, 16, 26,
+	27, 7, 17, 18, 28, 8, 9, 19, 29,
+}
+
+// HashSHA512Crypt hashes secret with the SHA-512 crypt(3) scheme and returns it
+// in the format used by /etc/shadow.
+func HashSHA512Crypt(secret []byte, rounds int) (string, error) {
+	salt, err := newCryptSalt(shaCryptSaltSize)
+	if err != nil {
+		return "", err
+	}
+
+	digest := shaCrypt(sha512.New, secret, salt, rounds)
+
+	var builder strings.Builder
+
+	builder.WriteString("$6$rounds=")
+	builder.WriteString(strconv.Itoa(rounds))
+	builder.WriteString("$")
+	builder.Write(salt)
+	builder.WriteString("$")
+
+	for i := 0; i+2 < len(shaCrypt512Order); i += 3 {
+		encodeCrypt(&builder, digest[shaCrypt512Order[i]], digest[shaCrypt512Order[i+1]], digest[shaCrypt512Order[i+2]], 4)
+	}
+
+	encodeCrypt(&builder, 0, 0, digest[63], 2)
+
+	return builder.String(), nil
+}
+
+// HashMySQLCachingSHA2 hashes secret the way the caching_sha2_password plugin
+// of MySQL does, using SHA-256 crypt with iterations thousand rounds. The salt
+// only uses printable characters, so the result can be used as-is in an
+// IDENTIFIED WITH caching_sha2_password AS clause.
+func HashMySQLCachingSHA2(secret []byte, iterations int) (string, error) {
+	salt, err := newCryptSalt(mysqlSaltSize)
+	if err != nil {
+		return "", err
+	}
+
+	digest := shaCrypt(sha256.New, secret, salt, iterations*mysqlRoundsMultiplier)
+
+	var builder strings.Builder
+
+	fmt.Fprintf(&builder, "$A$%03X$", iterations)
+	builder.Write(salt)
+
+	for i := 0; i+2 < len(shaCrypt256Order); i += 3 {
+		encodeCrypt(&builder, digest[shaCrypt256Order[i]], digest[shaCrypt256Order[i+1]], digest[shaCrypt256Order[i+2]], 4)
+	}
+
+	encodeCrypt(&builder, 0, digest[31], digest[30], 3)
+
+	return builder.String(), nil
+}
+
+// HashSCRAMSHA256 computes a SCRAM-SHA-256 verifier for secret in the format
+// PostgreSQL stores in pg_authid.
+//
+// PostgreSQL runs passwords through SASLprep first; generated secrets are
+// already in normalized form, so this step is skipped.
+func HashSCRAMSHA256(secret []byte, iterations int) (string, error) {
+	salt, err := newSalt(SaltSize)
+	if err != nil {
+		return "", err
+	}
+
+	var (
+		salted    = pbkdf2.Key(secret, salt, iterations, sha256.Size, sha256.New)
+		clientKey = hmacSHA256(salted, []byte("Client Key"))
+		serverKey = hmacSHA256(salted, []byte("Server Key"))
+		storedKey = sha256.Sum256(clientKey)
+	)
+
+	return fmt.Sprintf(
+		"SCRAM-SHA-256$%d:%s$%s:%s",
+		iterations,
+		base64.StdEncoding.EncodeToString(salt),
+		base64.StdEncoding.EncodeToString(storedKey[:]),
+		base64.StdEncoding.EncodeToString(serverKey),
+	), nil
+}
+
+// shaCrypt implements the SHA crypt algorithm by Ulrich Drepper, returning the
+// final digest before encoding.
+func shaCrypt(newHash func() hash.Hash, secret, salt []byte, rounds int) []byte {
+	h := newHash()
+	h.Write(secret)
+	h.Write(salt)
+	h.Write(secret)
+	alternate := h.Sum(nil)
+
+	h.Reset()
+	h.Write(secret)
+	h.Write(salt)
+	h.Write(repeat(alternate, len(secret)))
+
+	for i := len(secret); i > 0; i >>= 1 {
+		if i&1 != 0 {
+			h.Write(alternate)
+		} else {
+			h.Write(secret)
+		}
+	}
+
+	digest := h.Sum(nil)
+
+	h.Reset()
+
+	for i := 0; i < len(secret); i++ {
+		h.Write(secret)
+	}
+
+	p := repeat(h.Sum(nil), len(secret))
+
+	h.Reset()
+
+	for i := 0; i < 16+int(digest[0]); i++ {
+		h.Write(salt)
+	}
+
+	s := repeat(h.Sum(nil), len(salt))
+
+	for i := 0; i < rounds; i++ {
+		h.Reset()
+
+		if i&1 != 0 {
+			h.Write(p)
+		} else {
+			h.Write(digest)
+		}
+
+		if i%3 != 0 {
+			h.Write(s)
+		}
+
+		if i%7 != 0 {
+			h.Write(p)
+		}
+
+		if i&1 != 0 {
+			h.Write(digest)
+		} else {
+			h.Write(p)
+		}
+
+		digest = h.Sum(digest[:0])
+	}
+
+	return digest
+}
+
+// repeat returns the first n bytes of b repeated as many times as needed.
+func repeat(b []byte, n int) []byte {
+	out := make([]byte, n)
+
+	for i := 0; i < n; i += len(b) {
+		copy(out[i:], b)
+	}
+
+	return out
+}
+
+// encodeCrypt writes n characters encoding the 24-bit group formed by b2, b1,
+// and b0 using the crypt(3) alphabet.
+func encodeCrypt(builder *strings.Builder, b2, b1, b0 byte, n int) {
+	w := uint(b2)<<16 | uint(b1)<<8 | uint(b0)
+
+	for i := 0; i < n; i++ {
+		builder.WriteByte(shaCryptAlphabet[w&0x3f])
+		w >>= 6
+	}
+}
+
+// newCryptSalt returns a random salt of the given size using the crypt(3)
+// alphabet.
+func newCryptSalt(size int) ([]byte, error) {
+	var (
+		salt = make([]byte, size)
+		base = big.NewInt(int64(len(shaCryptAlphabet)))
+	)
+
+	for i := range salt {
+		idx, err := rand.Int(rand.Reader, base)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrHash, err)
+		}
+
+		salt[i] = shaCryptAlphabet[idx.Int64()]
+	}
+
+	return salt, nil
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of message using key.
+func hmacSHA256(key, message []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(message)
+
+	return mac.Sum(nil)
+}
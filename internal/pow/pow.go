@@ -0,0 +1,357 @@
+// Package pow implements a hashcash-style proof-of-work gate. The server
+// issues signed, expiring challenges, and clients prove they spent CPU time by
+// finding a counter such that the SHA-256 hash of the challenge and counter
+// starts with a given number of zero bits.
+package pow
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+)
+
+const (
+	// ErrMissingSolution is returned when a request carries no solution.
+	ErrMissingSolution xerrors.Error = "missing proof of work"
+
+	// ErrMalformedSolution is returned when a solution cannot be parsed.
+	ErrMalformedSolution xerrors.Error = "malformed proof of work"
+
+	// ErrInvalidSignature is returned when a challenge was not issued by the
+	// server.
+	ErrInvalidSignature xerrors.Error = "invalid challenge signature"
+
+	// ErrChallengeExpired is returned when a challenge has expired.
+	ErrChallengeExpired xerrors.Error = "challenge expired"
+
+	// ErrChallengeReused is returned when a challenge was already solved.
+	ErrChallengeReused xerrors.Error = "challenge already used"
+
+	// ErrInsufficientWork is returned when a solution does not have enough
+	// leading zero bits.
+	ErrInsufficientWork xerrors.Error = "insufficient proof of work"
+
+	// ErrInvalidOptions is returned when the gate options are invalid.
+	ErrInvalidOptions xerrors.Error = "invalid proof of work options"
+)
+
+const (
+	// MaxDifficulty is the highest difficulty a gate can require.
+	MaxDifficulty int = 32
+
+	// nonceSize is the size in bytes of the random part of a challenge.
+	nonceSize int = 16
+
+	// payloadSize is the size in bytes of a challenge before its signature.
+	payloadSize int = nonceSize + 8 + 1
+
+	// secretSize is the size in bytes of generated signing secrets.
+	secretSize int = 32
+)
+
+// Options configures a Gate.
+type Options struct {
+	// Secret is the key challenges are signed with. Instances sharing a
+	// secret accept each other's challenges. A random secret is generated if
+	// empty.
+	Secret []byte
+
+	// TTL is how long a challenge is valid for.
+	TTL time.Duration
+
+	// Window is the period over which request rates are measured.
+	Window time.Duration
+
+	// Difficulty is the number of leading zero bits required under normal
+	// load.
+	Difficulty int
+
+	// MaxDifficulty is the highest difficulty required under load.
+	MaxDifficulty int
+
+	// Threshold is the number of requests per window above which the
+	// difficulty is raised by one bit every time the rate doubles.
+	Threshold int
+}
+
+// Challenge is a challenge issued to a client.
+type Challenge struct {
+	// ExpiresAt is the time after which the challenge is rejected.
+	ExpiresAt time.Time
+
+	// Token is the signed challenge.
+	Token string
+
+	// Difficulty is the number of leading zero bits the solution must have.
+	Difficulty int
+}
+
+// Gate issues challenges and verifies their solutions.
+type Gate struct {
+	used        map[string]time.Time
+	done        chan struct{}
+	now         func() time.Time
+	windowStart time.Time
+	secret      []byte
+	opts        Options
+	count       int
+	lastCount   int
+	mu          sync.Mutex
+	closeOnce   sync.Once
+}
+
+// New returns a new Gate and starts evicting spent challenges in the
+// background. Call Close to stop it.
+func New(opts *Options) (*Gate, error) {
+	if opts.Difficulty < 1 || opts.Difficulty > MaxDifficulty || opts.MaxDifficulty < opts.Difficulty || opts.MaxDifficulty > MaxDifficulty {
+		return nil, fmt.Errorf("%w: difficulty must be between 1 and %d", ErrInvalidOptions, MaxDifficulty)
+	}
+
+	if opts.TTL <= 0 || opts.Window <= 0 || opts.Threshold < 1 {
+		return nil, fmt.Errorf("%w: TTL, window, and threshold must be positive", ErrInvalidOptions)
+	}
+
+	secret := opts.Secret
+	if len(secret) == 0 {
+		secret = make([]byte, secretSize)
+
+		if _, err := rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("failed to generate challenge secret: %w", err)
+		}
+	}
+
+	g := &Gate{
+		used:   make(map[string]time.Time),
+		done:   make(chan struct{}),
+		now:    time.Now,
+		secret: secret,
+		opts:   *opts,
+	}
+
+	g.windowStart = g.now()
+
+	go g.evict()
+
+	return g, nil
+}
+
+// Issue returns a new challenge at the current difficulty.
+func (g *Gate) Issue() (*Challenge, error) {
+	var (
+		difficulty = g.Difficulty()
+		expiresAt  = g.now().Add(g.opts.TTL).Truncate(time.Second)
+		payload    = make([]byte, payloadSize)
+	)
+
+	if _, err := rand.Read(payload[:nonceSize]); err != nil {
+		return nil, fmt.Errorf("failed to generate challenge: %w", err)
+	}
+
+	binary.BigEndian.PutUint64(payload[nonceSize:], uint64(expiresAt.Unix()))
+	payload[payloadSize-1] = byte(difficulty)
+
+	token := base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(g.sign(payload))
+
+	return &Challenge{
+		ExpiresAt:  expiresAt,
+		Token:      token,
+		Difficulty: difficulty,
+	}, nil
+}
+
+// Verify checks a solution of the form "<token>:<counter>" and marks its
+// challenge as used.
+func (g *Gate) Verify(solution string) error {
+	if solution == "" {
+		return ErrMissingSolution
+	}
+
+	token, counter, ok := strings.Cut(solution, ":")
+	if !ok || counter == "" {
+		return ErrMalformedSolution
+	}
+
+	if _, err := strconv.ParseUint(counter, 10, 64); err != nil {
+		return ErrMalformedSolution
+	}
+
+	encodedPayload, encodedMAC, ok := strings.Cut(token, ".")
+	if !ok {
+		return ErrMalformedSolution
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil || len(payload) != payloadSize {
+		return ErrMalformedSolution
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(encodedMAC)
+	if err != nil {
+		return ErrMalformedSolution
+	}
+
+	if !hmac.Equal(mac, g.sign(payload)) {
+		return ErrInvalidSignature
+	}
+
+	var (
+		expiresAt  = time.Unix(int64(binary.BigEndian.Uint64(payload[nonceSize:])), 0)
+		difficulty = int(payload[payloadSize-1])
+	)
+
+	if g.now().After(expiresAt) {
+		return ErrChallengeExpired
+	}
+
+	if LeadingZeroBits(token, counter) < difficulty {
+		return ErrInsufficientWork
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	nonce := string(payload[:nonceSize])
+
+	if _, ok := g.used[nonce]; ok {
+		return ErrChallengeReused
+	}
+
+	g.used[nonce] = expiresAt
+
+	return nil
+}
+
+// Record counts a request towards the rate used to adjust the difficulty.
+func (g *Gate) Record() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.rotate()
+	g.count++
+}
+
+// Difficulty returns the difficulty for new challenges. It is the configured
+// difficulty, raised by one bit for every doubling of the request rate above
+// the threshold, based on the busier of the current and previous windows.
+func (g *Gate) Difficulty() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.rotate()
+
+	rate := g.count
+	if g.lastCount > rate {
+		rate = g.lastCount
+	}
+
+	difficulty := g.opts.Difficulty
+
+	if rate > g.opts.Threshold {
+		difficulty += int(math.Ceil(math.Log2(float64(rate) / float64(g.opts.Threshold))))
+	}
+
+	if difficulty > g.opts.MaxDifficulty {
+		difficulty = g.opts.MaxDifficulty
+	}
+
+	return difficulty
+}
+
+// Close stops the eviction of spent challenges.
+func (g *Gate) Close() {
+	g.closeOnce.Do(func() {
+		close(g.done)
+	})
+}
+
+// rotate starts a new rate window if the current one is over. It must be
+// called with the lock held.
+func (g *Gate) rotate() {
+	now := g.now()
+
+	if elapsed := now.Sub(g.windowStart); elapsed >= g.opts.Window {
+		g.lastCount = g.count
+
+		if elapsed >= 2*g.opts.Window {
+			g.lastCount = 0
+		}
+
+		g.count = 0
+		g.windowStart = now
+	}
+}
+
+// sign returns the signature of a challenge payload.
+func (g *Gate) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, g.secret)
+	mac.Write(payload)
+
+	return mac.Sum(nil)
+}
+
+// evict periodically forgets spent challenges that have expired, since they
+// would be rejected anyway.
+func (g *Gate) evict() {
+	ticker := time.NewTicker(g.opts.TTL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-g.done:
+			return
+		case <-ticker.C:
+			g.mu.Lock()
+
+			now := g.now()
+
+			for nonce, expiresAt := range g.used {
+				if now.After(expiresAt) {
+					delete(g.used, nonce)
+				}
+			}
+
+			g.mu.Unlock()
+		}
+	}
+}
+
+// Solve finds a counter solving the challenge with the given token at the
+// given difficulty, and returns the solution to send to the server.
+func Solve(token string, difficulty int) string {
+	for counter := uint64(0); ; counter++ {
+		c := strconv.FormatUint(counter, 10)
+
+		if LeadingZeroBits(token, c) >= difficulty {
+			return token + ":" + c
+		}
+	}
+}
+
+// LeadingZeroBits returns the number of leading zero bits of the SHA-256 hash
+// of "<token>:<counter>".
+func LeadingZeroBits(token, counter string) int {
+	sum := sha256.Sum256([]byte(token + ":" + counter))
+
+	var zeros int
+
+	for _, b := range sum {
+		if b != 0 {
+			return zeros + bits.LeadingZeros8(b)
+		}
+
+		zeros += 8
+	}
+
+	return zeros
+}
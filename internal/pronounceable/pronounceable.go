@@ -0,0 +1,268 @@
+// Package pronounceable generates passwords built from consonant-vowel
+// syllables, which are easier to read aloud and type than random passwords.
+//
+// Every password is an unambiguous sequence of choices from fixed alphabets, so
+// the entropy reported by Generator.Entropy is exact rather than an estimate
+// based on the length of the password.
+package pronounceable
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strings"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+)
+
+const (
+	// ErrPronounceablePassword is returned when a password cannot be
+	// generated.
+	ErrPronounceablePassword xerrors.Error = "failed to generate pronounceable password"
+
+	// ErrInvalidLength is returned when the length is out of range.
+	ErrInvalidLength xerrors.Error = "invalid length"
+
+	// ErrTooManyDigits is returned when the number of digits is negative or
+	// leaves no room for letters.
+	ErrTooManyDigits xerrors.Error = "too many digits for the given length"
+
+	// ErrUnknownCapitalization is returned when the capitalization mode is not
+	// supported.
+	ErrUnknownCapitalization xerrors.Error = "unknown capitalization mode"
+)
+
+const (
+	// Consonants are the letters used to start a syllable. Letters that are
+	// hard to pronounce on their own or easy to confuse over the phone are
+	// left out.
+	Consonants string = "bdfghjklmnprstvz"
+
+	// Vowels are the letters used to end a syllable.
+	Vowels string = "aeiou"
+
+	// Digits are the characters inserted between letters when digits are
+	// requested.
+	Digits string = "0123456789"
+)
+
+const (
+	// DefaultLength is the default length of a pronounceable password.
+	DefaultLength int = 16
+
+	// MaxLength is the maximum length of a pronounceable password.
+	MaxLength int = 128
+)
+
+// Capitalization controls which letters of a password are uppercased.
+type Capitalization string
+
+const (
+	// CapitalizeNone keeps every letter lowercase.
+	CapitalizeNone Capitalization = "none"
+
+	// CapitalizeFirst uppercases the first letter of the password. It adds no
+	// entropy.
+	CapitalizeFirst Capitalization = "first"
+
+	// CapitalizeRandom uppercases the first letter of each syllable with a
+	// probability of one half, adding one bit of entropy per syllable.
+	CapitalizeRandom Capitalization = "random"
+)
+
+// ParseCapitalization returns the Capitalization with the given name.
+func ParseCapitalization(name string) (Capitalization, error) {
+	switch c := Capitalization(strings.ToLower(name)); c {
+	case CapitalizeNone, CapitalizeFirst, CapitalizeRandom:
+		return c, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnknownCapitalization, name)
+	}
+}
+
+// Generator contains configuration options for generating a pronounceable
+// password.
+type Generator struct {
+	// Rand provides the source of entropy for generating the password. If Rand
+	// is nil, the cryptographic random reader in package crypto/rand is used.
+	Rand io.Reader
+
+	// Capitalization controls which letters are uppercased. If empty,
+	// CapitalizeNone is used.
+	Capitalization Capitalization
+
+	// Length is the total length of the password, digits included.
+	Length int
+
+	// Digits is the number of digits inserted at random positions.
+	Digits int
+}
+
+// Validate checks the generator options for errors.
+func (g *Generator) Validate() error {
+	if g.Length < 1 || g.Length > MaxLength {
+		return fmt.Errorf("%w: must be between 1 and %d", ErrInvalidLength, MaxLength)
+	}
+
+	if g.Digits < 0 || g.Digits >= g.Length {
+		return ErrTooManyDigits
+	}
+
+	if g.Capitalization != "" {
+		if _, err := ParseCapitalization(string(g.Capitalization)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Generate generates a pronounceable password.
+func (g *Generator) Generate() (string, error) {
+	if g.Length == 0 {
+		g.Length = DefaultLength
+	}
+
+	if err := g.Validate(); err != nil {
+		return "", fmt.Errorf("%w: %w", ErrPronounceablePassword, err)
+	}
+
+	var (
+		letters  = g.Length - g.Digits
+		password = make([]byte, 0, g.Length)
+	)
+
+	for i := 0; i < letters; i++ {
+		alphabet := Consonants
+		if i%2 == 1 {
+			alphabet = Vowels
+		}
+
+		c, err := g.choose(alphabet)
+		if err != nil {
+			return "", err
+		}
+
+		if i%2 == 0 && g.shouldCapitalize(i) {
+			if c, err = g.capitalize(c); err != nil {
+				return "", err
+			}
+		}
+
+		password = append(password, c)
+	}
+
+	// Insert the digits one at a time at uniformly random positions, which
+	// selects a uniformly random set of digit positions in the final password.
+	for i := 0; i < g.Digits; i++ {
+		pos, err := g.intn(len(password) + 1)
+		if err != nil {
+			return "", err
+		}
+
+		digit, err := g.choose(Digits)
+		if err != nil {
+			return "", err
+		}
+
+		password = append(password, 0)
+		copy(password[pos+1:], password[pos:])
+		password[pos] = digit
+	}
+
+	return string(password), nil
+}
+
+// Entropy returns the exact entropy, in bits, of the passwords produced by the
+// generator with its current options.
+func (g *Generator) Entropy() float64 {
+	length := g.Length
+	if length == 0 {
+		length = DefaultLength
+	}
+
+	var (
+		letters   = length - g.Digits
+		syllables = (letters + 1) / 2
+		entropy   = float64(syllables)*math.Log2(float64(len(Consonants))) +
+			float64(letters/2)*math.Log2(float64(len(Vowels)))
+	)
+
+	if g.Capitalization == CapitalizeRandom {
+		entropy += float64(syllables)
+	}
+
+	if g.Digits > 0 {
+		entropy += float64(g.Digits)*math.Log2(float64(len(Digits))) + log2Binomial(length, g.Digits)
+	}
+
+	return entropy
+}
+
+// shouldCapitalize reports whether the letter at the given position must be
+// uppercased regardless of chance.
+func (g *Generator) shouldCapitalize(pos int) bool {
+	switch g.Capitalization {
+	case CapitalizeFirst:
+		return pos == 0
+	case CapitalizeRandom:
+		return true
+	default:
+		return false
+	}
+}
+
+// capitalize uppercases c unconditionally for CapitalizeFirst, and with a
+// probability of one half for CapitalizeRandom.
+func (g *Generator) capitalize(c byte) (byte, error) {
+	if g.Capitalization == CapitalizeRandom {
+		coin, err := g.intn(2)
+		if err != nil {
+			return 0, err
+		}
+
+		if coin == 0 {
+			return c, nil
+		}
+	}
+
+	return c - 'a' + 'A', nil
+}
+
+// choose returns a uniformly random character from alphabet.
+func (g *Generator) choose(alphabet string) (byte, error) {
+	idx, err := g.intn(len(alphabet))
+	if err != nil {
+		return 0, err
+	}
+
+	return alphabet[idx], nil
+}
+
+// intn returns a uniformly random integer in [0, n).
+func (g *Generator) intn(n int) (int, error) {
+	reader := g.Rand
+	if reader == nil {
+		reader = rand.Reader
+	}
+
+	v, err := rand.Int(reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrPronounceablePassword, err)
+	}
+
+	return int(v.Int64()), nil
+}
+
+// log2Binomial returns the base 2 logarithm of n choose k.
+func log2Binomial(n, k int) float64 {
+	var result float64
+
+	for i := 0; i < k; i++ {
+		result += math.Log2(float64(n-i)) - math.Log2(float64(i+1))
+	}
+
+	return result
+}
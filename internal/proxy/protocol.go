@@ -0,0 +1,245 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+)
+
+// ErrInvalidHeader is returned when a trusted peer sends a malformed PROXY
+// protocol header.
+const ErrInvalidHeader xerrors.Error = "invalid PROXY protocol header"
+
+// DefaultHeaderTimeout is the default time a trusted peer has to send its
+// PROXY protocol header.
+const DefaultHeaderTimeout = 5 * time.Second
+
+const (
+	// v1MaxLength is the maximum length of a version 1 header, including the
+	// trailing CRLF.
+	v1MaxLength int = 107
+
+	// v2HeaderLength is the length of the fixed part of a version 2 header.
+	v2HeaderLength int = 16
+)
+
+var (
+	// v1Signature is the start of every version 1 header.
+	v1Signature = []byte("PROXY ")
+
+	// v2Signature is the start of every version 2 header.
+	v2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+)
+
+// Listener wraps a net.Listener and reads PROXY protocol version 1 and 2
+// headers sent by trusted peers, so that connections report the address of
+// the client rather than that of the proxy. Connections from other peers, and
+// connections from trusted peers that do not start with a header, are passed
+// through unchanged.
+type Listener struct {
+	net.Listener
+
+	// Trusted lists the peers allowed to send PROXY protocol headers.
+	Trusted []netip.Prefix
+
+	// HeaderTimeout is the time a trusted peer has to send its header.
+	HeaderTimeout time.Duration
+}
+
+// NewListener returns a Listener wrapping the given listener.
+func NewListener(listener net.Listener, trusted []netip.Prefix) *Listener {
+	return &Listener{
+		Listener:      listener,
+		Trusted:       trusted,
+		HeaderTimeout: DefaultHeaderTimeout,
+	}
+}
+
+// Accept waits for and returns the next connection. The PROXY protocol header
+// is read lazily, on the first call to Read or RemoteAddr.
+func (l *Listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err //nolint:wrapcheck // returned as is to the HTTP server
+	}
+
+	addrPort, err := netip.ParseAddrPort(conn.RemoteAddr().String())
+	if err != nil || !Contains(l.Trusted, addrPort.Addr()) {
+		return conn, nil
+	}
+
+	return &Conn{
+		Conn:    conn,
+		reader:  bufio.NewReaderSize(conn, v1MaxLength+v2HeaderLength),
+		timeout: l.HeaderTimeout,
+	}, nil
+}
+
+// Conn is a connection from a trusted peer that may start with a PROXY
+// protocol header.
+type Conn struct {
+	net.Conn
+	err        error
+	reader     *bufio.Reader
+	remoteAddr net.Addr
+	timeout    time.Duration
+	once       sync.Once
+}
+
+// Read reads data from the connection, after the PROXY protocol header.
+func (c *Conn) Read(b []byte) (int, error) {
+	c.once.Do(c.readHeader)
+
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	return c.reader.Read(b) //nolint:wrapcheck // returned as is to the HTTP server
+}
+
+// RemoteAddr returns the address of the client given in the PROXY protocol
+// header, or the address of the peer if there is none.
+func (c *Conn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+
+	if c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+
+	return c.Conn.RemoteAddr()
+}
+
+// readHeader reads the PROXY protocol header, if any.
+func (c *Conn) readHeader() {
+	if err := c.Conn.SetReadDeadline(time.Now().Add(c.timeout)); err != nil {
+		c.err = err
+
+		return
+	}
+
+	defer func() {
+		if err := c.Conn.SetReadDeadline(time.Time{}); err != nil && c.err == nil {
+			c.err = err
+		}
+	}()
+
+	signature, err := c.reader.Peek(len(v1Signature))
+	if err != nil {
+		c.err = err
+
+		return
+	}
+
+	switch {
+	case bytes.Equal(signature, v1Signature):
+		c.remoteAddr, c.err = readV1(c.reader)
+	case bytes.Equal(signature, v2Signature[:len(v1Signature)]):
+		c.remoteAddr, c.err = readV2(c.reader)
+	}
+}
+
+// readV1 reads a version 1 header, such as
+// "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n".
+func readV1(reader *bufio.Reader) (net.Addr, error) {
+	var line []byte
+
+	for len(line) < v1MaxLength {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidHeader, err)
+		}
+
+		line = append(line, b)
+
+		if b == '\n' {
+			break
+		}
+	}
+
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, fmt.Errorf("%w: line too long", ErrInvalidHeader)
+	}
+
+	fields := strings.Fields(string(line))
+
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidHeader, strings.TrimSpace(string(line)))
+	}
+
+	addr, err := netip.ParseAddr(fields[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidHeader, err)
+	}
+
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidHeader, err)
+	}
+
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(addr, uint16(port))), nil
+}
+
+// readV2 reads a version 2 header.
+func readV2(reader *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, v2HeaderLength)
+
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidHeader, err)
+	}
+
+	if !bytes.Equal(header[:len(v2Signature)], v2Signature) || header[12]>>4 != 2 {
+		return nil, fmt.Errorf("%w: bad signature or version", ErrInvalidHeader)
+	}
+
+	payload := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidHeader, err)
+	}
+
+	var (
+		command = header[12] & 0x0F
+		family  = header[13] >> 4
+	)
+
+	// LOCAL connections, such as health checks from the proxy itself, and
+	// address families other than IPv4 and IPv6 keep the peer address.
+	if command == 0 {
+		return nil, nil
+	}
+
+	switch family {
+	case 1:
+		if len(payload) < 12 {
+			return nil, fmt.Errorf("%w: short IPv4 address block", ErrInvalidHeader)
+		}
+
+		addr := netip.AddrFrom4([4]byte(payload[0:4]))
+
+		return net.TCPAddrFromAddrPort(netip.AddrPortFrom(addr, binary.BigEndian.Uint16(payload[8:10]))), nil
+	case 2:
+		if len(payload) < 36 {
+			return nil, fmt.Errorf("%w: short IPv6 address block", ErrInvalidHeader)
+		}
+
+		addr := netip.AddrFrom16([16]byte(payload[0:16]))
+
+		return net.TCPAddrFromAddrPort(netip.AddrPortFrom(addr, binary.BigEndian.Uint16(payload[32:34]))), nil
+	default:
+		return nil, nil
+	}
+}
@@ -0,0 +1,175 @@
+// Package proxy resolves the address of clients connecting through trusted
+// reverse proxies, either from forwarding headers or from the HAProxy PROXY
+// protocol.
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+)
+
+// ErrInvalidCIDR is returned when a trusted proxy is neither a CIDR nor an IP
+// address.
+const ErrInvalidCIDR xerrors.Error = "invalid trusted proxy CIDR"
+
+const (
+	// Forwarded is the standard forwarding header defined in RFC 7239.
+	Forwarded string = "Forwarded"
+
+	// XForwardedFor is the de facto standard forwarding header.
+	XForwardedFor string = "X-Forwarded-For"
+
+	// XRealIP is the forwarding header set by nginx and others.
+	XRealIP string = "X-Real-IP"
+)
+
+// ParsePrefixes parses a list of CIDRs. Plain IP addresses are accepted as
+// single-address prefixes.
+func ParsePrefixes(cidrs []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			addr, err := netip.ParseAddr(cidr)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %q", ErrInvalidCIDR, cidr)
+			}
+
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidCIDR, cidr)
+		}
+
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
+
+// Contains reports whether the address is in any of the prefixes.
+func Contains(prefixes []netip.Prefix, addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ClientAddr returns the address of the client that sent a request received
+// from the given peer. Forwarding headers are only honored when the peer is a
+// trusted proxy, in order of preference Forwarded, X-Forwarded-For, and
+// X-Real-IP. Chains of proxies are walked from the nearest hop outwards, and
+// the first untrusted address is the client.
+func ClientAddr(r *http.Request, peer netip.Addr, trusted []netip.Prefix) netip.Addr {
+	peer = peer.Unmap()
+
+	if !Contains(trusted, peer) {
+		return peer
+	}
+
+	if values := r.Header.Values(Forwarded); len(values) > 0 {
+		if addr, ok := walk(parseForwarded(values), trusted); ok {
+			return addr
+		}
+	}
+
+	if values := r.Header.Values(XForwardedFor); len(values) > 0 {
+		if addr, ok := walk(splitList(values), trusted); ok {
+			return addr
+		}
+	}
+
+	if value := r.Header.Get(XRealIP); value != "" {
+		if addr, ok := parseAddr(value); ok {
+			return addr
+		}
+	}
+
+	return peer
+}
+
+// walk returns the rightmost untrusted address in a list of hops, or the
+// leftmost address if every hop is trusted. It fails if any hop cannot be
+// parsed, since the list can no longer be trusted from that point on.
+func walk(hops []string, trusted []netip.Prefix) (netip.Addr, bool) {
+	var addr netip.Addr
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		var ok bool
+
+		addr, ok = parseAddr(hops[i])
+		if !ok {
+			return netip.Addr{}, false
+		}
+
+		if !Contains(trusted, addr) {
+			return addr, true
+		}
+	}
+
+	return addr, addr.IsValid()
+}
+
+// parseForwarded returns the values of the for parameters of Forwarded
+// headers.
+func parseForwarded(values []string) []string {
+	var hops []string
+
+	for _, element := range splitList(values) {
+		for _, pair := range strings.Split(element, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if !ok || !strings.EqualFold(key, "for") {
+				continue
+			}
+
+			hops = append(hops, strings.Trim(value, `"`))
+		}
+	}
+
+	return hops
+}
+
+// splitList splits comma-separated header values into their elements.
+func splitList(values []string) []string {
+	var elements []string
+
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			if element = strings.TrimSpace(element); element != "" {
+				elements = append(elements, element)
+			}
+		}
+	}
+
+	return elements
+}
+
+// parseAddr parses an IP address as found in forwarding headers, with or
+// without a port, and with or without brackets around IPv6 addresses.
+func parseAddr(s string) (netip.Addr, bool) {
+	s = strings.TrimSpace(s)
+
+	if addrPort, err := netip.ParseAddrPort(s); err == nil {
+		return addrPort.Addr().Unmap(), true
+	}
+
+	addr, err := netip.ParseAddr(strings.TrimSuffix(strings.TrimPrefix(s, "["), "]"))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+
+	return addr.Unmap(), true
+}
@@ -0,0 +1,166 @@
+// Package ratelimit implements token bucket rate limiting keyed by client.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit describes the rate at which a bucket refills and how many tokens it
+// holds.
+type Limit struct {
+	// Rate is the number of tokens added to the bucket per second.
+	Rate float64
+
+	// Burst is the maximum number of tokens in the bucket.
+	Burst int
+}
+
+// Result describes the outcome of a call to Limiter.Allow.
+type Result struct {
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+
+	// RetryAfter is the time until the next token is available. It is zero
+	// when the request was allowed.
+	RetryAfter time.Duration
+
+	// Limit is the maximum number of tokens in the bucket.
+	Limit int
+
+	// Remaining is the number of tokens left in the bucket.
+	Remaining int
+
+	// Allowed indicates whether the request was allowed.
+	Allowed bool
+}
+
+// bucket holds the state of a single client.
+type bucket struct {
+	last   time.Time
+	tokens float64
+}
+
+// Limiter keeps a token bucket per key and evicts buckets that have been idle
+// for longer than a given timeout.
+type Limiter struct {
+	buckets     map[string]*bucket
+	done        chan struct{}
+	now         func() time.Time
+	idleTimeout time.Duration
+	mu          sync.Mutex
+	closeOnce   sync.Once
+}
+
+// New returns a new Limiter and starts evicting idle buckets in the
+// background. Call Close to stop it.
+func New(idleTimeout time.Duration) *Limiter {
+	l := &Limiter{
+		buckets:     make(map[string]*bucket),
+		done:        make(chan struct{}),
+		now:         time.Now,
+		idleTimeout: idleTimeout,
+	}
+
+	go l.evict()
+
+	return l
+}
+
+// Allow takes a token from the bucket of the given key, creating a full bucket
+// if none exists yet.
+func (l *Limiter) Allow(key string, limit Limit) Result {
+	return l.take(key, limit, 1)
+}
+
+// Peek reports whether Allow would allow a request for the given key, without
+// taking a token.
+func (l *Limiter) Peek(key string, limit Limit) Result {
+	return l.take(key, limit, 0)
+}
+
+// take refills the bucket of the given key and takes n tokens from it if it
+// holds at least one.
+func (l *Limiter) take(key string, limit Limit, n float64) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{
+			tokens: float64(limit.Burst),
+			last:   now,
+		}
+
+		l.buckets[key] = b
+	}
+
+	elapsed := now.Sub(b.last).Seconds()
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+elapsed*limit.Rate)
+	b.last = now
+
+	result := Result{
+		Limit: limit.Burst,
+	}
+
+	if b.tokens >= 1 {
+		b.tokens -= n
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - b.tokens) / limit.Rate)
+	}
+
+	result.Remaining = int(b.tokens)
+	result.Reset = seconds((float64(limit.Burst) - b.tokens) / limit.Rate)
+
+	return result
+}
+
+// Len returns the number of buckets currently tracked.
+func (l *Limiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.buckets)
+}
+
+// Close stops the eviction of idle buckets.
+func (l *Limiter) Close() {
+	l.closeOnce.Do(func() {
+		close(l.done)
+	})
+}
+
+// evict periodically removes buckets that have not been used for longer than
+// the idle timeout.
+func (l *Limiter) evict() {
+	ticker := time.NewTicker(l.idleTimeout)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-ticker.C:
+			l.mu.Lock()
+
+			now := l.now()
+
+			for key, b := range l.buckets {
+				if now.Sub(b.last) > l.idleTimeout {
+					delete(l.buckets, key)
+				}
+			}
+
+			l.mu.Unlock()
+		}
+	}
+}
+
+// seconds converts a number of seconds into a time.Duration.
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,114 @@
+// Package recovery generates sets of single-use recovery codes, such as the
+// backup codes offered alongside multi-factor authentication.
+//
+// Codes use Crockford's Base32 alphabet, so they can be read back regardless
+// of case and tolerate the usual confusion between O and 0, and I, L and 1.
+package recovery
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+)
+
+const (
+	// ErrRecoveryCodes is returned when a set of recovery codes cannot be
+	// generated.
+	ErrRecoveryCodes xerrors.Error = "failed to generate recovery codes"
+
+	// ErrInvalidCount is returned when the number of codes is out of range.
+	ErrInvalidCount xerrors.Error = "invalid number of codes"
+)
+
+const (
+	// Alphabet is Crockford's Base32 alphabet.
+	Alphabet string = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+	// Separator is the character separating the groups of a code.
+	Separator string = "-"
+
+	// GroupSize is the number of characters in each group of a code.
+	GroupSize int = 4
+
+	// Groups is the number of groups in a code.
+	Groups int = 2
+
+	// DefaultCount is the default number of codes in a set.
+	DefaultCount int = 10
+
+	// MaxCount is the maximum number of codes in a set.
+	MaxCount int = 100
+)
+
+// Generate returns count unique recovery codes in XXXX-XXXX form.
+func Generate(count int) ([]string, error) {
+	if count < 1 || count > MaxCount {
+		return nil, fmt.Errorf("%w: must be between 1 and %d", ErrInvalidCount, MaxCount)
+	}
+
+	var (
+		codes = make([]string, 0, count)
+		seen  = make(map[string]struct{}, count)
+		base  = big.NewInt(int64(len(Alphabet)))
+	)
+
+	for len(codes) < count {
+		var builder strings.Builder
+
+		builder.Grow(Groups*GroupSize + Groups - 1)
+
+		for i := 0; i < Groups*GroupSize; i++ {
+			if i > 0 && i%GroupSize == 0 {
+				builder.WriteString(Separator)
+			}
+
+			idx, err := rand.Int(rand.Reader, base)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrRecoveryCodes, err)
+			}
+
+			builder.WriteByte(Alphabet[idx.Int64()])
+		}
+
+		code := builder.String()
+
+		if _, ok := seen[code]; ok {
+			continue
+		}
+
+		seen[code] = struct{}{}
+		codes = append(codes, code)
+	}
+
+	return codes, nil
+}
+
+// Normalize returns the canonical form of a code as typed by a user: hyphens
+// and spaces are removed, letters are uppercased, O is read as 0, and I and L
+// are read as 1.
+//
+// Hashes of codes are always computed over their canonical form, so calling
+// applications must normalize user input before verifying it.
+func Normalize(code string) string {
+	var builder strings.Builder
+
+	builder.Grow(len(code))
+
+	for _, c := range strings.ToUpper(code) {
+		switch c {
+		case '-', ' ':
+			continue
+		case 'O':
+			c = '0'
+		case 'I', 'L':
+			c = '1'
+		}
+
+		builder.WriteRune(c)
+	}
+
+	return builder.String()
+}
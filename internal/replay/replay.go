@@ -0,0 +1,82 @@
+// Package replay implements a cache of recently seen values, used to reject
+// replayed requests.
+package replay
+
+import (
+	"sync"
+	"time"
+)
+
+// Cache remembers values for a fixed time.
+type Cache struct {
+	seen      map[string]time.Time
+	done      chan struct{}
+	now       func() time.Time
+	ttl       time.Duration
+	mu        sync.Mutex
+	closeOnce sync.Once
+}
+
+// New returns a new Cache remembering values for the given time, and starts
+// evicting older values in the background. Call Close to stop it.
+func New(ttl time.Duration) *Cache {
+	c := &Cache{
+		seen: make(map[string]time.Time),
+		done: make(chan struct{}),
+		now:  time.Now,
+		ttl:  ttl,
+	}
+
+	go c.evict()
+
+	return c
+}
+
+// Seen records the value and reports whether it was already recorded within
+// the cache's time to live.
+func (c *Cache) Seen(value string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+
+	if expiresAt, ok := c.seen[value]; ok && now.Before(expiresAt) {
+		return true
+	}
+
+	c.seen[value] = now.Add(c.ttl)
+
+	return false
+}
+
+// Close stops the eviction of old values.
+func (c *Cache) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+}
+
+// evict periodically removes expired values.
+func (c *Cache) evict() {
+	ticker := time.NewTicker(c.ttl)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			c.mu.Lock()
+
+			now := c.now()
+
+			for value, expiresAt := range c.seen {
+				if !now.Before(expiresAt) {
+					delete(c.seen, value)
+				}
+			}
+
+			c.mu.Unlock()
+		}
+	}
+}
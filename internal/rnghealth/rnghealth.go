@@ -0,0 +1,227 @@
+// Package rnghealth continuously tests the output of the randomness source,
+// with the repetition count and adaptive proportion health tests of NIST SP
+// 800-90B, section 4.4.
+//
+// The source is sampled in the background rather than on every read, and each
+// byte is treated as an 8-bit sample with full entropy. Cutoffs are derived
+// for a false positive probability of 2^-40 per sample, the lowest SP 800-90B
+// allows, since the tests run for the whole life of the process. Once a test
+// fails, the source is considered broken until the process restarts.
+package rnghealth
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/secmem"
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+	"go.uber.org/zap"
+)
+
+const (
+	// ErrRepetitionCount is returned when the same sample repeats more often
+	// in a row than a working source plausibly would.
+	ErrRepetitionCount xerrors.Error = "repetition count test failed"
+
+	// ErrAdaptiveProportion is returned when a sample makes up more of a
+	// window than a working source plausibly would.
+	ErrAdaptiveProportion xerrors.Error = "adaptive proportion test failed"
+
+	// ErrSelfTest is returned when a known-answer self-test fails.
+	ErrSelfTest xerrors.Error = "known-answer self-test failed"
+)
+
+const (
+	// RepetitionCutoff is the number of identical samples in a row that fails
+	// the repetition count test: 1 + ceil(40 / 8).
+	RepetitionCutoff int = 6
+
+	// ProportionWindow is the number of samples in every window of the
+	// adaptive proportion test.
+	ProportionWindow int = 512
+
+	// ProportionCutoff is the number of times the first sample of a window
+	// may appear in it before failing the adaptive proportion test:
+	// 1 + CRITBINOM(512, 2^-8, 1 - 2^-40).
+	ProportionCutoff int = 19
+
+	// MinStartupSamples is the number of samples tested at startup, before
+	// the source is trusted.
+	MinStartupSamples int = 1024
+)
+
+// Monitor samples a randomness source in the background and runs the health
+// tests on every sample.
+type Monitor struct {
+	source     io.Reader
+	logger     *zap.Logger
+	done       chan struct{}
+	err        error
+	repetition repetitionCountTest
+	proportion adaptiveProportionTest
+	sampleSize int
+	mu         sync.Mutex
+	closeOnce  sync.Once
+}
+
+// New runs the known-answer self-tests and the startup health tests on source,
+// and returns a Monitor that keeps testing sampleSize bytes of it every
+// interval. Failures are reported by Err rather than returned, so that the
+// server can keep reporting them. Call Close to stop it.
+func New(source io.Reader, sampleSize int, interval time.Duration, logger *zap.Logger) *Monitor {
+	m := &Monitor{
+		source:     source,
+		logger:     logger,
+		done:       make(chan struct{}),
+		sampleSize: sampleSize,
+	}
+
+	if err := SelfTest(); err != nil {
+		m.fail(err)
+
+		return m
+	}
+
+	startup := sampleSize
+	if startup < MinStartupSamples {
+		startup = MinStartupSamples
+	}
+
+	if err := m.test(startup); err != nil {
+		m.fail(err)
+
+		return m
+	}
+
+	go m.run(interval)
+
+	return m
+}
+
+// Err returns the reason the randomness source is considered broken, or nil if
+// every test passed.
+func (m *Monitor) Err() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.err
+}
+
+// Close stops sampling the randomness source.
+func (m *Monitor) Close() {
+	m.closeOnce.Do(func() {
+		close(m.done)
+	})
+}
+
+// run periodically tests a sample of the source, until a test fails.
+func (m *Monitor) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+			if err := m.test(m.sampleSize); err != nil {
+				m.fail(err)
+
+				return
+			}
+		}
+	}
+}
+
+// test reads size bytes from the source and runs the health tests on them.
+// The state of the tests carries over from one sample to the next.
+func (m *Monitor) test(size int) error {
+	sample := make([]byte, size)
+	defer secmem.Zero(sample)
+
+	if _, err := io.ReadFull(m.source, sample); err != nil {
+		return fmt.Errorf("failed to sample randomness source: %w", err)
+	}
+
+	for _, b := range sample {
+		if err := m.repetition.add(b); err != nil {
+			return err
+		}
+
+		if err := m.proportion.add(b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// fail marks the source as broken.
+func (m *Monitor) fail(err error) {
+	m.logger.Error("randomness source failed its health tests", zap.Error(err))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.err = err
+}
+
+// repetitionCountTest is the repetition count test of SP 800-90B, section
+// 4.4.1, which catches a source stuck on one value.
+type repetitionCountTest struct {
+	count int
+	last  byte
+}
+
+// add feeds a sample to the test.
+func (t *repetitionCountTest) add(b byte) error {
+	if t.count > 0 && b == t.last {
+		t.count++
+	} else {
+		t.last = b
+		t.count = 1
+	}
+
+	if t.count >= RepetitionCutoff {
+		return fmt.Errorf("%w: %d identical samples in a row", ErrRepetitionCount, t.count)
+	}
+
+	return nil
+}
+
+// adaptiveProportionTest is the adaptive proportion test of SP 800-90B,
+// section 4.4.2, which catches a source losing entropy by favoring a value.
+type adaptiveProportionTest struct {
+	seen  int
+	count int
+	first byte
+}
+
+// add feeds a sample to the test.
+func (t *adaptiveProportionTest) add(b byte) error {
+	if t.seen == 0 {
+		t.first = b
+		t.count = 1
+		t.seen = 1
+
+		return nil
+	}
+
+	if b == t.first {
+		t.count++
+	}
+
+	t.seen++
+
+	if t.count >= ProportionCutoff {
+		return fmt.Errorf("%w: sample seen %d times in a window of %d", ErrAdaptiveProportion, t.count, ProportionWindow)
+	}
+
+	if t.seen == ProportionWindow {
+		t.seen = 0
+	}
+
+	return nil
+}
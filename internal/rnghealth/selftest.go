@@ -0,0 +1,75 @@
+package rnghealth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/secmem"
+)
+
+// SelfTest runs the known-answer tests of the deterministic steps secrets go
+// through once the randomness source is read: the hashes used to key and
+// derive them, the mapping of random bytes onto a character set, and the
+// health tests themselves, which must reject a broken source.
+func SelfTest() error {
+	// FIPS 180-2, appendix B.1.
+	sum := sha256.Sum256([]byte("abc"))
+	if hex.EncodeToString(sum[:]) != "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad" {
+		return fmt.Errorf("%w: SHA-256", ErrSelfTest)
+	}
+
+	// RFC 4231, test case 2.
+	mac := hmac.New(sha256.New, []byte("Jefe"))
+	mac.Write([]byte("what do ya want for nothing?"))
+
+	if hex.EncodeToString(mac.Sum(nil)) != "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843" {
+		return fmt.Errorf("%w: HMAC-SHA256", ErrSelfTest)
+	}
+
+	// Bytes at or above 250 are rejected for a charset of ten characters, and
+	// the rest are mapped modulo ten.
+	password := make([]byte, 2)
+
+	if err := secmem.FillFrom(bytes.NewReader([]byte{255, 250, 3, 12}), password, "0123456789"); err != nil || string(password) != "32" {
+		return fmt.Errorf("%w: character mapping", ErrSelfTest)
+	}
+
+	// A stuck source must fail the repetition count test.
+	var repetition repetitionCountTest
+
+	if err := feed(repetition.add, bytes.Repeat([]byte{0x42}, RepetitionCutoff)); !errors.Is(err, ErrRepetitionCount) {
+		return fmt.Errorf("%w: repetition count test", ErrSelfTest)
+	}
+
+	// A source favoring one value without repeating it must fail the
+	// adaptive proportion test.
+	var (
+		proportion adaptiveProportionTest
+		biased     = make([]byte, 2*ProportionCutoff)
+	)
+
+	for i := range biased {
+		biased[i] = byte(i % 2)
+	}
+
+	if err := feed(proportion.add, biased); !errors.Is(err, ErrAdaptiveProportion) {
+		return fmt.Errorf("%w: adaptive proportion test", ErrSelfTest)
+	}
+
+	return nil
+}
+
+// feed feeds samples to a health test, stopping at the first failure.
+func feed(add func(byte) error, samples []byte) error {
+	for _, b := range samples {
+		if err := add(b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
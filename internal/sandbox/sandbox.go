@@ -0,0 +1,159 @@
+// Package sandbox confines the server process once it has initialized, using
+// Landlock to restrict filesystem access, a seccomp filter to restrict system
+// calls, and privilege dropping to run as an unprivileged user.
+//
+// Landlock only restricts the calling thread, and the Go runtime cannot apply
+// it to every thread of a program using cgo, as acopwctl does for SQLite.
+// Enter therefore restricts a single thread and re-executes the program from
+// it, so that the whole new process inherits the restrictions.
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+)
+
+const (
+	// ErrUnsupported is returned when a sandboxing feature is not available on
+	// the current platform or kernel.
+	ErrUnsupported xerrors.Error = "not supported on this platform"
+
+	// ErrInvalidCredentials is returned when the user or group to switch to
+	// cannot be resolved.
+	ErrInvalidCredentials xerrors.Error = "invalid user or group"
+)
+
+// EnvSandboxed is the environment variable marking a process re-executed by
+// Enter. Its value holds the credentials to switch to, resolved before
+// filesystem access was restricted.
+const EnvSandboxed string = "ACOPW_SANDBOXED"
+
+// Credentials are the user and group the server switches to after binding its
+// listener.
+type Credentials struct {
+	// UID is the numeric user ID.
+	UID int
+
+	// GID is the numeric group ID.
+	GID int
+}
+
+// Policy describes what the sandboxed process may access.
+type Policy struct {
+	// Credentials are the user and group to switch to, if any.
+	Credentials *Credentials
+
+	// ReadOnly are the files and directories the process may read.
+	ReadOnly []string
+
+	// ReadWrite are the files and directories the process may read, write,
+	// create files in, and remove files from. Paths that do not exist yet are
+	// replaced by their parent directory.
+	ReadWrite []string
+}
+
+// Sandboxed reports whether the process was re-executed by Enter.
+func Sandboxed() bool {
+	_, ok := os.LookupEnv(EnvSandboxed)
+
+	return ok
+}
+
+// LookupCredentials resolves the given user and group names or numeric IDs.
+// If the group is empty, the primary group of the user is used. If both are
+// empty, it returns nil.
+//
+// In a process re-executed by Enter, it returns the credentials resolved
+// before re-executing, as the user database is no longer readable.
+func LookupCredentials(username, group string) (*Credentials, error) {
+	if Sandboxed() {
+		return parseCredentials(os.Getenv(EnvSandboxed))
+	}
+
+	if username == "" && group == "" {
+		return nil, nil //nolint:nilnil // no credentials to switch to
+	}
+
+	creds := &Credentials{
+		UID: os.Getuid(),
+		GID: os.Getgid(),
+	}
+
+	if username != "" {
+		u, err := user.Lookup(username)
+		if err != nil {
+			u, err = user.LookupId(username)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+		}
+
+		if creds.UID, err = strconv.Atoi(u.Uid); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+		}
+
+		if creds.GID, err = strconv.Atoi(u.Gid); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+		}
+	}
+
+	if group != "" {
+		g, err := user.LookupGroup(group)
+		if err != nil {
+			g, err = user.LookupGroupId(group)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+		}
+
+		if creds.GID, err = strconv.Atoi(g.Gid); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+		}
+	}
+
+	return creds, nil
+}
+
+// String returns the credentials in uid:gid format.
+func (c *Credentials) String() string {
+	if c == nil {
+		return ""
+	}
+
+	return strconv.Itoa(c.UID) + ":" + strconv.Itoa(c.GID)
+}
+
+// parseCredentials parses credentials in the format returned by
+// Credentials.String.
+func parseCredentials(s string) (*Credentials, error) {
+	if s == "" {
+		return nil, nil //nolint:nilnil // no credentials to switch to
+	}
+
+	uid, gid, ok := strings.Cut(s, ":")
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidCredentials, s)
+	}
+
+	var (
+		creds Credentials
+		err   error
+	)
+
+	if creds.UID, err = strconv.Atoi(uid); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+	}
+
+	if creds.GID, err = strconv.Atoi(gid); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+	}
+
+	return &creds, nil
+}
@@ -0,0 +1,238 @@
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// readAccess is the Landlock access granted to read-only paths.
+	readAccess uint64 = unix.LANDLOCK_ACCESS_FS_READ_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_DIR
+
+	// writeAccess is the Landlock access granted to read-write paths, on top
+	// of readAccess.
+	writeAccess uint64 = unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_FILE |
+		unix.LANDLOCK_ACCESS_FS_MAKE_REG |
+		unix.LANDLOCK_ACCESS_FS_TRUNCATE
+
+	// fileAccess is the Landlock access that applies to files, as opposed to
+	// directories.
+	fileAccess uint64 = unix.LANDLOCK_ACCESS_FS_EXECUTE |
+		unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_FILE |
+		unix.LANDLOCK_ACCESS_FS_TRUNCATE
+)
+
+// rule grants access beneath a path.
+type rule struct {
+	path   string
+	access uint64
+}
+
+// Enter restricts filesystem access to the paths of the policy and
+// re-executes the program, with the credentials of the policy recorded in the
+// environment. Processes re-executed this way can no longer gain privileges,
+// and can only execute the program itself. Enter only returns on error.
+func Enter(policy *Policy) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find executable: %w", err)
+	}
+
+	// The restrictions only apply to the current thread, which then becomes
+	// the new process.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("failed to set no new privileges: %w", err)
+	}
+
+	if err := landlock(policy, executable); err != nil {
+		return err
+	}
+
+	env := append(os.Environ(), EnvSandboxed+"="+policy.Credentials.String())
+
+	if err := syscall.Exec(executable, os.Args, env); err != nil {
+		return fmt.Errorf("failed to re-execute sandboxed: %w", err)
+	}
+
+	return nil
+}
+
+// DropPrivileges switches to the given user and group, dropping supplementary
+// groups.
+func DropPrivileges(creds *Credentials) error {
+	if creds == nil {
+		return nil
+	}
+
+	if err := syscall.Setgroups([]int{}); err != nil {
+		return fmt.Errorf("failed to drop supplementary groups: %w", err)
+	}
+
+	if err := syscall.Setgid(creds.GID); err != nil {
+		return fmt.Errorf("failed to switch group: %w", err)
+	}
+
+	if err := syscall.Setuid(creds.UID); err != nil {
+		return fmt.Errorf("failed to switch user: %w", err)
+	}
+
+	return nil
+}
+
+// landlock restricts the filesystem access of the current thread to the paths
+// of the policy and the executable.
+func landlock(policy *Policy, executable string) error {
+	abi, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0, unix.LANDLOCK_CREATE_RULESET_VERSION)
+	if errno != 0 {
+		return fmt.Errorf("landlock: %w: %w", ErrUnsupported, errno)
+	}
+
+	// Only handle the access rights known to the running kernel.
+	handled := uint64(unix.LANDLOCK_ACCESS_FS_MAKE_SYM<<1 - 1)
+
+	if abi >= 2 {
+		handled |= unix.LANDLOCK_ACCESS_FS_REFER
+	}
+
+	if abi >= 3 {
+		handled |= unix.LANDLOCK_ACCESS_FS_TRUNCATE
+	}
+
+	attr := unix.LandlockRulesetAttr{
+		Access_fs: handled,
+	}
+
+	fd, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return fmt.Errorf("failed to create landlock ruleset: %w", errno)
+	}
+	defer unix.Close(int(fd))
+
+	rules := []rule{
+		{executable, unix.LANDLOCK_ACCESS_FS_READ_FILE | unix.LANDLOCK_ACCESS_FS_EXECUTE},
+		{"/etc/ld.so.cache", unix.LANDLOCK_ACCESS_FS_READ_FILE},
+	}
+
+	// The dynamic loader and the shared libraries the program is linked
+	// against must be loaded again after re-executing.
+	libraries, err := mappedLibraries()
+	if err != nil {
+		return err
+	}
+
+	for _, path := range libraries {
+		rules = append(rules, rule{path, unix.LANDLOCK_ACCESS_FS_READ_FILE | unix.LANDLOCK_ACCESS_FS_EXECUTE})
+	}
+
+	for _, path := range policy.ReadOnly {
+		rules = append(rules, rule{path, readAccess})
+	}
+
+	for _, path := range policy.ReadWrite {
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			path = filepath.Dir(path)
+		}
+
+		rules = append(rules, rule{path, readAccess | writeAccess})
+	}
+
+	for _, r := range rules {
+		if err := addRule(int(fd), r.path, r.access&handled); err != nil {
+			return err
+		}
+	}
+
+	if _, _, errno := unix.Syscall(unix.SYS_LANDLOCK_RESTRICT_SELF, fd, 0, 0); errno != 0 {
+		return fmt.Errorf("failed to enforce landlock ruleset: %w", errno)
+	}
+
+	return nil
+}
+
+// mappedLibraries returns the paths of the executable files mapped into the
+// memory of the process, such as the dynamic loader and shared libraries.
+func mappedLibraries() ([]string, error) {
+	maps, err := os.ReadFile("/proc/self/maps")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read memory mappings: %w", err)
+	}
+
+	var (
+		seen      = make(map[string]struct{})
+		libraries []string
+	)
+
+	for _, line := range strings.Split(string(maps), "\n") {
+		// address perms offset dev inode path
+		fields := strings.Fields(line)
+		if len(fields) < 6 || !strings.Contains(fields[1], "x") || !strings.HasPrefix(fields[5], "/") {
+			continue
+		}
+
+		if _, ok := seen[fields[5]]; ok {
+			continue
+		}
+
+		seen[fields[5]] = struct{}{}
+
+		libraries = append(libraries, fields[5])
+	}
+
+	return libraries, nil
+}
+
+// addRule allows the given access beneath a path. Paths that do not exist are
+// skipped.
+func addRule(rulesetFD int, path string, access uint64) error {
+	fd, err := unix.Open(path, unix.O_PATH|unix.O_CLOEXEC, 0)
+	if errors.Is(err, unix.ENOENT) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer unix.Close(fd)
+
+	var stat unix.Stat_t
+
+	if err := unix.Fstat(fd, &stat); err != nil {
+		return fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+
+	if stat.Mode&unix.S_IFMT != unix.S_IFDIR {
+		access &= fileAccess
+	}
+
+	attr := unix.LandlockPathBeneathAttr{
+		Allowed_access: access,
+		Parent_fd:      int32(fd),
+	}
+
+	_, _, errno := unix.Syscall6(
+		unix.SYS_LANDLOCK_ADD_RULE,
+		uintptr(rulesetFD),
+		unix.LANDLOCK_RULE_PATH_BENEATH,
+		uintptr(unsafe.Pointer(&attr)),
+		0, 0, 0,
+	)
+	if errno != 0 {
+		return fmt.Errorf("failed to allow access to %s: %w", path, errno)
+	}
+
+	return nil
+}
@@ -0,0 +1,22 @@
+//go:build !linux
+
+package sandbox
+
+// Enter is only supported on Linux.
+func Enter(_ *Policy) error {
+	return ErrUnsupported
+}
+
+// DropPrivileges is only supported on Linux.
+func DropPrivileges(creds *Credentials) error {
+	if creds == nil {
+		return nil
+	}
+
+	return ErrUnsupported
+}
+
+// Seccomp is only supported on Linux.
+func Seccomp() error {
+	return ErrUnsupported
+}
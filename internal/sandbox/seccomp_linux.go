@@ -0,0 +1,97 @@
+package sandbox
+
+import (
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Values from linux/seccomp.h missing from golang.org/x/sys/unix.
+const (
+	seccompSetModeFilter   uintptr = 1
+	seccompFilterFlagTSync uintptr = 1
+	seccompRetKillProcess  uint32  = 0x80000000
+	seccompRetErrno        uint32  = 0x00050000
+	seccompRetAllow        uint32  = 0x7fff0000
+)
+
+// Offsets of the fields of struct seccomp_data.
+const (
+	seccompDataNR   uint32 = 0
+	seccompDataArch uint32 = 4
+)
+
+// Seccomp installs a seccomp filter on every thread of the process, allowing
+// only the system calls a running server needs. Other system calls fail with
+// EPERM, and system calls made with another architecture's calling convention
+// kill the process. The filter cannot be removed.
+func Seccomp() error {
+	if len(allowedSyscalls) == 0 {
+		return fmt.Errorf("seccomp: %w", ErrUnsupported)
+	}
+
+	filter := seccompFilter()
+
+	prog := unix.SockFprog{
+		Len:    uint16(len(filter)),
+		Filter: &filter[0],
+	}
+
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("failed to set no new privileges: %w", err)
+	}
+
+	tid, _, errno := unix.Syscall(unix.SYS_SECCOMP, seccompSetModeFilter, seccompFilterFlagTSync, uintptr(unsafe.Pointer(&prog)))
+	if errno != 0 {
+		return fmt.Errorf("failed to install seccomp filter: %w", errno)
+	}
+
+	if tid != 0 {
+		return fmt.Errorf("failed to install seccomp filter: thread %d cannot be synchronized", tid)
+	}
+
+	return nil
+}
+
+// seccompFilter returns the BPF program of the seccomp filter.
+func seccompFilter() []unix.SockFilter {
+	n := len(allowedSyscalls)
+
+	filter := make([]unix.SockFilter, 0, n+6)
+
+	filter = append(filter,
+		bpfStmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, seccompDataArch),
+		bpfJump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, auditArch, 1, 0),
+		bpfStmt(unix.BPF_RET|unix.BPF_K, seccompRetKillProcess),
+		bpfStmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, seccompDataNR),
+	)
+
+	for i, nr := range allowedSyscalls {
+		// Jump over the remaining comparisons and the EPERM return.
+		filter = append(filter, bpfJump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, uint32(nr), uint8(n-i), 0))
+	}
+
+	return append(filter,
+		bpfStmt(unix.BPF_RET|unix.BPF_K, seccompRetErrno|uint32(unix.EPERM)),
+		bpfStmt(unix.BPF_RET|unix.BPF_K, seccompRetAllow),
+	)
+}
+
+// bpfStmt returns a BPF statement.
+func bpfStmt(code uint16, k uint32) unix.SockFilter {
+	return unix.SockFilter{
+		Code: code,
+		K:    k,
+	}
+}
+
+// bpfJump returns a BPF jump.
+func bpfJump(code uint16, k uint32, jt, jf uint8) unix.SockFilter {
+	return unix.SockFilter{
+		Code: code,
+		Jt:   jt,
+		Jf:   jf,
+		K:    k,
+	}
+}
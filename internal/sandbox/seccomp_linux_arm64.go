@@ -0,0 +1,107 @@
+package sandbox
+
+import "golang.org/x/sys/unix"
+
+// auditArch is the architecture seccomp filters check system calls against.
+const auditArch uint32 = unix.AUDIT_ARCH_AARCH64
+
+// allowedSyscalls are the system calls used by the Go runtime, the network
+// stack, and SQLite while serving requests.
+var allowedSyscalls = []uintptr{
+	// Files.
+	unix.SYS_READ,
+	unix.SYS_WRITE,
+	unix.SYS_READV,
+	unix.SYS_WRITEV,
+	unix.SYS_PREAD64,
+	unix.SYS_PWRITE64,
+	unix.SYS_OPENAT,
+	unix.SYS_CLOSE,
+	unix.SYS_FSTAT,
+	unix.SYS_FSTATAT,
+	unix.SYS_STATX,
+	unix.SYS_LSEEK,
+	unix.SYS_FACCESSAT,
+	unix.SYS_FACCESSAT2,
+	unix.SYS_READLINKAT,
+	unix.SYS_GETDENTS64,
+	unix.SYS_GETCWD,
+	unix.SYS_FCNTL,
+	unix.SYS_FLOCK,
+	unix.SYS_FSYNC,
+	unix.SYS_FDATASYNC,
+	unix.SYS_FTRUNCATE,
+	unix.SYS_FALLOCATE,
+	unix.SYS_FCHMOD,
+	unix.SYS_FCHOWN,
+	unix.SYS_UNLINKAT,
+	unix.SYS_RENAMEAT,
+	unix.SYS_IOCTL,
+	unix.SYS_DUP,
+	unix.SYS_DUP3,
+	unix.SYS_PIPE2,
+
+	// Network.
+	unix.SYS_ACCEPT4,
+	unix.SYS_GETSOCKNAME,
+	unix.SYS_GETPEERNAME,
+	unix.SYS_GETSOCKOPT,
+	unix.SYS_SETSOCKOPT,
+	unix.SYS_RECVFROM,
+	unix.SYS_RECVMSG,
+	unix.SYS_SENDTO,
+	unix.SYS_SENDMSG,
+	unix.SYS_SHUTDOWN,
+	unix.SYS_EPOLL_CREATE1,
+	unix.SYS_EPOLL_CTL,
+	unix.SYS_EPOLL_PWAIT,
+	unix.SYS_EVENTFD2,
+	unix.SYS_PPOLL,
+	unix.SYS_PSELECT6,
+
+	// Memory.
+	unix.SYS_BRK,
+	unix.SYS_MMAP,
+	unix.SYS_MUNMAP,
+	unix.SYS_MREMAP,
+	unix.SYS_MPROTECT,
+	unix.SYS_MADVISE,
+	unix.SYS_MINCORE,
+	unix.SYS_MLOCK,
+	unix.SYS_MUNLOCK,
+	unix.SYS_MEMBARRIER,
+
+	// Threads, signals, and time.
+	unix.SYS_CLONE,
+	unix.SYS_CLONE3,
+	unix.SYS_EXIT,
+	unix.SYS_EXIT_GROUP,
+	unix.SYS_FUTEX,
+	unix.SYS_SET_ROBUST_LIST,
+	unix.SYS_RSEQ,
+	unix.SYS_SCHED_YIELD,
+	unix.SYS_SCHED_GETAFFINITY,
+	unix.SYS_RT_SIGACTION,
+	unix.SYS_RT_SIGPROCMASK,
+	unix.SYS_RT_SIGRETURN,
+	unix.SYS_SIGALTSTACK,
+	unix.SYS_TGKILL,
+	unix.SYS_GETPID,
+	unix.SYS_GETTID,
+	unix.SYS_GETPPID,
+	unix.SYS_GETUID,
+	unix.SYS_GETEUID,
+	unix.SYS_GETGID,
+	unix.SYS_GETEGID,
+	unix.SYS_NANOSLEEP,
+	unix.SYS_CLOCK_GETTIME,
+	unix.SYS_CLOCK_NANOSLEEP,
+	unix.SYS_GETTIMEOFDAY,
+	unix.SYS_TIMER_CREATE,
+	unix.SYS_TIMER_SETTIME,
+	unix.SYS_TIMER_DELETE,
+	unix.SYS_RESTART_SYSCALL,
+	unix.SYS_GETRANDOM,
+	unix.SYS_PRLIMIT64,
+	unix.SYS_UNAME,
+}
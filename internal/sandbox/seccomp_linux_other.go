@@ -0,0 +1,10 @@
+//go:build linux && !amd64 && !arm64
+
+package sandbox
+
+// auditArch is unused on architectures without a system call allowlist.
+const auditArch uint32 = 0
+
+// allowedSyscalls is empty on architectures without a system call allowlist,
+// which makes Seccomp return ErrUnsupported.
+var allowedSyscalls []uintptr
@@ -0,0 +1,126 @@
+// Package secmem keeps secrets in byte slices that can be locked into memory
+// and wiped once the response is written, instead of leaving immutable string
+// copies scattered across the heap.
+//
+// It covers the random passwords, PINs, and derived passwords generated by the
+// server, and the secrets clients send in request bodies. The diceware,
+// pronounceable, mnemonic, recovery code, WireGuard, and age generators build
+// their secrets as strings, which cannot be wiped.
+package secmem
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"runtime"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+)
+
+const (
+	// ErrUnsupported is returned when memory locking or core dump protection
+	// is not available on the current platform.
+	ErrUnsupported xerrors.Error = "not supported on this platform"
+
+	// ErrEmptyCharset is returned when a secret is generated from an empty
+	// character set.
+	ErrEmptyCharset xerrors.Error = "character set cannot be empty"
+)
+
+// Buffer is a fixed-size byte slice holding a secret. Call Destroy once the
+// secret is no longer needed.
+type Buffer struct {
+	b      []byte
+	locked bool
+}
+
+// NewBuffer returns a zeroed Buffer of the given size.
+func NewBuffer(size int) *Buffer {
+	return &Buffer{
+		b: make([]byte, size),
+	}
+}
+
+// Bytes returns the contents of the buffer. The slice is only valid until
+// Destroy is called.
+func (b *Buffer) Bytes() []byte {
+	return b.b
+}
+
+// Lock locks the buffer into memory, so it is never written to swap.
+func (b *Buffer) Lock() error {
+	if b.locked || len(b.b) == 0 {
+		return nil
+	}
+
+	if err := lock(b.b); err != nil {
+		return fmt.Errorf("failed to lock memory: %w", err)
+	}
+
+	b.locked = true
+
+	return nil
+}
+
+// Destroy zeroes the buffer and unlocks it from memory.
+func (b *Buffer) Destroy() {
+	Zero(b.b)
+
+	if b.locked {
+		_ = unlock(b.b)
+
+		b.locked = false
+	}
+}
+
+// Zero overwrites b with zeroes.
+func Zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+
+	runtime.KeepAlive(b)
+}
+
+// Fill fills b with characters picked uniformly at random from charset, which
+// must have at most 256 characters. The random bytes used to pick them are
+// zeroed before returning.
+func Fill(b []byte, charset string) error {
+	return FillFrom(rand.Reader, b, charset)
+}
+
+// FillFrom is like Fill, but reads the bytes used to pick characters from r,
+// so that a deterministic reader always gives the same secret.
+func FillFrom(r io.Reader, b []byte, charset string) error {
+	if charset == "" {
+		return ErrEmptyCharset
+	}
+
+	var (
+		scratch = make([]byte, len(b)+len(b)/2+1)
+		limit   = 256 - 256%len(charset)
+	)
+
+	defer Zero(scratch)
+
+	for i := 0; i < len(b); {
+		if _, err := io.ReadFull(r, scratch); err != nil {
+			return fmt.Errorf("failed to read random bytes: %w", err)
+		}
+
+		for _, c := range scratch {
+			if int(c) >= limit {
+				continue
+			}
+
+			b[i] = charset[int(c)%len(charset)]
+			i++
+
+			if i == len(b) {
+				break
+			}
+		}
+	}
+
+	return nil
+}
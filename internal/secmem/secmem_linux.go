@@ -0,0 +1,32 @@
+package secmem
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// DisableCoreDumps sets the core file size limit of the process to zero and
+// marks it as not dumpable, so secrets in memory never end up in a core dump
+// and other processes of the same user cannot attach to it.
+func DisableCoreDumps() error {
+	if err := unix.Setrlimit(unix.RLIMIT_CORE, &unix.Rlimit{}); err != nil {
+		return fmt.Errorf("failed to set core file size limit: %w", err)
+	}
+
+	if err := unix.Prctl(unix.PR_SET_DUMPABLE, 0, 0, 0, 0); err != nil {
+		return fmt.Errorf("failed to mark process as not dumpable: %w", err)
+	}
+
+	return nil
+}
+
+// lock locks b into memory.
+func lock(b []byte) error {
+	return unix.Mlock(b) //nolint:wrapcheck // wrapped by the caller
+}
+
+// unlock unlocks b from memory.
+func unlock(b []byte) error {
+	return unix.Munlock(b) //nolint:wrapcheck // wrapped by the caller
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package secmem
+
+// DisableCoreDumps is only supported on Linux.
+func DisableCoreDumps() error {
+	return ErrUnsupported
+}
+
+// lock is only supported on Linux.
+func lock(_ []byte) error {
+	return ErrUnsupported
+}
+
+// unlock is only supported on Linux.
+func unlock(_ []byte) error {
+	return ErrUnsupported
+}
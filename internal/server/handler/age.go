@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/cerrors"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/database"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/keygen"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/logging"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/passhash"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/server/middleware"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/server/model"
+	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
+	"go.uber.org/zap"
+)
+
+// AgeHandler is an HTTP handler for the /age endpoint.
+type AgeHandler struct {
+	db      *database.DB
+	limiter *passhash.Limiter
+	logger  *zap.Logger
+}
+
+// NewAgeHandler returns a new AgeHandler instance.
+func NewAgeHandler(db *database.DB, limiter *passhash.Limiter, logger *zap.Logger) *AgeHandler {
+	return &AgeHandler{
+		db:      db,
+		limiter: limiter,
+		logger:  logger,
+	}
+}
+
+// ServeHTTP handles HTTP requests for the /age endpoint.
+func (h *AgeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.logger)
+
+	hashOpts, err := parseHashOptions(r.URL.Query(), h.limiter, 1)
+	if err != nil {
+		writeHashOptionsError(w, logger, err)
+
+		return
+	}
+
+	identity, recipient, err := keygen.Age()
+	if err != nil {
+		logger.Error("error generating age key", zap.Error(err))
+
+		cerrors.JSON(w, logger, cerrors.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Cannot generate age key. Please try again later.",
+		})
+
+		return
+	}
+
+	middleware.RecordEntropy(r, keygen.KeyEntropy)
+	middleware.RecordIssued(r, []byte(identity))
+
+	hashes, err := hashOpts.hash(r.Context(), identity)
+	if err != nil {
+		writeHashError(w, logger, err)
+
+		return
+	}
+
+	contentType := r.Header.Get(xhttp.ContentType)
+
+	if contentType == xhttp.ApplicationJSON {
+		w.Header().Set(xhttp.ContentType, xhttp.ApplicationJSON)
+
+		keyModel := model.NewAgeKey(identity, recipient)
+		keyModel.Hashes = hashes
+
+		keyJSON, _ := json.Marshal(keyModel)
+
+		_, err = w.Write(keyJSON)
+		if err != nil {
+			logger.Error("error writing response", zap.Error(err))
+
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusInternalServerError,
+				Message: "Cannot write response. Please try again later.",
+			})
+
+			return
+		}
+	} else {
+		w.Header().Set(xhttp.ContentType, xhttp.TextPlain)
+
+		// Use the same format as age-keygen(1), so the response can be saved
+		// as an identity file as-is.
+		_, err = w.Write([]byte(withHashes("# public key: "+recipient+"\n"+identity+"\n", hashes)))
+		if err != nil {
+			logger.Error("error writing response", zap.Error(err))
+
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusInternalServerError,
+				Message: "Cannot write response. Please try again later.",
+			})
+
+			return
+		}
+	}
+
+	middleware.CountIssued(r, h.db, database.CounterTypeAge, logger)
+}
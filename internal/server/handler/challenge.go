@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/cerrors"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/logging"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/pow"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/server/middleware"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/server/model"
+	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
+	"go.uber.org/zap"
+)
+
+// ChallengeHandler is an HTTP handler for the /challenge endpoint.
+type ChallengeHandler struct {
+	gate   *pow.Gate
+	logger *zap.Logger
+}
+
+// NewChallengeHandler returns a new ChallengeHandler instance.
+func NewChallengeHandler(gate *pow.Gate, logger *zap.Logger) *ChallengeHandler {
+	return &ChallengeHandler{
+		gate:   gate,
+		logger: logger,
+	}
+}
+
+// ServeHTTP handles HTTP requests for the /challenge endpoint. Plain text
+// responses hold the challenge on the first line and its difficulty on the
+// second.
+func (h *ChallengeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.logger)
+
+	challenge, err := h.gate.Issue()
+	if err != nil {
+		logger.Error("error issuing challenge", zap.Error(err))
+
+		cerrors.JSON(w, logger, cerrors.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Cannot issue challenge. Please try again later.",
+		})
+
+		return
+	}
+
+	contentType := r.Header.Get(xhttp.ContentType)
+
+	if contentType == xhttp.ApplicationJSON {
+		w.Header().Set(xhttp.ContentType, xhttp.ApplicationJSON)
+
+		challengeJSON, _ := json.Marshal(model.NewChallenge(challenge.Token, middleware.ProofOfWorkHeader, challenge.Difficulty, challenge.ExpiresAt))
+
+		_, err = w.Write(challengeJSON)
+	} else {
+		w.Header().Set(xhttp.ContentType, xhttp.TextPlain)
+
+		_, err = w.Write([]byte(challenge.Token + "\n" + strconv.Itoa(challenge.Difficulty)))
+	}
+
+	if err != nil {
+		logger.Error("error writing response", zap.Error(err))
+
+		cerrors.JSON(w, logger, cerrors.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Cannot write response. Please try again later.",
+		})
+
+		return
+	}
+}
@@ -0,0 +1,184 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/cerrors"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/logging"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/secmem"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/shamir"
+	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
+	"go.uber.org/zap"
+)
+
+// MaxCombineSize is the maximum size in bytes of the body of a request to the
+// /combine endpoint.
+const MaxCombineSize int = 1 << 20
+
+// CombineHandler is an HTTP handler for the /combine endpoint.
+type CombineHandler struct {
+	logger     *zap.Logger
+	lockMemory bool
+}
+
+// NewCombineHandler returns a new CombineHandler instance.
+func NewCombineHandler(lockMemory bool, logger *zap.Logger) *CombineHandler {
+	return &CombineHandler{
+		logger:     logger,
+		lockMemory: lockMemory,
+	}
+}
+
+// ServeHTTP handles HTTP requests for the /combine endpoint. Shares are sent in
+// the body of a POST request, one per line or as JSON, and the secret they
+// were split from is returned as it was originally sent.
+func (h *CombineHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.logger)
+
+	if r.Method != http.MethodPost {
+		cerrors.JSON(w, logger, cerrors.ErrorResponse{
+			Code:    http.StatusMethodNotAllowed,
+			Message: "Method " + r.Method + " not allowed. Must be POST.",
+		})
+
+		return
+	}
+
+	body, data, ok := readSecret(w, r, false, "", "set of shares", MaxCombineSize, h.lockMemory, logger)
+	if !ok {
+		return
+	}
+	defer body.Destroy()
+
+	var encoded [][]byte
+
+	if r.Header.Get(xhttp.ContentType) == xhttp.ApplicationJSON {
+		decoded, err := decodeShares(data)
+		if err != nil {
+			logger.Error("error parsing request body", zap.Error(err))
+
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusBadRequest,
+				Message: "Cannot parse the request body. Please provide a JSON object with a shares field.",
+			})
+
+			return
+		}
+
+		defer func() {
+			for _, share := range decoded {
+				secmem.Zero(share)
+			}
+		}()
+
+		encoded = decoded
+	} else {
+		encoded = bytes.Fields(data)
+	}
+
+	shares := make([]*shamir.Share, 0, len(encoded))
+
+	defer func() {
+		for _, share := range shares {
+			secmem.Zero(share.Value)
+		}
+	}()
+
+	for i, s := range encoded {
+		share, err := shamir.ParseShare(s)
+		if err != nil {
+			logger.Error("error parsing share", zap.Error(err))
+
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusBadRequest,
+				Message: "Cannot parse share " + strconv.Itoa(i+1) + ". Please check it was copied correctly.",
+			})
+
+			return
+		}
+
+		shares = append(shares, share)
+	}
+
+	secret, err := shamir.Combine(shares)
+	if err != nil {
+		logger.Error("error combining shares", zap.Error(err))
+
+		message := "Cannot combine the given shares. Please check they all belong to the same secret."
+
+		switch {
+		case errors.Is(err, shamir.ErrNotEnoughShares):
+			message = "Not enough shares to recover the secret. Please provide at least as many shares as the threshold."
+		case errors.Is(err, shamir.ErrDuplicateShare):
+			message = "The same share was given twice. Please provide distinct shares."
+		case errors.Is(err, shamir.ErrIntegrity):
+			message = "The combined secret failed its integrity check. At least one share was altered."
+		}
+
+		cerrors.JSON(w, logger, cerrors.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: message,
+		})
+
+		return
+	}
+
+	defer secmem.Zero(secret)
+
+	if shares[0].Format == shamir.FormatJSON {
+		w.Header().Set(xhttp.ContentType, xhttp.ApplicationJSON)
+	} else {
+		w.Header().Set(xhttp.ContentType, xhttp.TextPlain)
+	}
+
+	if _, err := w.Write(secret); err != nil {
+		logger.Error("error writing response", zap.Error(err))
+	}
+}
+
+// decodeShares decodes the strings of the shares field of the JSON object in
+// data into byte slices the caller must wipe, and wipes every other copy made
+// along the way.
+func decodeShares(data []byte) ([][]byte, error) {
+	var request struct {
+		Shares []json.RawMessage `json:"shares"`
+	}
+
+	err := json.Unmarshal(data, &request)
+
+	defer func() {
+		for _, value := range request.Shares {
+			secmem.Zero(value)
+		}
+	}()
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode JSON object: %w", err)
+	}
+
+	shares := make([][]byte, 0, len(request.Shares))
+
+	for _, value := range request.Shares {
+		share := make([]byte, len(value))
+
+		n, err := unquoteJSON(share, value)
+		if err != nil {
+			secmem.Zero(share)
+
+			for _, decoded := range shares {
+				secmem.Zero(decoded)
+			}
+
+			return nil, fmt.Errorf("failed to decode share: %w", err)
+		}
+
+		shares = append(shares, share[:n])
+	}
+
+	return shares, nil
+}
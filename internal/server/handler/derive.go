@@ -0,0 +1,189 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/cerrors"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/database"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/derive"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/logging"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/passhash"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/secmem"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/server/middleware"
+	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
+	"go.uber.org/zap"
+)
+
+// MaxMasterSize is the maximum size in bytes of the master secret sent to the
+// /derive endpoint.
+const MaxMasterSize int = 1024
+
+// DeriveHandler is an HTTP handler for the /derive endpoint.
+type DeriveHandler struct {
+	db         *database.DB
+	limiter    *passhash.Limiter
+	logger     *zap.Logger
+	lockMemory bool
+}
+
+// NewDeriveHandler returns a new DeriveHandler instance.
+func NewDeriveHandler(db *database.DB, limiter *passhash.Limiter, lockMemory bool, logger *zap.Logger) *DeriveHandler {
+	return &DeriveHandler{
+		db:         db,
+		limiter:    limiter,
+		logger:     logger,
+		lockMemory: lockMemory,
+	}
+}
+
+// ServeHTTP handles HTTP requests for the /derive endpoint. The master secret
+// is sent in the body of a POST request, as is or as JSON, so that it never
+// shows up in a URL, and the site, login, and counter in the query string.
+func (h *DeriveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.logger)
+
+	if r.Method != http.MethodPost {
+		cerrors.JSON(w, logger, cerrors.ErrorResponse{
+			Code:    http.StatusMethodNotAllowed,
+			Message: "Method " + r.Method + " not allowed. Must be POST.",
+		})
+
+		return
+	}
+
+	var (
+		site    = r.URL.Query().Get("site")
+		login   = r.URL.Query().Get("login")
+		counter = derive.DefaultCounter
+	)
+
+	if site == "" {
+		logger.Error("missing site")
+
+		cerrors.JSON(w, logger, cerrors.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Missing site. Please provide the site to derive a password for.",
+		})
+
+		return
+	}
+
+	if r.URL.Query().Get("counter") != "" {
+		value, err := strconv.ParseUint(r.URL.Query().Get("counter"), 10, 32)
+		if err != nil || value < 1 {
+			logger.Error("error parsing counter", zap.String("counter", r.URL.Query().Get("counter")))
+
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusBadRequest,
+				Message: "Cannot parse the given counter. Please provide an integer between 1 and " + strconv.FormatUint(1<<32-1, 10) + ".",
+			})
+
+			return
+		}
+
+		counter = uint32(value)
+	}
+
+	length, charset, ok := parseCharsetOptions(w, r, logger)
+	if !ok {
+		return
+	}
+
+	hashOpts, err := parseHashOptions(r.URL.Query(), h.limiter, 1)
+	if err != nil {
+		writeHashOptionsError(w, logger, err)
+
+		return
+	}
+
+	asJSON := r.Header.Get(xhttp.ContentType) == xhttp.ApplicationJSON
+
+	body, master, ok := readSecret(w, r, asJSON, "master", "master secret", MaxMasterSize, h.lockMemory, logger)
+	if !ok {
+		return
+	}
+	defer body.Destroy()
+
+	if len(master) == 0 {
+		logger.Error("missing master secret")
+
+		cerrors.JSON(w, logger, cerrors.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Missing master secret. Please send it in the request body.",
+		})
+
+		return
+	}
+
+	password := secmem.NewBuffer(length)
+	defer password.Destroy()
+
+	if h.lockMemory {
+		if err := password.Lock(); err != nil {
+			logger.Warn("failed to lock password buffer", zap.Error(err))
+		}
+	}
+
+	// Every derivation takes 64 MiB of memory, so they share the hashing
+	// slots of the server.
+	release, err := h.limiter.Acquire(r.Context())
+	if err != nil {
+		logger.Error("error waiting for a hashing slot", zap.Error(err))
+
+		cerrors.JSON(w, logger, cerrors.ErrorResponse{
+			Code:    http.StatusServiceUnavailable,
+			Message: "Cannot derive password right now. Please try again later.",
+		})
+
+		return
+	}
+
+	err = derive.Password(password.Bytes(), master, site, login, counter, charset)
+
+	release()
+
+	if err != nil {
+		logger.Error("error deriving password", zap.Error(err))
+
+		if errors.Is(err, derive.ErrEmptySite) {
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusBadRequest,
+				Message: "Missing site. Please provide the site to derive a password for.",
+			})
+
+			return
+		}
+
+		cerrors.JSON(w, logger, cerrors.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Cannot derive password. Please try again later.",
+		})
+
+		return
+	}
+
+	// No entropy is recorded for the audit log, since a derived password is
+	// only as strong as the master secret it was derived from.
+
+	hashes, err := hashOpts.hashBytes(r.Context(), password.Bytes())
+	if err != nil {
+		writeHashError(w, logger, err)
+
+		return
+	}
+
+	if err := writeSecret(w, asJSON, "derived", password.Bytes(), hashes, h.lockMemory, logger); err != nil {
+		logger.Error("error writing response", zap.Error(err))
+
+		cerrors.JSON(w, logger, cerrors.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Cannot write response. Please try again later.",
+		})
+
+		return
+	}
+
+	middleware.CountIssued(r, h.db, database.CounterTypeDerived, logger)
+}
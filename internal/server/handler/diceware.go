@@ -2,11 +2,15 @@ package handler
 
 import (
 	"encoding/json"
+	"math"
 	"net/http"
 	"strconv"
 
 	"git.sr.ht/~jamesponddotco/acciopassword/internal/cerrors"
 	"git.sr.ht/~jamesponddotco/acciopassword/internal/database"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/logging"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/passhash"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/server/middleware"
 	"git.sr.ht/~jamesponddotco/acciopassword/internal/server/model"
 	"git.sr.ht/~jamesponddotco/acopw-go"
 	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
@@ -19,24 +23,32 @@ const (
 
 	// MaxDicewareLength is the maximum length of a diceware password.
 	MaxDicewareLength int = 64
+
+	// dicewareWords is the number of words in the list diceware passwords are
+	// drawn from, as of acopw-go v0.1.0.
+	dicewareWords int = 23453
 )
 
 // DicewareHandler is an HTTP handler for the /diceware endpoint.
 type DicewareHandler struct {
-	db     *database.DB
-	logger *zap.Logger
+	db      *database.DB
+	limiter *passhash.Limiter
+	logger  *zap.Logger
 }
 
 // NewDicewareHandler returns a new DicewareHandler instance.
-func NewDicewareHandler(db *database.DB, logger *zap.Logger) *DicewareHandler {
+func NewDicewareHandler(db *database.DB, limiter *passhash.Limiter, logger *zap.Logger) *DicewareHandler {
 	return &DicewareHandler{
-		db:     db,
-		logger: logger,
+		db:      db,
+		limiter: limiter,
+		logger:  logger,
 	}
 }
 
 // ServeHTTP handles HTTP requests for the /diceware endpoint.
 func (h *DicewareHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.logger)
+
 	var (
 		length = acopw.DefaultDicewareLength
 		err    error
@@ -45,9 +57,9 @@ func (h *DicewareHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Query().Get("length") != "" {
 		length, err = strconv.Atoi(r.URL.Query().Get("length"))
 		if err != nil {
-			h.logger.Error("error parsing diceware length", zap.Error(err))
+			logger.Error("error parsing diceware length", zap.Error(err))
 
-			cerrors.JSON(w, h.logger, cerrors.ErrorResponse{
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
 				Code:    http.StatusInternalServerError,
 				Message: "Cannot parse the given diceware length. Please provide a valid integer.",
 			})
@@ -60,9 +72,9 @@ func (h *DicewareHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 
 		if length > MaxDicewareLength {
-			h.logger.Error("password length is too long", zap.Int("length", length))
+			logger.Error("password length is too long", zap.Int("length", length))
 
-			cerrors.JSON(w, h.logger, cerrors.ErrorResponse{
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
 				Code:    http.StatusBadRequest,
 				Message: "The given password length is too long. Please provide a length less than or equal to " + strconv.Itoa(MaxDicewareLength) + ".",
 			})
@@ -80,6 +92,13 @@ func (h *DicewareHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		separator = DefaultDicewareSeparator
 	}
 
+	hashOpts, err := parseHashOptions(r.URL.Query(), h.limiter, 1)
+	if err != nil {
+		writeHashOptionsError(w, logger, err)
+
+		return
+	}
+
 	diceware := &acopw.Diceware{
 		Separator:  separator,
 		Capitalize: capitalize,
@@ -88,9 +107,9 @@ func (h *DicewareHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	password, err := diceware.Generate()
 	if err != nil {
-		h.logger.Error("error generating diceware password", zap.Error(err))
+		logger.Error("error generating diceware password", zap.Error(err))
 
-		cerrors.JSON(w, h.logger, cerrors.ErrorResponse{
+		cerrors.JSON(w, logger, cerrors.ErrorResponse{
 			Code:    http.StatusInternalServerError,
 			Message: "Cannot generate diceware password. Please try again later.",
 		})
@@ -98,21 +117,31 @@ func (h *DicewareHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	middleware.RecordEntropy(r, float64(length)*math.Log2(float64(dicewareWords)))
+	middleware.RecordIssued(r, []byte(password))
+
+	hashes, err := hashOpts.hash(r.Context(), password)
+	if err != nil {
+		writeHashError(w, logger, err)
+
+		return
+	}
+
 	contentType := r.Header.Get(xhttp.ContentType)
 
 	if contentType == xhttp.ApplicationJSON {
 		w.Header().Set(xhttp.ContentType, xhttp.ApplicationJSON)
 
-		var (
-			passwordModel   = model.NewDicewarePassword(password)
-			passwordJSON, _ = json.Marshal(passwordModel)
-		)
+		passwordModel := model.NewDicewarePassword(password)
+		passwordModel.Hashes = hashes
+
+		passwordJSON, _ := json.Marshal(passwordModel)
 
 		_, err = w.Write(passwordJSON)
 		if err != nil {
-			h.logger.Error("error writing response", zap.Error(err))
+			logger.Error("error writing response", zap.Error(err))
 
-			cerrors.JSON(w, h.logger, cerrors.ErrorResponse{
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
 				Code:    http.StatusInternalServerError,
 				Message: "Cannot write response. Please try again later.",
 			})
@@ -122,11 +151,11 @@ func (h *DicewareHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	} else {
 		w.Header().Set(xhttp.ContentType, xhttp.TextPlain)
 
-		_, err = w.Write([]byte(password))
+		_, err = w.Write([]byte(withHashes(password, hashes)))
 		if err != nil {
-			h.logger.Error("error writing response", zap.Error(err))
+			logger.Error("error writing response", zap.Error(err))
 
-			cerrors.JSON(w, h.logger, cerrors.ErrorResponse{
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
 				Code:    http.StatusInternalServerError,
 				Message: "Cannot write response. Please try again later.",
 			})
@@ -135,9 +164,5 @@ func (h *DicewareHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	go func() {
-		if err := h.db.Increment(database.CounterTypeDiceware); err != nil {
-			h.logger.Error("Failed to increment access counter", zap.Error(err))
-		}
-	}()
+	middleware.CountIssued(r, h.db, database.CounterTypeDiceware, logger)
 }
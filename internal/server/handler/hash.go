@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/cerrors"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/passhash"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/server/model"
+	"go.uber.org/zap"
+)
+
+// hashOptions holds the hashes a client requested alongside a generated
+// secret.
+type hashOptions struct {
+	limiter    *passhash.Limiter
+	params     *passhash.Params
+	algorithms []passhash.Algorithm
+}
+
+// parseHashOptions reads the hashing options from the query string of a
+// request and validates them against the limits of limiter, for count secrets
+// hashed with every requested algorithm.
+//
+// Algorithms are given as a comma-separated list in the hash parameter, and
+// their costs in parameters named after the fields of passhash.Params.
+func parseHashOptions(query url.Values, limiter *passhash.Limiter, count int) (*hashOptions, error) {
+	opts := &hashOptions{
+		limiter: limiter,
+		params:  passhash.DefaultParams(),
+	}
+
+	seen := make(map[passhash.Algorithm]struct{})
+
+	for _, value := range query["hash"] {
+		for _, name := range strings.Split(value, ",") {
+			if strings.TrimSpace(name) == "" {
+				continue
+			}
+
+			algorithm, err := passhash.ParseAlgorithm(name)
+			if err != nil {
+				return nil, err //nolint:wrapcheck // the error is already descriptive
+			}
+
+			if _, ok := seen[algorithm]; ok {
+				continue
+			}
+
+			seen[algorithm] = struct{}{}
+			opts.algorithms = append(opts.algorithms, algorithm)
+		}
+	}
+
+	ints := []struct {
+		dst  *int
+		name string
+	}{
+		{&opts.params.BcryptCost, "bcryptCost"},
+		{&opts.params.ScryptLogN, "scryptLogN"},
+		{&opts.params.ScryptR, "scryptR"},
+		{&opts.params.ScryptP, "scryptP"},
+		{&opts.params.PBKDF2Iterations, "pbkdf2Iterations"},
+		{&opts.params.SHACryptRounds, "shaCryptRounds"},
+		{&opts.params.SCRAMIterations, "scramIterations"},
+		{&opts.params.MySQLIterations, "mysqlIterations"},
+	}
+
+	for _, param := range ints {
+		if query.Get(param.name) == "" {
+			continue
+		}
+
+		value, err := strconv.Atoi(query.Get(param.name))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s must be an integer", passhash.ErrInvalidParams, param.name)
+		}
+
+		*param.dst = value
+	}
+
+	if query.Get("argon2Time") != "" {
+		value, err := strconv.ParseUint(query.Get("argon2Time"), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%w: argon2Time must be an integer", passhash.ErrInvalidParams)
+		}
+
+		opts.params.Argon2Time = uint32(value)
+	}
+
+	if query.Get("argon2Memory") != "" {
+		value, err := strconv.ParseUint(query.Get("argon2Memory"), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%w: argon2Memory must be an integer", passhash.ErrInvalidParams)
+		}
+
+		opts.params.Argon2Memory = uint32(value)
+	}
+
+	if query.Get("argon2Threads") != "" {
+		value, err := strconv.ParseUint(query.Get("argon2Threads"), 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("%w: argon2Threads must be an integer", passhash.ErrInvalidParams)
+		}
+
+		opts.params.Argon2Threads = uint8(value)
+	}
+
+	if err := limiter.Validate(opts.params, opts.algorithms, count); err != nil {
+		return nil, err //nolint:wrapcheck // the error is already descriptive
+	}
+
+	return opts, nil
+}
+
+// hash returns the requested hashes of secret, or nil if none were requested.
+func (o *hashOptions) hash(ctx context.Context, secret string) ([]model.Hash, error) {
+	return o.hashBytes(ctx, []byte(secret))
+}
+
+// hashBytes is like hash, but takes the secret as a byte slice, for secrets
+// that never become strings.
+func (o *hashOptions) hashBytes(ctx context.Context, secret []byte) ([]model.Hash, error) {
+	if len(o.algorithms) == 0 {
+		return nil, nil
+	}
+
+	hashes := make([]model.Hash, 0, len(o.algorithms))
+
+	for _, algorithm := range o.algorithms {
+		hash, err := o.limiter.Hash(ctx, algorithm, secret, o.params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash secret with %s: %w", algorithm, err)
+		}
+
+		hashes = append(hashes, model.Hash{
+			Algorithm: string(algorithm),
+			Hash:      hash,
+		})
+	}
+
+	return hashes, nil
+}
+
+// withHashes appends each hash to text on its own line, for plain text
+// responses.
+func withHashes(text string, hashes []model.Hash) string {
+	if len(hashes) == 0 {
+		return text
+	}
+
+	var builder strings.Builder
+
+	builder.WriteString(strings.TrimRight(text, "\n"))
+
+	for _, hash := range hashes {
+		builder.WriteString("\n")
+		builder.WriteString(hash.Hash)
+	}
+
+	builder.WriteString("\n")
+
+	return builder.String()
+}
+
+// writeHashOptionsError sends the error response for invalid hashing options.
+func writeHashOptionsError(w http.ResponseWriter, logger *zap.Logger, err error) {
+	logger.Error("error parsing hashing options", zap.Error(err))
+
+	cerrors.JSON(w, logger, cerrors.ErrorResponse{
+		Code:    http.StatusBadRequest,
+		Message: "Cannot use the given hashing options: " + err.Error() + ".",
+	})
+}
+
+// writeHashError sends the error response for a secret that could not be
+// hashed.
+func writeHashError(w http.ResponseWriter, logger *zap.Logger, err error) {
+	logger.Error("error hashing secret", zap.Error(err))
+
+	if errors.Is(err, passhash.ErrSecretTooLong) {
+		cerrors.JSON(w, logger, cerrors.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "The generated secret is too long for the requested hashing algorithm. Please request a shorter secret or a different algorithm.",
+		})
+
+		return
+	}
+
+	cerrors.JSON(w, logger, cerrors.ErrorResponse{
+		Code:    http.StatusInternalServerError,
+		Message: "Cannot hash the generated secret. Please try again later.",
+	})
+}
@@ -7,6 +7,8 @@ import (
 	"git.sr.ht/~jamesponddotco/acciopassword/internal/build"
 	"git.sr.ht/~jamesponddotco/acciopassword/internal/cerrors"
 	"git.sr.ht/~jamesponddotco/acciopassword/internal/database"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/logging"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/rnghealth"
 	"git.sr.ht/~jamesponddotco/acciopassword/internal/server/model"
 	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
 	"go.uber.org/zap"
@@ -18,31 +20,46 @@ const (
 
 	// Offline is the status of a service that is offline.
 	Offline string = "Offline"
+
+	// Failed is the status of a service that failed its health tests.
+	Failed string = "Failed"
 )
 
 // HealthHandler is an HTTP handler for the /health endpoint.
 type HealthHandler struct {
-	db     *database.DB
-	logger *zap.Logger
+	db      *database.DB
+	monitor *rnghealth.Monitor
+	logger  *zap.Logger
 }
 
 // NewHealthHandler creates a new HealthHandler instance.
-func NewHealthHandler(db *database.DB, logger *zap.Logger) *HealthHandler {
+func NewHealthHandler(db *database.DB, monitor *rnghealth.Monitor, logger *zap.Logger) *HealthHandler {
 	return &HealthHandler{
-		db:     db,
-		logger: logger,
+		db:      db,
+		monitor: monitor,
+		logger:  logger,
 	}
 }
 
 // ServeHTTP serves the /health endpoint.
-func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.logger)
+
 	databaseStatus := Online
 
 	err := h.db.Ping()
 	if err != nil {
 		databaseStatus = Offline
 
-		h.logger.Warn("Database is offline", zap.Error(err))
+		logger.Warn("Database is offline", zap.Error(err))
+	}
+
+	rngStatus := Online
+
+	if err := h.monitor.Err(); err != nil {
+		rngStatus = Failed
+
+		logger.Warn("Randomness source failed its health tests", zap.Error(err))
 	}
 
 	var (
@@ -51,6 +68,10 @@ func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
 				Service: "sqlite",
 				Status:  databaseStatus,
 			},
+			{
+				Service: "rng",
+				Status:  rngStatus,
+			},
 		}
 		status = model.NewHealth(build.Name, build.Version, dependencies)
 	)
@@ -61,9 +82,9 @@ func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
 
 	_, err = w.Write(statusJSON)
 	if err != nil {
-		h.logger.Error("Failed to write status JSON to response", zap.Error(err))
+		logger.Error("Failed to write status JSON to response", zap.Error(err))
 
-		cerrors.JSON(w, h.logger, cerrors.ErrorResponse{
+		cerrors.JSON(w, logger, cerrors.ErrorResponse{
 			Code:    http.StatusInternalServerError,
 			Message: "Failed to write status JSON to response.",
 		})
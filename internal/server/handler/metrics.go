@@ -6,6 +6,8 @@ import (
 
 	"git.sr.ht/~jamesponddotco/acciopassword/internal/cerrors"
 	"git.sr.ht/~jamesponddotco/acciopassword/internal/database"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/logging"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/server/middleware"
 	"git.sr.ht/~jamesponddotco/acciopassword/internal/server/model"
 	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
 	"go.uber.org/zap"
@@ -26,24 +28,43 @@ func NewMetricsHandler(db *database.DB, logger *zap.Logger) *MetricsHandler {
 }
 
 // ServeHTTP serves the /metrics endpoint.
-func (h *MetricsHandler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+func (h *MetricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.logger)
+
 	var (
-		countDiceware = h.db.Count(database.CounterTypeDiceware)
-		countRandom   = h.db.Count(database.CounterTypeRandom)
-		countPIN      = h.db.Count(database.CounterTypePIN)
-		countTotal    = countDiceware + countRandom + countPIN
-		counter       = model.NewMetrics(countRandom, countDiceware, countPIN, countTotal)
+		countDiceware      = h.db.Count(database.CounterTypeDiceware)
+		countRandom        = h.db.Count(database.CounterTypeRandom)
+		countPIN           = h.db.Count(database.CounterTypePIN)
+		countWireGuard     = h.db.Count(database.CounterTypeWireGuard)
+		countAge           = h.db.Count(database.CounterTypeAge)
+		countMnemonic      = h.db.Count(database.CounterTypeMnemonic)
+		countPronounceable = h.db.Count(database.CounterTypePronounceable)
+		countRecoveryCodes = h.db.Count(database.CounterTypeRecoveryCodes)
+		countDerived       = h.db.Count(database.CounterTypeDerived)
+		countTotal         = countDiceware + countRandom + countPIN + countWireGuard + countAge + countMnemonic + countPronounceable + countRecoveryCodes + countDerived
+		counter            = model.NewMetrics(countRandom, countDiceware, countPIN, countWireGuard, countAge, countMnemonic, countPronounceable, countRecoveryCodes, countDerived, countTotal)
 	)
 
+	// Clients only ever see their own counter, so that the identities and
+	// activity of other clients are not disclosed.
+	if identity, ok := middleware.ClientIdentityFromContext(r.Context()); ok {
+		count, err := h.db.ClientCount(identity)
+		if err != nil {
+			logger.Error("Failed to get client counter", zap.Error(err))
+		} else {
+			counter.Clients = map[string]uint64{identity: count}
+		}
+	}
+
 	counterJSON, _ := json.Marshal(counter)
 
 	w.Header().Set(xhttp.ContentType, xhttp.ApplicationJSON)
 
 	_, err := w.Write(counterJSON)
 	if err != nil {
-		h.logger.Error("Failed to write access counter JSON to response", zap.Error(err))
+		logger.Error("Failed to write access counter JSON to response", zap.Error(err))
 
-		cerrors.JSON(w, h.logger, cerrors.ErrorResponse{
+		cerrors.JSON(w, logger, cerrors.ErrorResponse{
 			Code:    http.StatusInternalServerError,
 			Message: "Failed to write access counter JSON to response.",
 		})
@@ -0,0 +1,276 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/cerrors"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/database"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/logging"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/mnemonic"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/passhash"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/server/middleware"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/server/model"
+	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
+	"go.uber.org/zap"
+)
+
+// MnemonicHandler is an HTTP handler for the /mnemonic endpoint.
+type MnemonicHandler struct {
+	db      *database.DB
+	limiter *passhash.Limiter
+	logger  *zap.Logger
+}
+
+// NewMnemonicHandler returns a new MnemonicHandler instance.
+func NewMnemonicHandler(db *database.DB, limiter *passhash.Limiter, logger *zap.Logger) *MnemonicHandler {
+	return &MnemonicHandler{
+		db:      db,
+		limiter: limiter,
+		logger:  logger,
+	}
+}
+
+// ServeHTTP handles HTTP requests for the /mnemonic endpoint.
+func (h *MnemonicHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.logger)
+
+	var (
+		bits     = mnemonic.DefaultEntropyBits
+		language = mnemonic.DefaultLanguage
+		err      error
+	)
+
+	if r.URL.Query().Get("bits") != "" {
+		bits, err = strconv.Atoi(r.URL.Query().Get("bits"))
+		if err != nil {
+			logger.Error("error parsing mnemonic entropy", zap.Error(err))
+
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusBadRequest,
+				Message: "Cannot parse the given number of bits. Please provide a valid integer.",
+			})
+
+			return
+		}
+
+		if bits < mnemonic.MinEntropyBits || bits > mnemonic.MaxEntropyBits || bits%32 != 0 {
+			logger.Error("invalid mnemonic entropy", zap.Int("bits", bits))
+
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusBadRequest,
+				Message: "The given number of bits is invalid. Please provide 128, 160, 192, 224, or 256.",
+			})
+
+			return
+		}
+	}
+
+	if r.URL.Query().Get("language") != "" {
+		language, err = mnemonic.ParseLanguage(r.URL.Query().Get("language"))
+		if err != nil {
+			logger.Error("error parsing mnemonic language", zap.Error(err))
+
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusBadRequest,
+				Message: "The given language is not supported. Please provide a language with a BIP 39 word list.",
+			})
+
+			return
+		}
+	}
+
+	hashOpts, err := parseHashOptions(r.URL.Query(), h.limiter, 1)
+	if err != nil {
+		writeHashOptionsError(w, logger, err)
+
+		return
+	}
+
+	phrase, err := mnemonic.Generate(bits, language)
+	if err != nil {
+		logger.Error("error generating mnemonic", zap.Error(err))
+
+		cerrors.JSON(w, logger, cerrors.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Cannot generate mnemonic. Please try again later.",
+		})
+
+		return
+	}
+
+	middleware.RecordEntropy(r, float64(bits))
+	middleware.RecordIssued(r, []byte(phrase))
+
+	hashes, err := hashOpts.hash(r.Context(), phrase)
+	if err != nil {
+		writeHashError(w, logger, err)
+
+		return
+	}
+
+	contentType := r.Header.Get(xhttp.ContentType)
+
+	if contentType == xhttp.ApplicationJSON {
+		w.Header().Set(xhttp.ContentType, xhttp.ApplicationJSON)
+
+		passwordModel := model.NewMnemonic(phrase)
+		passwordModel.Hashes = hashes
+
+		passwordJSON, _ := json.Marshal(passwordModel)
+
+		_, err = w.Write(passwordJSON)
+		if err != nil {
+			logger.Error("error writing response", zap.Error(err))
+
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusInternalServerError,
+				Message: "Cannot write response. Please try again later.",
+			})
+
+			return
+		}
+	} else {
+		w.Header().Set(xhttp.ContentType, xhttp.TextPlain)
+
+		_, err = w.Write([]byte(withHashes(phrase, hashes)))
+		if err != nil {
+			logger.Error("error writing response", zap.Error(err))
+
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusInternalServerError,
+				Message: "Cannot write response. Please try again later.",
+			})
+
+			return
+		}
+	}
+
+	middleware.CountIssued(r, h.db, database.CounterTypeMnemonic, logger)
+}
+
+// MaxPhraseSize is the maximum size in bytes of the mnemonic phrase sent to the
+// /mnemonic/validate endpoint.
+const MaxPhraseSize int = 4096
+
+// MnemonicValidationHandler is an HTTP handler for the /mnemonic/validate
+// endpoint.
+type MnemonicValidationHandler struct {
+	logger     *zap.Logger
+	lockMemory bool
+}
+
+// NewMnemonicValidationHandler returns a new MnemonicValidationHandler
+// instance.
+func NewMnemonicValidationHandler(lockMemory bool, logger *zap.Logger) *MnemonicValidationHandler {
+	return &MnemonicValidationHandler{
+		logger:     logger,
+		lockMemory: lockMemory,
+	}
+}
+
+// ServeHTTP handles HTTP requests for the /mnemonic/validate endpoint. The
+// phrase is sent in the body of a POST request, as is or as JSON, so that it
+// never shows up in a URL.
+func (h *MnemonicValidationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.logger)
+
+	if r.Method != http.MethodPost {
+		cerrors.JSON(w, logger, cerrors.ErrorResponse{
+			Code:    http.StatusMethodNotAllowed,
+			Message: "Method " + r.Method + " not allowed. Must be POST.",
+		})
+
+		return
+	}
+
+	var (
+		language = mnemonic.DefaultLanguage
+		asJSON   = r.Header.Get(xhttp.ContentType) == xhttp.ApplicationJSON
+		err      error
+	)
+
+	if r.URL.Query().Get("language") != "" {
+		language, err = mnemonic.ParseLanguage(r.URL.Query().Get("language"))
+		if err != nil {
+			logger.Error("error parsing mnemonic language", zap.Error(err))
+
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusBadRequest,
+				Message: "The given language is not supported. Please provide a language with a BIP 39 word list.",
+			})
+
+			return
+		}
+	}
+
+	body, phrase, ok := readSecret(w, r, asJSON, "phrase", "phrase", MaxPhraseSize, h.lockMemory, logger)
+	if !ok {
+		return
+	}
+	defer body.Destroy()
+
+	if len(phrase) == 0 {
+		cerrors.JSON(w, logger, cerrors.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "The phrase is missing. Please send the mnemonic to validate in the request body.",
+		})
+
+		return
+	}
+
+	var (
+		result      = model.NewMnemonicValidation()
+		message     = "The mnemonic is valid."
+		invalidWord *mnemonic.InvalidWordError
+	)
+
+	err = mnemonic.Validate(phrase, language)
+
+	switch {
+	case err == nil:
+	case errors.As(err, &invalidWord):
+		message = "Word " + strconv.Itoa(invalidWord.Position) + " is not in the " + string(language) + " word list."
+		result = model.NewInvalidMnemonicWord(message, invalidWord.Word, invalidWord.Position)
+	case errors.Is(err, mnemonic.ErrInvalidChecksum):
+		message = "The checksum does not match. One or more words are wrong or out of order."
+		result = model.NewInvalidMnemonic(message)
+	case errors.Is(err, mnemonic.ErrInvalidWordCount):
+		message = "The mnemonic must have 12, 15, 18, 21, or 24 words."
+		result = model.NewInvalidMnemonic(message)
+	default:
+		logger.Error("error validating mnemonic", zap.Error(err))
+
+		cerrors.JSON(w, logger, cerrors.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Cannot validate mnemonic. Please try again later.",
+		})
+
+		return
+	}
+
+	if asJSON {
+		w.Header().Set(xhttp.ContentType, xhttp.ApplicationJSON)
+
+		resultJSON, _ := json.Marshal(result)
+
+		_, err = w.Write(resultJSON)
+	} else {
+		w.Header().Set(xhttp.ContentType, xhttp.TextPlain)
+
+		_, err = w.Write([]byte(message))
+	}
+
+	if err != nil {
+		logger.Error("error writing response", zap.Error(err))
+
+		cerrors.JSON(w, logger, cerrors.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Cannot write response. Please try again later.",
+		})
+
+		return
+	}
+}
@@ -1,13 +1,16 @@
 package handler
 
 import (
-	"encoding/json"
+	"math"
 	"net/http"
 	"strconv"
 
 	"git.sr.ht/~jamesponddotco/acciopassword/internal/cerrors"
 	"git.sr.ht/~jamesponddotco/acciopassword/internal/database"
-	"git.sr.ht/~jamesponddotco/acciopassword/internal/server/model"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/logging"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/passhash"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/secmem"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/server/middleware"
 	"git.sr.ht/~jamesponddotco/acopw-go"
 	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
 	"go.uber.org/zap"
@@ -20,20 +23,26 @@ const (
 
 // PINHandler is an HTTP handler for the /pin endpoint.
 type PINHandler struct {
-	db     *database.DB
-	logger *zap.Logger
+	db         *database.DB
+	limiter    *passhash.Limiter
+	logger     *zap.Logger
+	lockMemory bool
 }
 
 // NewPINHandler returns a new PINHandler instance.
-func NewPINHandler(db *database.DB, logger *zap.Logger) *PINHandler {
+func NewPINHandler(db *database.DB, limiter *passhash.Limiter, lockMemory bool, logger *zap.Logger) *PINHandler {
 	return &PINHandler{
-		db:     db,
-		logger: logger,
+		db:         db,
+		limiter:    limiter,
+		logger:     logger,
+		lockMemory: lockMemory,
 	}
 }
 
 // ServeHTTP handles HTTP requests for the /pin endpoint.
 func (h *PINHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.logger)
+
 	var (
 		length = acopw.DefaultPINLength
 		err    error
@@ -42,9 +51,9 @@ func (h *PINHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Query().Get("length") != "" {
 		length, err = strconv.Atoi(r.URL.Query().Get("length"))
 		if err != nil {
-			h.logger.Error("error parsing PIN length", zap.Error(err))
+			logger.Error("error parsing PIN length", zap.Error(err))
 
-			cerrors.JSON(w, h.logger, cerrors.ErrorResponse{
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
 				Code:    http.StatusInternalServerError,
 				Message: "Cannot parse the given PIN length. Please provide a valid integer.",
 			})
@@ -57,9 +66,9 @@ func (h *PINHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 
 		if length > MaxPINLength {
-			h.logger.Error("PIN length is too long", zap.Int("length", length))
+			logger.Error("PIN length is too long", zap.Int("length", length))
 
-			cerrors.JSON(w, h.logger, cerrors.ErrorResponse{
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
 				Code:    http.StatusBadRequest,
 				Message: "The given PIN length is too long. Please provide a length less than or equal to " + strconv.Itoa(MaxPINLength) + ".",
 			})
@@ -68,52 +77,55 @@ func (h *PINHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	var (
-		pin = &acopw.PIN{
-			Length: length,
+	hashOpts, err := parseHashOptions(r.URL.Query(), h.limiter, 1)
+	if err != nil {
+		writeHashOptionsError(w, logger, err)
+
+		return
+	}
+
+	password := secmem.NewBuffer(length)
+	defer password.Destroy()
+
+	if h.lockMemory {
+		if err := password.Lock(); err != nil {
+			logger.Warn("failed to lock PIN buffer", zap.Error(err))
 		}
-		password    = pin.Generate()
-		contentType = r.Header.Get(xhttp.ContentType)
-	)
+	}
 
-	if contentType == xhttp.ApplicationJSON {
-		w.Header().Set(xhttp.ContentType, xhttp.ApplicationJSON)
+	if err := secmem.Fill(password.Bytes(), acopw.Numbers); err != nil {
+		logger.Error("error generating PIN", zap.Error(err))
 
-		var (
-			passwordModel   = model.NewPIN(password)
-			passwordJSON, _ = json.Marshal(passwordModel)
-		)
+		cerrors.JSON(w, logger, cerrors.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Cannot generate PIN. Please try again later.",
+		})
 
-		_, err = w.Write(passwordJSON)
-		if err != nil {
-			h.logger.Error("error writing response", zap.Error(err))
+		return
+	}
 
-			cerrors.JSON(w, h.logger, cerrors.ErrorResponse{
-				Code:    http.StatusInternalServerError,
-				Message: "Cannot write response. Please try again later.",
-			})
+	middleware.RecordEntropy(r, float64(length)*math.Log2(float64(len(acopw.Numbers))))
+	middleware.RecordIssued(r, password.Bytes())
 
-			return
-		}
-	} else {
-		w.Header().Set(xhttp.ContentType, xhttp.TextPlain)
+	hashes, err := hashOpts.hashBytes(r.Context(), password.Bytes())
+	if err != nil {
+		writeHashError(w, logger, err)
 
-		_, err := w.Write([]byte(password))
-		if err != nil {
-			h.logger.Error("error writing response", zap.Error(err))
+		return
+	}
 
-			cerrors.JSON(w, h.logger, cerrors.ErrorResponse{
-				Code:    http.StatusInternalServerError,
-				Message: "Cannot write response. Please try again later.",
-			})
+	asJSON := r.Header.Get(xhttp.ContentType) == xhttp.ApplicationJSON
 
-			return
-		}
+	if err := writeSecret(w, asJSON, "pin", password.Bytes(), hashes, h.lockMemory, logger); err != nil {
+		logger.Error("error writing response", zap.Error(err))
+
+		cerrors.JSON(w, logger, cerrors.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Cannot write response. Please try again later.",
+		})
+
+		return
 	}
 
-	go func() {
-		if err := h.db.Increment(database.CounterTypePIN); err != nil {
-			h.logger.Error("Failed to increment access counter", zap.Error(err))
-		}
-	}()
+	middleware.CountIssued(r, h.db, database.CounterTypePIN, logger)
 }
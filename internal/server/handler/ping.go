@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"git.sr.ht/~jamesponddotco/acciopassword/internal/cerrors"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/logging"
 	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
 	"go.uber.org/zap"
 )
@@ -23,14 +24,16 @@ func NewPingHandler(logger *zap.Logger) *PingHandler {
 }
 
 // ServeHTTP serves the /heartbeat endpoint.
-func (h *PingHandler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+func (h *PingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.logger)
+
 	w.Header().Set(xhttp.ContentType, xhttp.TextPlain)
 
 	_, err := w.Write([]byte(pong))
 	if err != nil {
-		h.logger.Error("Failed to write response", zap.Error(err))
+		logger.Error("Failed to write response", zap.Error(err))
 
-		cerrors.JSON(w, h.logger, cerrors.ErrorResponse{
+		cerrors.JSON(w, logger, cerrors.ErrorResponse{
 			Code:    http.StatusInternalServerError,
 			Message: "Failed to write response. Please try again later.",
 		})
@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/cerrors"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/database"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/logging"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/passhash"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/pronounceable"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/server/middleware"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/server/model"
+	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
+	"go.uber.org/zap"
+)
+
+// PronounceableHandler is an HTTP handler for the /pronounceable endpoint.
+type PronounceableHandler struct {
+	db      *database.DB
+	limiter *passhash.Limiter
+	logger  *zap.Logger
+}
+
+// NewPronounceableHandler returns a new PronounceableHandler instance.
+func NewPronounceableHandler(db *database.DB, limiter *passhash.Limiter, logger *zap.Logger) *PronounceableHandler {
+	return &PronounceableHandler{
+		db:      db,
+		limiter: limiter,
+		logger:  logger,
+	}
+}
+
+// ServeHTTP handles HTTP requests for the /pronounceable endpoint.
+func (h *PronounceableHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.logger)
+
+	var (
+		length         = pronounceable.DefaultLength
+		digits         = 0
+		capitalization = pronounceable.CapitalizeNone
+		err            error
+	)
+
+	if r.URL.Query().Get("length") != "" {
+		length, err = strconv.Atoi(r.URL.Query().Get("length"))
+		if err != nil {
+			logger.Error("error parsing password length", zap.Error(err))
+
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusBadRequest,
+				Message: "Cannot parse the given password length. Please provide a valid integer.",
+			})
+
+			return
+		}
+
+		if length < 1 {
+			length = pronounceable.DefaultLength
+		}
+
+		if length > pronounceable.MaxLength {
+			logger.Error("password length is too long", zap.Int("length", length))
+
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusBadRequest,
+				Message: "The given password length is too long. Please provide a length less than or equal to " + strconv.Itoa(pronounceable.MaxLength) + ".",
+			})
+
+			return
+		}
+	}
+
+	if r.URL.Query().Get("digits") != "" {
+		digits, err = strconv.Atoi(r.URL.Query().Get("digits"))
+		if err != nil {
+			logger.Error("error parsing number of digits", zap.Error(err))
+
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusBadRequest,
+				Message: "Cannot parse the given number of digits. Please provide a valid integer.",
+			})
+
+			return
+		}
+
+		if digits < 0 || digits >= length {
+			logger.Error("invalid number of digits", zap.Int("digits", digits), zap.Int("length", length))
+
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusBadRequest,
+				Message: "The given number of digits is invalid. Please provide a number between 0 and " + strconv.Itoa(length-1) + ".",
+			})
+
+			return
+		}
+	}
+
+	if r.URL.Query().Get("capitalize") != "" {
+		capitalization, err = pronounceable.ParseCapitalization(r.URL.Query().Get("capitalize"))
+		if err != nil {
+			logger.Error("error parsing capitalization mode", zap.Error(err))
+
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusBadRequest,
+				Message: "Cannot parse the given capitalization mode. Please provide none, first, or random.",
+			})
+
+			return
+		}
+	}
+
+	hashOpts, err := parseHashOptions(r.URL.Query(), h.limiter, 1)
+	if err != nil {
+		writeHashOptionsError(w, logger, err)
+
+		return
+	}
+
+	generator := &pronounceable.Generator{
+		Capitalization: capitalization,
+		Length:         length,
+		Digits:         digits,
+	}
+
+	password, err := generator.Generate()
+	if err != nil {
+		logger.Error("error generating pronounceable password", zap.Error(err))
+
+		cerrors.JSON(w, logger, cerrors.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Cannot generate pronounceable password. Please try again later.",
+		})
+
+		return
+	}
+
+	middleware.RecordEntropy(r, generator.Entropy())
+	middleware.RecordIssued(r, []byte(password))
+
+	hashes, err := hashOpts.hash(r.Context(), password)
+	if err != nil {
+		writeHashError(w, logger, err)
+
+		return
+	}
+
+	contentType := r.Header.Get(xhttp.ContentType)
+
+	if contentType == xhttp.ApplicationJSON {
+		w.Header().Set(xhttp.ContentType, xhttp.ApplicationJSON)
+
+		passwordModel := model.NewPronounceablePassword(password, generator.Entropy())
+		passwordModel.Hashes = hashes
+
+		passwordJSON, _ := json.Marshal(passwordModel)
+
+		_, err = w.Write(passwordJSON)
+		if err != nil {
+			logger.Error("error writing response", zap.Error(err))
+
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusInternalServerError,
+				Message: "Cannot write response. Please try again later.",
+			})
+
+			return
+		}
+	} else {
+		w.Header().Set(xhttp.ContentType, xhttp.TextPlain)
+
+		_, err = w.Write([]byte(withHashes(password, hashes)))
+		if err != nil {
+			logger.Error("error writing response", zap.Error(err))
+
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusInternalServerError,
+				Message: "Cannot write response. Please try again later.",
+			})
+
+			return
+		}
+	}
+
+	middleware.CountIssued(r, h.db, database.CounterTypePronounceable, logger)
+}
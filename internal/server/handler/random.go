@@ -1,13 +1,16 @@
 package handler
 
 import (
-	"encoding/json"
+	"math"
 	"net/http"
 	"strconv"
 
 	"git.sr.ht/~jamesponddotco/acciopassword/internal/cerrors"
 	"git.sr.ht/~jamesponddotco/acciopassword/internal/database"
-	"git.sr.ht/~jamesponddotco/acciopassword/internal/server/model"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/logging"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/passhash"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/secmem"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/server/middleware"
 	"git.sr.ht/~jamesponddotco/acopw-go"
 	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
 	"go.uber.org/zap"
@@ -20,20 +23,89 @@ const (
 
 // RandomHandler is an HTTP handler for the /diceware endpoint.
 type RandomHandler struct {
-	db     *database.DB
-	logger *zap.Logger
+	db         *database.DB
+	limiter    *passhash.Limiter
+	logger     *zap.Logger
+	lockMemory bool
 }
 
 // NewRandomHandler returns a new RandomHandler instance.
-func NewRandomHandler(db *database.DB, logger *zap.Logger) *RandomHandler {
+func NewRandomHandler(db *database.DB, limiter *passhash.Limiter, lockMemory bool, logger *zap.Logger) *RandomHandler {
 	return &RandomHandler{
-		db:     db,
-		logger: logger,
+		db:         db,
+		limiter:    limiter,
+		logger:     logger,
+		lockMemory: lockMemory,
 	}
 }
 
 // ServeHTTP handles HTTP requests for the /diceware endpoint.
 func (h *RandomHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.logger)
+
+	length, charset, ok := parseCharsetOptions(w, r, logger)
+	if !ok {
+		return
+	}
+
+	hashOpts, err := parseHashOptions(r.URL.Query(), h.limiter, 1)
+	if err != nil {
+		writeHashOptionsError(w, logger, err)
+
+		return
+	}
+
+	password := secmem.NewBuffer(length)
+	defer password.Destroy()
+
+	if h.lockMemory {
+		if err := password.Lock(); err != nil {
+			logger.Warn("failed to lock password buffer", zap.Error(err))
+		}
+	}
+
+	if err := secmem.Fill(password.Bytes(), charset); err != nil {
+		logger.Error("error generating password", zap.Error(err))
+
+		cerrors.JSON(w, logger, cerrors.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Cannot generate password. Please try again later.",
+		})
+
+		return
+	}
+
+	middleware.RecordEntropy(r, float64(length)*math.Log2(float64(len(charset))))
+	middleware.RecordIssued(r, password.Bytes())
+
+	hashes, err := hashOpts.hashBytes(r.Context(), password.Bytes())
+	if err != nil {
+		writeHashError(w, logger, err)
+
+		return
+	}
+
+	asJSON := r.Header.Get(xhttp.ContentType) == xhttp.ApplicationJSON
+
+	if err := writeSecret(w, asJSON, "random", password.Bytes(), hashes, h.lockMemory, logger); err != nil {
+		logger.Error("error writing response", zap.Error(err))
+
+		cerrors.JSON(w, logger, cerrors.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Cannot write response. Please try again later.",
+		})
+
+		return
+	}
+
+	middleware.CountIssued(r, h.db, database.CounterTypeRandom, logger)
+}
+
+// parseCharsetOptions parses the password length and character class query
+// parameters shared by the /random and /derive endpoints, and returns the
+// length and character set to use. It writes an error response and returns
+// false if any parameter is invalid.
+func parseCharsetOptions(w http.ResponseWriter, r *http.Request, logger *zap.Logger) (int, string, bool) {
 	var (
 		length       = acopw.DefaultRandomLength
 		useLowercase = true
@@ -46,14 +118,14 @@ func (h *RandomHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Query().Get("length") != "" {
 		length, err = strconv.Atoi(r.URL.Query().Get("length"))
 		if err != nil {
-			h.logger.Error("error parsing password length", zap.Error(err))
+			logger.Error("error parsing password length", zap.Error(err))
 
-			cerrors.JSON(w, h.logger, cerrors.ErrorResponse{
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
 				Code:    http.StatusBadRequest,
 				Message: "Cannot parse the given password length. Please provide a valid integer.",
 			})
 
-			return
+			return 0, "", false
 		}
 
 		if length < 1 {
@@ -61,122 +133,85 @@ func (h *RandomHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 
 		if length > MaxRandomLength {
-			h.logger.Error("password length is too long", zap.Int("length", length))
+			logger.Error("password length is too long", zap.Int("length", length))
 
-			cerrors.JSON(w, h.logger, cerrors.ErrorResponse{
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
 				Code:    http.StatusBadRequest,
 				Message: "The given password length is too long. Please provide a length less than or equal to " + strconv.Itoa(MaxRandomLength) + ".",
 			})
 
-			return
+			return 0, "", false
 		}
 	}
 
 	if r.URL.Query().Get("lowercase") != "" {
 		useLowercase, err = strconv.ParseBool(r.URL.Query().Get("lowercase"))
 		if err != nil {
-			h.logger.Error("error parsing lowercase flag", zap.Error(err))
+			logger.Error("error parsing lowercase flag", zap.Error(err))
 
-			cerrors.JSON(w, h.logger, cerrors.ErrorResponse{
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
 				Code:    http.StatusBadRequest,
 				Message: "Cannot parse the given lowercase flag. Please provide a valid boolean.",
 			})
 
-			return
+			return 0, "", false
 		}
 	}
 
 	if r.URL.Query().Get("uppercase") != "" {
 		useUppercase, err = strconv.ParseBool(r.URL.Query().Get("uppercase"))
 		if err != nil {
-			h.logger.Error("error parsing uppercase flag", zap.Error(err))
+			logger.Error("error parsing uppercase flag", zap.Error(err))
 
-			cerrors.JSON(w, h.logger, cerrors.ErrorResponse{
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
 				Code:    http.StatusBadRequest,
 				Message: "Cannot parse the given uppercase flag. Please provide a valid boolean.",
 			})
 
-			return
+			return 0, "", false
 		}
 	}
 
 	if r.URL.Query().Get("numbers") != "" {
 		useNumbers, err = strconv.ParseBool(r.URL.Query().Get("numbers"))
 		if err != nil {
-			h.logger.Error("error parsing numbers flag", zap.Error(err))
+			logger.Error("error parsing numbers flag", zap.Error(err))
 
-			cerrors.JSON(w, h.logger, cerrors.ErrorResponse{
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
 				Code:    http.StatusBadRequest,
 				Message: "Cannot parse the given numbers flag. Please provide a valid boolean.",
 			})
 
-			return
+			return 0, "", false
 		}
 	}
 
 	if r.URL.Query().Get("symbols") != "" {
 		useSymbols, err = strconv.ParseBool(r.URL.Query().Get("symbols"))
 		if err != nil {
-			h.logger.Error("error parsing symbols flag", zap.Error(err))
+			logger.Error("error parsing symbols flag", zap.Error(err))
 
-			cerrors.JSON(w, h.logger, cerrors.ErrorResponse{
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
 				Code:    http.StatusBadRequest,
 				Message: "Cannot parse the given symbols flag. Please provide a valid boolean.",
 			})
 
-			return
+			return 0, "", false
 		}
 	}
 
-	var (
-		password = &acopw.Random{
-			Length:     length,
-			UseLower:   useLowercase,
-			UseUpper:   useUppercase,
-			UseNumbers: useNumbers,
-			UseSymbols: useSymbols,
-		}
-		contentType = r.Header.Get(xhttp.ContentType)
-	)
-
-	if contentType == xhttp.ApplicationJSON {
-		w.Header().Set(xhttp.ContentType, xhttp.ApplicationJSON)
-
-		var (
-			passwordModel   = model.NewRandomPassword(password.Generate())
-			passwordJSON, _ = json.Marshal(passwordModel)
-		)
-
-		_, err = w.Write(passwordJSON)
-		if err != nil {
-			h.logger.Error("error writing response", zap.Error(err))
-
-			cerrors.JSON(w, h.logger, cerrors.ErrorResponse{
-				Code:    http.StatusInternalServerError,
-				Message: "Cannot write response. Please try again later.",
-			})
-
-			return
-		}
-	} else {
-		w.Header().Set(xhttp.ContentType, xhttp.TextPlain)
-
-		_, err = w.Write([]byte(password.Generate()))
-		if err != nil {
-			h.logger.Error("error writing response", zap.Error(err))
-
-			cerrors.JSON(w, h.logger, cerrors.ErrorResponse{
-				Code:    http.StatusInternalServerError,
-				Message: "Cannot write response. Please try again later.",
-			})
-
-			return
-		}
+	// Like acopw.Random, fall back to every character class if none was
+	// requested.
+	if !useLowercase && !useUppercase && !useNumbers && !useSymbols {
+		useLowercase, useUppercase, useNumbers, useSymbols = true, true, true, true
 	}
 
-	go func() {
-		if err := h.db.Increment(database.CounterTypeRandom); err != nil {
-			h.logger.Error("Failed to increment access counter", zap.Error(err))
-		}
-	}()
+	charset := (&acopw.Random{
+		UseLower:   useLowercase,
+		UseUpper:   useUppercase,
+		UseNumbers: useNumbers,
+		UseSymbols: useSymbols,
+	}).Charset()
+
+	return length, charset, true
 }
@@ -0,0 +1,200 @@
+package handler
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/cerrors"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/database"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/logging"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/passhash"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/recovery"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/server/middleware"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/server/model"
+	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
+	"go.uber.org/zap"
+)
+
+const (
+	// MaxHashedRecoveryCodes is the maximum number of recovery codes in a set
+	// when hashes are requested, since hashing is deliberately slow.
+	MaxHashedRecoveryCodes int = 20
+)
+
+// RecoveryCodesHandler is an HTTP handler for the /recovery-codes endpoint.
+type RecoveryCodesHandler struct {
+	db      *database.DB
+	limiter *passhash.Limiter
+	logger  *zap.Logger
+}
+
+// NewRecoveryCodesHandler returns a new RecoveryCodesHandler instance.
+func NewRecoveryCodesHandler(db *database.DB, limiter *passhash.Limiter, logger *zap.Logger) *RecoveryCodesHandler {
+	return &RecoveryCodesHandler{
+		db:      db,
+		limiter: limiter,
+		logger:  logger,
+	}
+}
+
+// ServeHTTP handles HTTP requests for the /recovery-codes endpoint.
+func (h *RecoveryCodesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.logger)
+
+	var (
+		count = recovery.DefaultCount
+		err   error
+	)
+
+	if r.URL.Query().Get("count") != "" {
+		count, err = strconv.Atoi(r.URL.Query().Get("count"))
+		if err != nil {
+			logger.Error("error parsing number of recovery codes", zap.Error(err))
+
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusBadRequest,
+				Message: "Cannot parse the given number of codes. Please provide a valid integer.",
+			})
+
+			return
+		}
+
+		if count < 1 {
+			count = recovery.DefaultCount
+		}
+
+		if count > recovery.MaxCount {
+			logger.Error("too many recovery codes", zap.Int("count", count))
+
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusBadRequest,
+				Message: "The given number of codes is too large. Please provide a number less than or equal to " + strconv.Itoa(recovery.MaxCount) + ".",
+			})
+
+			return
+		}
+	}
+
+	hashOpts, err := parseHashOptions(r.URL.Query(), h.limiter, count)
+	if err != nil {
+		writeHashOptionsError(w, logger, err)
+
+		return
+	}
+
+	if len(hashOpts.algorithms) > 1 {
+		cerrors.JSON(w, logger, cerrors.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Recovery codes can only be hashed with one algorithm at a time. Please provide a single hashing algorithm.",
+		})
+
+		return
+	}
+
+	if len(hashOpts.algorithms) > 0 && count > MaxHashedRecoveryCodes {
+		logger.Error("too many recovery codes to hash", zap.Int("count", count))
+
+		cerrors.JSON(w, logger, cerrors.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "The given number of codes is too large to hash. Please provide a number less than or equal to " + strconv.Itoa(MaxHashedRecoveryCodes) + ".",
+		})
+
+		return
+	}
+
+	codes, err := recovery.Generate(count)
+	if err != nil {
+		logger.Error("error generating recovery codes", zap.Error(err))
+
+		cerrors.JSON(w, logger, cerrors.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Cannot generate recovery codes. Please try again later.",
+		})
+
+		return
+	}
+
+	// Codes are independent, so the entropy of a single code is recorded.
+	middleware.RecordEntropy(r, float64(recovery.Groups*recovery.GroupSize)*math.Log2(float64(len(recovery.Alphabet))))
+
+	for _, code := range codes {
+		middleware.RecordIssued(r, []byte(code))
+	}
+
+	var (
+		algorithm string
+		hashes    []string
+	)
+
+	if len(hashOpts.algorithms) > 0 {
+		hashes = make([]string, 0, len(codes))
+
+		for _, code := range codes {
+			codeHashes, err := hashOpts.hash(r.Context(), recovery.Normalize(code))
+			if err != nil {
+				writeHashError(w, logger, err)
+
+				return
+			}
+
+			algorithm = codeHashes[0].Algorithm
+			hashes = append(hashes, codeHashes[0].Hash)
+		}
+	}
+
+	contentType := r.Header.Get(xhttp.ContentType)
+
+	if contentType == xhttp.ApplicationJSON {
+		w.Header().Set(xhttp.ContentType, xhttp.ApplicationJSON)
+
+		var (
+			codesModel   = model.NewRecoveryCodes(codes, hashes, algorithm)
+			codesJSON, _ = json.Marshal(codesModel)
+		)
+
+		_, err = w.Write(codesJSON)
+		if err != nil {
+			logger.Error("error writing response", zap.Error(err))
+
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusInternalServerError,
+				Message: "Cannot write response. Please try again later.",
+			})
+
+			return
+		}
+	} else {
+		w.Header().Set(xhttp.ContentType, xhttp.TextPlain)
+
+		// One code per line, followed by its hash when one was requested.
+		var builder strings.Builder
+
+		for i, code := range codes {
+			builder.WriteString(code)
+
+			if hashes != nil {
+				builder.WriteString(" ")
+				builder.WriteString(hashes[i])
+			}
+
+			builder.WriteString("\n")
+		}
+
+		_, err = w.Write([]byte(builder.String()))
+		if err != nil {
+			logger.Error("error writing response", zap.Error(err))
+
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusInternalServerError,
+				Message: "Cannot write response. Please try again later.",
+			})
+
+			return
+		}
+	}
+
+	middleware.CountIssued(r, h.db, database.CounterTypeRecoveryCodes, logger)
+}
@@ -0,0 +1,160 @@
+package handler_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/database"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/logging"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/passhash"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/server/handler"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/server/middleware"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent use, since handlers log
+// from background goroutines.
+type syncBuffer struct {
+	buf bytes.Buffer
+	mu  sync.Mutex
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
+
+// newRedactingLogger returns a debug logger writing JSON to out through
+// logging.Redact, as the server does.
+func newRedactingLogger(out *syncBuffer) *zap.Logger {
+	core := zapcore.NewCore(
+		zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()),
+		zapcore.AddSync(out),
+		zapcore.DebugLevel,
+	)
+
+	return logging.Redact(zap.New(core))
+}
+
+func TestHandlersDoNotLogSecrets(t *testing.T) {
+	t.Parallel()
+
+	const (
+		master = "correct-horse-battery-staple-master"
+		login  = "alice@example.com"
+		query  = "hunter2-query-password"
+		phrase = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+		typo   = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abuot"
+	)
+
+	for _, format := range []string{middleware.AccessLogJSON, middleware.AccessLogCombined} {
+		format := format
+
+		t.Run(format, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				logs      = &syncBuffer{}
+				accessLog = &syncBuffer{}
+				logger    = newRedactingLogger(logs)
+				limiter   = passhash.NewLimiter(passhash.DefaultParams(), 0, 0)
+			)
+
+			db, err := database.Open(logger, filepath.Join(t.TempDir(), "test.db"))
+			if err != nil {
+				t.Fatalf("failed to open database: %v", err)
+			}
+
+			t.Cleanup(func() { _ = db.Close() })
+
+			serve := func(h http.Handler, r *http.Request) *httptest.ResponseRecorder {
+				t.Helper()
+
+				w := httptest.NewRecorder()
+
+				middleware.RequestID(logger, middleware.AccessLog(format, accessLog, logger, h)).ServeHTTP(w, r)
+
+				return w
+			}
+
+			random := serve(
+				handler.NewRandomHandler(db, limiter, false, logger),
+				httptest.NewRequest(http.MethodGet, "/v1/random/?length=32&password="+query, http.NoBody),
+			)
+			if random.Code != http.StatusOK {
+				t.Fatalf("random: got status %d, want %d", random.Code, http.StatusOK)
+			}
+
+			password := strings.TrimSpace(random.Body.String())
+
+			derived := serve(
+				handler.NewDeriveHandler(db, limiter, false, logger),
+				httptest.NewRequest(
+					http.MethodPost,
+					"/v1/derive/?site=example.com&login="+login+"&master="+master+"&password="+query,
+					strings.NewReader(master),
+				),
+			)
+			if derived.Code != http.StatusOK {
+				t.Fatalf("derive: got status %d, want %d", derived.Code, http.StatusOK)
+			}
+
+			validation := handler.NewMnemonicValidationHandler(false, logger)
+
+			for _, p := range []string{phrase, typo} {
+				serve(validation, httptest.NewRequest(http.MethodPost, "/v1/mnemonic/validate/", strings.NewReader(p)))
+			}
+
+			secrets := map[string]string{
+				"generated password": password,
+				"derived password":   strings.TrimSpace(derived.Body.String()),
+				"master secret":      master,
+				"login":              login,
+				"query password":     query,
+				"mnemonic":           "abandon abandon",
+				"misspelled word":    "abuot",
+			}
+
+			sinks := map[string]string{
+				"log":        logs.String(),
+				"access log": accessLog.String(),
+			}
+
+			// The JSON access log is written through the application logger.
+			if format == middleware.AccessLogJSON && !strings.Contains(sinks["log"], `"msg":"request"`) {
+				t.Fatal("access log entries are missing from the log")
+			}
+
+			if format == middleware.AccessLogCombined && sinks["access log"] == "" {
+				t.Fatal("access log is empty")
+			}
+
+			for name, sink := range sinks {
+				for secret, value := range secrets {
+					if value == "" {
+						t.Fatalf("%s is empty", secret)
+					}
+
+					if strings.Contains(sink, value) {
+						t.Errorf("%s contains the %s", name, secret)
+					}
+				}
+			}
+		})
+	}
+}
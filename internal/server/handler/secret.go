@@ -0,0 +1,356 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/cerrors"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/secmem"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/server/model"
+	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
+	"go.uber.org/zap"
+)
+
+// hex is the alphabet used to escape control characters in JSON strings.
+const hex string = "0123456789abcdef"
+
+// errInvalidJSONString is returned when a JSON value is not a valid string.
+var errInvalidJSONString = errors.New("invalid JSON string")
+
+// readSecret reads a secret of at most maxSize bytes from the body of a
+// request into a secmem.Buffer, locked into memory if lockMemory is true. If
+// asJSON is true, the secret is the value of the given field of a JSON object,
+// decoded without ever turning it into a string. The secret is empty if the
+// body is. name describes the secret in error messages.
+//
+// It writes an error response and returns false if the body cannot be read.
+// Otherwise, the caller must destroy the returned buffer, of which the secret
+// is a slice.
+func readSecret(w http.ResponseWriter, r *http.Request, asJSON bool, field, name string, maxSize int, lockMemory bool, logger *zap.Logger) (*secmem.Buffer, []byte, bool) {
+	// The body is read into a buffer one byte larger than the limit, so that
+	// reading it whole means it is too large.
+	body := secmem.NewBuffer(maxSize + 1)
+
+	if lockMemory {
+		if err := body.Lock(); err != nil {
+			logger.Warn("failed to lock request buffer", zap.Error(err))
+		}
+	}
+
+	n, err := io.ReadFull(r.Body, body.Bytes())
+	if err == nil {
+		body.Destroy()
+
+		logger.Error("request body is too large")
+
+		cerrors.JSON(w, logger, cerrors.ErrorResponse{
+			Code:    http.StatusRequestEntityTooLarge,
+			Message: "The given " + name + " is too large. Please provide a " + name + " of at most " + strconv.Itoa(maxSize) + " bytes.",
+		})
+
+		return nil, nil, false
+	}
+
+	if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		body.Destroy()
+
+		logger.Error("error reading request body", zap.Error(err))
+
+		cerrors.JSON(w, logger, cerrors.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Cannot read the request body. Please try again.",
+		})
+
+		return nil, nil, false
+	}
+
+	secret := body.Bytes()[:n]
+
+	if asJSON && n > 0 {
+		secret, err = decodeSecretField(body.Bytes(), secret, field)
+		if err != nil {
+			body.Destroy()
+
+			logger.Error("error parsing request body", zap.Error(err))
+
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusBadRequest,
+				Message: "Cannot parse the request body. Please provide a JSON object with a " + field + " field.",
+			})
+
+			return nil, nil, false
+		}
+	}
+
+	return body, secret, true
+}
+
+// decodeSecretField decodes the string value of the given field of the JSON
+// object in data into dst, which must be at least as long as data, and wipes
+// every copy made along the way. A missing or null field decodes to an empty
+// secret.
+func decodeSecretField(dst, data []byte, field string) ([]byte, error) {
+	var object map[string]json.RawMessage
+
+	err := json.Unmarshal(data, &object)
+
+	defer func() {
+		for _, value := range object {
+			secmem.Zero(value)
+		}
+	}()
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode JSON object: %w", err)
+	}
+
+	value, ok := object[field]
+	if !ok || string(value) == "null" {
+		secmem.Zero(dst)
+
+		return dst[:0], nil
+	}
+
+	n, err := unquoteJSON(dst, value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s field: %w", field, err)
+	}
+
+	secmem.Zero(dst[n:])
+
+	return dst[:n], nil
+}
+
+// unquoteJSON decodes the JSON string in src into dst, which must be at least
+// as long as src, and returns the number of bytes written. Invalid surrogates
+// decode to U+FFFD, like they do with encoding/json.
+func unquoteJSON(dst, src []byte) (int, error) {
+	if len(src) < 2 || src[0] != '"' || src[len(src)-1] != '"' {
+		return 0, errInvalidJSONString
+	}
+
+	var (
+		s = src[1 : len(src)-1]
+		n int
+	)
+
+	for i := 0; i < len(s); {
+		c := s[i]
+
+		if c != '\\' {
+			dst[n] = c
+			n++
+			i++
+
+			continue
+		}
+
+		if i+1 >= len(s) {
+			return 0, errInvalidJSONString
+		}
+
+		switch s[i+1] {
+		case '"', '\\', '/':
+			dst[n] = s[i+1]
+		case 'b':
+			dst[n] = '\b'
+		case 'f':
+			dst[n] = '\f'
+		case 'n':
+			dst[n] = '\n'
+		case 'r':
+			dst[n] = '\r'
+		case 't':
+			dst[n] = '\t'
+		case 'u':
+			r, ok := parseJSONEscape(s[i:])
+			if !ok {
+				return 0, errInvalidJSONString
+			}
+
+			i += len(`\u0000`)
+
+			if utf16.IsSurrogate(r) {
+				r2, ok := parseJSONEscape(s[i:])
+				if decoded := utf16.DecodeRune(r, r2); ok && decoded != utf8.RuneError {
+					r = decoded
+					i += len(`\u0000`)
+				} else {
+					r = utf8.RuneError
+				}
+			}
+
+			n += utf8.EncodeRune(dst[n:], r)
+
+			continue
+		default:
+			return 0, errInvalidJSONString
+		}
+
+		n++
+		i += 2
+	}
+
+	return n, nil
+}
+
+// parseJSONEscape parses the \uXXXX escape at the start of s.
+func parseJSONEscape(s []byte) (rune, bool) {
+	if len(s) < len(`\u0000`) || s[0] != '\\' || s[1] != 'u' {
+		return 0, false
+	}
+
+	var r rune
+
+	for _, c := range s[2:6] {
+		switch {
+		case '0' <= c && c <= '9':
+			c -= '0'
+		case 'a' <= c && c <= 'f':
+			c = c - 'a' + 10
+		case 'A' <= c && c <= 'F':
+			c = c - 'A' + 10
+		default:
+			return 0, false
+		}
+
+		r = r<<4 | rune(c)
+	}
+
+	return r, true
+}
+
+// writeSecret writes a secret held in a byte slice to the response, along
+// with its hashes, without ever turning it into a string. In JSON, the secret
+// is the value of the given field, mirroring model.Password; in plain text, it
+// is followed by one hash per line, like withHashes. The response body is
+// built in a secmem.Buffer, locked into memory if lockMemory is true, and
+// zeroed once written.
+func writeSecret(w http.ResponseWriter, asJSON bool, field string, secret []byte, hashes []model.Hash, lockMemory bool, logger *zap.Logger) error {
+	var (
+		hashesJSON []byte
+		size       int
+		err        error
+	)
+
+	if asJSON {
+		if len(hashes) > 0 {
+			hashesJSON, err = json.Marshal(hashes)
+			if err != nil {
+				return fmt.Errorf("failed to encode hashes: %w", err)
+			}
+
+			size = len(`,"hashes":`) + len(hashesJSON)
+		}
+
+		size += len(`{"":}`) + len(field) + jsonStringSize(secret)
+	} else {
+		size = len(secret)
+
+		for _, hash := range hashes {
+			size += len(hash.Hash) + 1
+		}
+
+		if len(hashes) > 0 {
+			size++
+		}
+	}
+
+	buffer := secmem.NewBuffer(size)
+	defer buffer.Destroy()
+
+	if lockMemory {
+		// Locking is best effort, as RLIMIT_MEMLOCK may be too low.
+		if err := buffer.Lock(); err != nil {
+			logger.Warn("failed to lock response buffer", zap.Error(err))
+		}
+	}
+
+	body := buffer.Bytes()[:0]
+
+	if asJSON {
+		body = append(body, `{"`...)
+		body = append(body, field...)
+		body = append(body, `":`...)
+		body = appendJSONString(body, secret)
+
+		if len(hashesJSON) > 0 {
+			body = append(body, `,"hashes":`...)
+			body = append(body, hashesJSON...)
+		}
+
+		body = append(body, '}')
+
+		w.Header().Set(xhttp.ContentType, xhttp.ApplicationJSON)
+	} else {
+		body = append(body, secret...)
+
+		for _, hash := range hashes {
+			body = append(body, '\n')
+			body = append(body, hash.Hash...)
+		}
+
+		if len(hashes) > 0 {
+			body = append(body, '\n')
+		}
+
+		w.Header().Set(xhttp.ContentType, xhttp.TextPlain)
+	}
+
+	if _, err := w.Write(body); err != nil {
+		return fmt.Errorf("failed to write secret: %w", err)
+	}
+
+	return nil
+}
+
+// jsonStringSize returns the size of s once encoded by appendJSONString.
+func jsonStringSize(s []byte) int {
+	size := len(`""`)
+
+	for _, c := range s {
+		switch {
+		case c == '"' || c == '\\' || c == '\n' || c == '\r' || c == '\t':
+			size += 2
+		case c < 0x20 || c == '<' || c == '>' || c == '&':
+			size += len(`\u0000`)
+		default:
+			size++
+		}
+	}
+
+	return size
+}
+
+// appendJSONString appends s to dst as a JSON string, escaping it the same way
+// encoding/json does. dst must have enough capacity, as reported by
+// jsonStringSize, so that no copy of the secret is left behind by a
+// reallocation.
+func appendJSONString(dst, s []byte) []byte {
+	dst = append(dst, '"')
+
+	for _, c := range s {
+		switch {
+		case c == '"' || c == '\\':
+			dst = append(dst, '\\', c)
+		case c == '\n':
+			dst = append(dst, '\\', 'n')
+		case c == '\r':
+			dst = append(dst, '\\', 'r')
+		case c == '\t':
+			dst = append(dst, '\\', 't')
+		case c < 0x20 || c == '<' || c == '>' || c == '&':
+			dst = append(dst, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+		default:
+			dst = append(dst, c)
+		}
+	}
+
+	return append(dst, '"')
+}
@@ -0,0 +1,339 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/cerrors"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/database"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/endpoint"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/logging"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/secmem"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/server/middleware"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/server/model"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/share"
+	"git.sr.ht/~jamesponddotco/acopw-go"
+	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
+	"go.uber.org/zap"
+)
+
+// ShareKeyHeader is the request header carrying the key of a shared secret.
+const ShareKeyHeader string = "X-Share-Key"
+
+// shareRevealScript reads the key from the fragment of the share link and
+// fetches the secret with it, only once the recipient asks for it, so link
+// previews cannot burn the secret.
+const shareRevealScript string = `document.getElementById("reveal").addEventListener("click", async () => {
+	const button = document.getElementById("reveal");
+	const status = document.getElementById("status");
+
+	button.disabled = true;
+
+	const response = await fetch(location.pathname, {
+		headers: {"` + ShareKeyHeader + `": location.hash.slice(1)},
+		cache: "no-store",
+	});
+
+	if (!response.ok) {
+		status.textContent = "This secret cannot be read. It may have expired or already been read.";
+		return;
+	}
+
+	const secret = document.getElementById("secret");
+	secret.textContent = await response.text();
+	secret.hidden = false;
+
+	button.hidden = true;
+	status.textContent = "This secret has been deleted from the server. Copy it now.";
+	history.replaceState(null, "", location.pathname);
+});`
+
+// shareRevealPage is the page served to browsers opening a share link.
+const shareRevealPage string = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Shared secret</title>
+</head>
+<body>
+<p id="status">Someone shared a secret with you. It can only be read once.</p>
+<button id="reveal" type="button">Reveal secret</button>
+<pre id="secret" hidden></pre>
+<script>` + shareRevealScript + `</script>
+</body>
+</html>
+`
+
+// shareRevealPolicy is the Content-Security-Policy header of the reveal page,
+// which only allows its own script to run and talk to the server.
+var shareRevealPolicy = func() string {
+	hash := sha256.Sum256([]byte(shareRevealScript))
+
+	return "default-src 'none'; script-src 'sha256-" + base64.StdEncoding.EncodeToString(hash[:]) + "'; connect-src 'self'; frame-ancestors 'none'; base-uri 'none'; form-action 'none'"
+}()
+
+// ShareHandler is an HTTP handler for the /share endpoint.
+type ShareHandler struct {
+	store      *share.Store
+	logger     *zap.Logger
+	baseURL    string
+	ttl        time.Duration
+	maxTTL     time.Duration
+	maxSize    int64
+	lockMemory bool
+}
+
+// NewShareHandler returns a new ShareHandler instance. Links are built on
+// baseURL, or on the scheme and host of the request if it is empty.
+func NewShareHandler(store *share.Store, baseURL string, ttl, maxTTL time.Duration, maxSize int64, lockMemory bool, logger *zap.Logger) *ShareHandler {
+	return &ShareHandler{
+		store:      store,
+		logger:     logger,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		ttl:        ttl,
+		maxTTL:     maxTTL,
+		maxSize:    maxSize,
+		lockMemory: lockMemory,
+	}
+}
+
+// ServeHTTP handles HTTP requests for the /share endpoint. Secrets are shared
+// with a POST request to the endpoint itself, and read with a GET request to
+// the link returned.
+func (h *ShareHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.logger)
+
+	id := strings.TrimPrefix(r.URL.Path, endpoint.Share)
+
+	switch {
+	case id == "" && r.Method == http.MethodPost:
+		h.create(w, r, logger)
+	case id != "" && !strings.Contains(id, "/") && r.Method == http.MethodGet:
+		h.reveal(w, r, id, logger)
+	case id == "":
+		cerrors.JSON(w, logger, cerrors.ErrorResponse{
+			Code:    http.StatusMethodNotAllowed,
+			Message: fmt.Sprintf("Method %s not allowed. Must be POST.", r.Method),
+		})
+	case strings.Contains(id, "/"):
+		cerrors.JSON(w, logger, cerrors.ErrorResponse{
+			Code:    http.StatusNotFound,
+			Message: "Page not found. Check the URL and try again.",
+		})
+	default:
+		// HEAD requests are rejected too, since they would delete the secret
+		// without returning it.
+		cerrors.JSON(w, logger, cerrors.ErrorResponse{
+			Code:    http.StatusMethodNotAllowed,
+			Message: fmt.Sprintf("Method %s not allowed. Must be GET.", r.Method),
+		})
+	}
+}
+
+// create stores the secret in the request body, or a random password if the
+// body is empty, and responds with the link to it.
+func (h *ShareHandler) create(w http.ResponseWriter, r *http.Request, logger *zap.Logger) {
+	var (
+		ttl    = h.ttl
+		length = acopw.DefaultRandomLength
+		err    error
+	)
+
+	if r.URL.Query().Get("ttl") != "" {
+		seconds, err := strconv.Atoi(r.URL.Query().Get("ttl"))
+		if err != nil || seconds < 1 {
+			logger.Error("error parsing share TTL", zap.String("ttl", r.URL.Query().Get("ttl")))
+
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusBadRequest,
+				Message: "Cannot parse the given TTL. Please provide a positive number of seconds.",
+			})
+
+			return
+		}
+
+		ttl = time.Duration(seconds) * time.Second
+
+		if ttl > h.maxTTL {
+			logger.Error("share TTL is too long", zap.Int("ttl", seconds))
+
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusBadRequest,
+				Message: "The given TTL is too long. Please provide a TTL less than or equal to " + strconv.Itoa(int(h.maxTTL/time.Second)) + " seconds.",
+			})
+
+			return
+		}
+	}
+
+	if r.URL.Query().Get("length") != "" {
+		length, err = strconv.Atoi(r.URL.Query().Get("length"))
+		if err != nil || length < 1 || length > MaxRandomLength {
+			logger.Error("error parsing password length", zap.String("length", r.URL.Query().Get("length")))
+
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusBadRequest,
+				Message: "Cannot parse the given password length. Please provide an integer between 1 and " + strconv.Itoa(MaxRandomLength) + ".",
+			})
+
+			return
+		}
+	}
+
+	asJSON := r.Header.Get(xhttp.ContentType) == xhttp.ApplicationJSON
+
+	body, secret, ok := readSecret(w, r, asJSON, "secret", "secret", int(h.maxSize), h.lockMemory, logger)
+	if !ok {
+		return
+	}
+	defer body.Destroy()
+
+	if len(secret) == 0 {
+		charset := (&acopw.Random{
+			UseLower:   true,
+			UseUpper:   true,
+			UseNumbers: true,
+			UseSymbols: true,
+		}).Charset()
+
+		generated := secmem.NewBuffer(length)
+		defer generated.Destroy()
+
+		if h.lockMemory {
+			if err := generated.Lock(); err != nil {
+				logger.Warn("failed to lock password buffer", zap.Error(err))
+			}
+		}
+
+		secret = generated.Bytes()
+
+		if err := secmem.Fill(secret, charset); err != nil {
+			logger.Error("error generating password", zap.Error(err))
+
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusInternalServerError,
+				Message: "Cannot generate password. Please try again later.",
+			})
+
+			return
+		}
+
+		middleware.RecordEntropy(r, float64(length)*math.Log2(float64(len(charset))))
+	}
+
+	shared, key, err := h.store.Create(secret, ttl)
+	if err != nil {
+		logger.Error("error sharing secret", zap.Error(err))
+
+		cerrors.JSON(w, logger, cerrors.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Cannot share secret. Please try again later.",
+		})
+
+		return
+	}
+
+	baseURL := h.baseURL
+	if baseURL == "" {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+
+		baseURL = scheme + "://" + r.Host
+	}
+
+	link := baseURL + endpoint.Share + shared.ID + "#" + key
+
+	if asJSON {
+		w.Header().Set(xhttp.ContentType, xhttp.ApplicationJSON)
+		w.WriteHeader(http.StatusCreated)
+
+		shareJSON, _ := json.Marshal(model.NewShare(shared.ID, link, shared.ExpiresAt))
+
+		_, err = w.Write(shareJSON)
+	} else {
+		w.Header().Set(xhttp.ContentType, xhttp.TextPlain)
+		w.WriteHeader(http.StatusCreated)
+
+		_, err = w.Write([]byte(link))
+	}
+
+	if err != nil {
+		logger.Error("error writing response", zap.Error(err))
+	}
+}
+
+// reveal responds with the shared secret and deletes it. Browsers opening the
+// link without the key are sent a page that reads it from the fragment.
+func (h *ShareHandler) reveal(w http.ResponseWriter, r *http.Request, id string, logger *zap.Logger) {
+	key := r.Header.Get(ShareKeyHeader)
+
+	if key == "" {
+		if strings.Contains(r.Header.Get(xhttp.Accept), xhttp.TextHTML) {
+			w.Header().Set("Content-Security-Policy", shareRevealPolicy)
+			w.Header().Set(xhttp.ContentType, xhttp.TextHTML+"; charset=utf-8")
+
+			if _, err := w.Write([]byte(shareRevealPage)); err != nil {
+				logger.Error("error writing response", zap.Error(err))
+			}
+
+			return
+		}
+
+		cerrors.JSON(w, logger, cerrors.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Missing share key. Please send the fragment of the link in the " + ShareKeyHeader + " header.",
+		})
+
+		return
+	}
+
+	secret, err := h.store.Reveal(id, key)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrShareNotFound):
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusNotFound,
+				Message: "Shared secret not found. It may have expired or already been read.",
+			})
+		case errors.Is(err, share.ErrInvalidKey):
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusForbidden,
+				Message: "Invalid share key. Check the link and try again.",
+			})
+		default:
+			logger.Error("error revealing shared secret", zap.Error(err))
+
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusInternalServerError,
+				Message: "Cannot read shared secret. Please try again later.",
+			})
+		}
+
+		return
+	}
+	defer secret.Destroy()
+
+	if h.lockMemory {
+		if err := secret.Lock(); err != nil {
+			logger.Warn("failed to lock shared secret", zap.Error(err))
+		}
+	}
+
+	asJSON := r.Header.Get(xhttp.ContentType) == xhttp.ApplicationJSON
+
+	if err := writeSecret(w, asJSON, "secret", secret.Bytes(), nil, h.lockMemory, logger); err != nil {
+		// The secret is already deleted, so all that is left is to log it.
+		logger.Error("error writing response", zap.Error(err))
+	}
+}
@@ -0,0 +1,196 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/cerrors"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/database"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/keygen"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/logging"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/passhash"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/server/middleware"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/server/model"
+	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
+	"go.uber.org/zap"
+)
+
+// WireGuardHandler is an HTTP handler for the /wireguard endpoint.
+type WireGuardHandler struct {
+	db      *database.DB
+	limiter *passhash.Limiter
+	logger  *zap.Logger
+}
+
+// NewWireGuardHandler returns a new WireGuardHandler instance.
+func NewWireGuardHandler(db *database.DB, limiter *passhash.Limiter, logger *zap.Logger) *WireGuardHandler {
+	return &WireGuardHandler{
+		db:      db,
+		limiter: limiter,
+		logger:  logger,
+	}
+}
+
+// ServeHTTP handles HTTP requests for the /wireguard endpoint.
+func (h *WireGuardHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.logger)
+
+	hashOpts, err := parseHashOptions(r.URL.Query(), h.limiter, 1)
+	if err != nil {
+		writeHashOptionsError(w, logger, err)
+
+		return
+	}
+
+	privateKey, publicKey, err := keygen.WireGuard()
+	if err != nil {
+		logger.Error("error generating WireGuard key", zap.Error(err))
+
+		cerrors.JSON(w, logger, cerrors.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Cannot generate WireGuard key. Please try again later.",
+		})
+
+		return
+	}
+
+	middleware.RecordEntropy(r, keygen.ClampedKeyEntropy)
+	middleware.RecordIssued(r, []byte(privateKey))
+
+	hashes, err := hashOpts.hash(r.Context(), privateKey)
+	if err != nil {
+		writeHashError(w, logger, err)
+
+		return
+	}
+
+	contentType := r.Header.Get(xhttp.ContentType)
+
+	if contentType == xhttp.ApplicationJSON {
+		w.Header().Set(xhttp.ContentType, xhttp.ApplicationJSON)
+
+		keyModel := model.NewWireGuardKey(privateKey, publicKey)
+		keyModel.Hashes = hashes
+
+		keyJSON, _ := json.Marshal(keyModel)
+
+		_, err = w.Write(keyJSON)
+		if err != nil {
+			logger.Error("error writing response", zap.Error(err))
+
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusInternalServerError,
+				Message: "Cannot write response. Please try again later.",
+			})
+
+			return
+		}
+	} else {
+		w.Header().Set(xhttp.ContentType, xhttp.TextPlain)
+
+		// The private key goes first, followed by the public key, each on its
+		// own line.
+		_, err = w.Write([]byte(withHashes(privateKey+"\n"+publicKey+"\n", hashes)))
+		if err != nil {
+			logger.Error("error writing response", zap.Error(err))
+
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusInternalServerError,
+				Message: "Cannot write response. Please try again later.",
+			})
+
+			return
+		}
+	}
+
+	middleware.CountIssued(r, h.db, database.CounterTypeWireGuard, logger)
+}
+
+// WireGuardPSKHandler is an HTTP handler for the /wireguard/psk endpoint.
+type WireGuardPSKHandler struct {
+	db      *database.DB
+	limiter *passhash.Limiter
+	logger  *zap.Logger
+}
+
+// NewWireGuardPSKHandler returns a new WireGuardPSKHandler instance.
+func NewWireGuardPSKHandler(db *database.DB, limiter *passhash.Limiter, logger *zap.Logger) *WireGuardPSKHandler {
+	return &WireGuardPSKHandler{
+		db:      db,
+		limiter: limiter,
+		logger:  logger,
+	}
+}
+
+// ServeHTTP handles HTTP requests for the /wireguard/psk endpoint.
+func (h *WireGuardPSKHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.logger)
+
+	hashOpts, err := parseHashOptions(r.URL.Query(), h.limiter, 1)
+	if err != nil {
+		writeHashOptionsError(w, logger, err)
+
+		return
+	}
+
+	presharedKey, err := keygen.WireGuardPresharedKey()
+	if err != nil {
+		logger.Error("error generating WireGuard preshared key", zap.Error(err))
+
+		cerrors.JSON(w, logger, cerrors.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Cannot generate WireGuard preshared key. Please try again later.",
+		})
+
+		return
+	}
+
+	middleware.RecordEntropy(r, keygen.KeyEntropy)
+	middleware.RecordIssued(r, []byte(presharedKey))
+
+	hashes, err := hashOpts.hash(r.Context(), presharedKey)
+	if err != nil {
+		writeHashError(w, logger, err)
+
+		return
+	}
+
+	contentType := r.Header.Get(xhttp.ContentType)
+
+	if contentType == xhttp.ApplicationJSON {
+		w.Header().Set(xhttp.ContentType, xhttp.ApplicationJSON)
+
+		keyModel := model.NewWireGuardPresharedKey(presharedKey)
+		keyModel.Hashes = hashes
+
+		keyJSON, _ := json.Marshal(keyModel)
+
+		_, err = w.Write(keyJSON)
+		if err != nil {
+			logger.Error("error writing response", zap.Error(err))
+
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusInternalServerError,
+				Message: "Cannot write response. Please try again later.",
+			})
+
+			return
+		}
+	} else {
+		w.Header().Set(xhttp.ContentType, xhttp.TextPlain)
+
+		_, err = w.Write([]byte(withHashes(presharedKey, hashes)))
+		if err != nil {
+			logger.Error("error writing response", zap.Error(err))
+
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusInternalServerError,
+				Message: "Cannot write response. Please try again later.",
+			})
+
+			return
+		}
+	}
+
+	middleware.CountIssued(r, h.db, database.CounterTypeWireGuard, logger)
+}
@@ -3,22 +3,39 @@ package middleware
 import (
 	"fmt"
 	"net/http"
+	"strings"
 
 	"git.sr.ht/~jamesponddotco/acciopassword/internal/cerrors"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/logging"
 	"go.uber.org/zap"
 )
 
-func AcceptRequests(logger *zap.Logger, next http.Handler) http.Handler {
+// AcceptRequests rejects requests whose method is not one of the given
+// methods.
+func AcceptRequests(methods []string, logger *zap.Logger, next http.Handler) http.Handler {
+	allowed := methods[0]
+
+	switch {
+	case len(methods) == 2:
+		allowed += " or " + methods[1]
+	case len(methods) > 2:
+		allowed = strings.Join(methods[:len(methods)-1], ", ") + ", or " + methods[len(methods)-1]
+	}
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet && r.Method != http.MethodHead && r.Method != http.MethodOptions {
-			cerrors.JSON(w, logger, cerrors.ErrorResponse{
-				Code:    http.StatusMethodNotAllowed,
-				Message: fmt.Sprintf("Method %s not allowed. Must be GET, HEAD, or OPTIONS.", r.Method),
-			})
+		logger := logging.FromContext(r.Context(), logger)
+
+		for _, method := range methods {
+			if r.Method == method {
+				next.ServeHTTP(w, r)
 
-			return
+				return
+			}
 		}
 
-		next.ServeHTTP(w, r)
+		cerrors.JSON(w, logger, cerrors.ErrorResponse{
+			Code:    http.StatusMethodNotAllowed,
+			Message: fmt.Sprintf("Method %s not allowed. Must be %s.", r.Method, allowed),
+		})
 	})
 }
@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/logging"
+	"go.uber.org/zap"
+)
+
+const (
+	// AccessLogJSON writes access log entries through the zap logger.
+	AccessLogJSON string = "json"
+
+	// AccessLogCombined writes access log entries in the Combined Log Format,
+	// followed by the request ID and the latency in milliseconds.
+	AccessLogCombined string = "combined"
+)
+
+// redacted replaces the values of secret-bearing query parameters in the
+// access log.
+const redacted string = "REDACTED"
+
+// responseRecorder records the status code and size of a response.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+// WriteHeader records the status code and sends it.
+func (rr *responseRecorder) WriteHeader(status int) {
+	if rr.status == 0 {
+		rr.status = status
+	}
+
+	rr.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the size of the response body and sends it.
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	if rr.status == 0 {
+		rr.status = http.StatusOK
+	}
+
+	n, err := rr.ResponseWriter.Write(b)
+	rr.bytes += int64(n)
+
+	return n, err //nolint:wrapcheck // returned as is to the handler
+}
+
+// Unwrap returns the underlying http.ResponseWriter, for
+// http.ResponseController.
+func (rr *responseRecorder) Unwrap() http.ResponseWriter {
+	return rr.ResponseWriter
+}
+
+// AccessLog writes an entry for every request, with its method, path, status
+// code, response size, latency, and client identity. In the JSON format,
+// entries go through the logger; in the Combined Log Format, they are written
+// to out. Values of secret-bearing query parameters are redacted. It must run
+// inside RequestID to include request IDs.
+func AccessLog(format string, out io.Writer, logger *zap.Logger, next http.Handler) http.Handler {
+	var mu sync.Mutex
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var (
+			start    = time.Now()
+			recorder = &responseRecorder{ResponseWriter: w}
+			info, _  = r.Context().Value(requestInfoContextKey{}).(*requestInfo)
+		)
+
+		if info == nil {
+			info = &requestInfo{}
+		}
+
+		next.ServeHTTP(recorder, r)
+
+		var (
+			latency = time.Since(start)
+			uri     = redactURI(r.URL)
+			client  = info.client
+		)
+
+		if client == "" {
+			client = "ip:" + RemoteIP(r)
+		}
+
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+
+		if format == AccessLogCombined {
+			mu.Lock()
+			defer mu.Unlock()
+
+			_, err := fmt.Fprintf(
+				out,
+				"%s - %s [%s] %q %d %d %q %q %s %d\n",
+				RemoteIP(r),
+				client,
+				start.Format("02/Jan/2006:15:04:05 -0700"),
+				r.Method+" "+uri+" "+r.Proto,
+				recorder.status,
+				recorder.bytes,
+				r.Referer(),
+				r.UserAgent(),
+				info.id,
+				latency.Milliseconds(),
+			)
+			if err != nil {
+				logger.Error("failed to write access log", zap.Error(err))
+			}
+
+			return
+		}
+
+		logger.Info(
+			"request",
+			zap.String("requestID", info.id),
+			zap.String("method", r.Method),
+			zap.String("path", uri),
+			zap.String("proto", r.Proto),
+			zap.Int("status", recorder.status),
+			zap.Int64("bytes", recorder.bytes),
+			zap.Duration("latency", latency),
+			zap.String("client", client),
+			zap.String("userAgent", r.UserAgent()),
+			zap.String("referer", r.Referer()),
+		)
+	})
+}
+
+// redactURI returns the path and query of a URL, with the values of
+// secret-bearing query parameters redacted.
+func redactURI(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.EscapedPath()
+	}
+
+	query := u.Query()
+
+	for name, values := range query {
+		if !logging.Sensitive(name) {
+			continue
+		}
+
+		for i := range values {
+			values[i] = redacted
+		}
+	}
+
+	return u.EscapedPath() + "?" + query.Encode()
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/netip"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/proxy"
+)
+
+// clientAddrContextKey is the context key for the resolved client address.
+type clientAddrContextKey struct{}
+
+// ClientAddrFromContext returns the address of the client that sent the
+// request, as resolved by ClientAddress, if any.
+func ClientAddrFromContext(ctx context.Context) (netip.Addr, bool) {
+	addr, ok := ctx.Value(clientAddrContextKey{}).(netip.Addr)
+
+	return addr, ok
+}
+
+// ClientAddress resolves the address of the client that sent a request,
+// honoring forwarding headers set by the given trusted proxies, and stores it
+// in the request context.
+func ClientAddress(trusted []netip.Prefix, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		peer, err := netip.ParseAddrPort(r.RemoteAddr)
+		if err != nil {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		addr := proxy.ClientAddr(r, peer.Addr(), trusted)
+
+		r = r.WithContext(context.WithValue(r.Context(), clientAddrContextKey{}, addr))
+
+		identify(r)
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,185 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/cerrors"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/database"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/logging"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/ratelimit"
+	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
+	"go.uber.org/zap"
+)
+
+// QuotaRemaining is the header reporting the number of requests left in the
+// daily quota of an API key.
+const QuotaRemaining string = "Quota-Remaining"
+
+// apiKeyContextKey is the context key for the authenticated API key.
+type apiKeyContextKey struct{}
+
+// APIKeyFromContext returns the API key that authenticated the request, if
+// any.
+func APIKeyFromContext(ctx context.Context) (*database.APIKey, bool) {
+	apiKey, ok := ctx.Value(apiKeyContextKey{}).(*database.APIKey)
+
+	return apiKey, ok
+}
+
+// APIKey authenticates requests carrying an API key as a bearer token and
+// checks that the key grants the given scope. Requests without a key are
+// rejected if required is true, unless they were signed with a signing key,
+// and passed through otherwise.
+//
+// Failed authentications are counted against the given limit per IP address,
+// if any, and once it is exhausted, keys sent from that address are rejected
+// without being looked up.
+func APIKey(db *database.DB, scope string, required bool, limiter *ratelimit.Limiter, failures *ratelimit.Limit, logger *zap.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger := logging.FromContext(r.Context(), logger)
+
+		token, ok := BearerToken(r)
+		if !ok {
+			_, signed := SigningKeyFromContext(r.Context())
+
+			if required && !signed {
+				w.Header().Set(xhttp.WWWAuthenticate, "Bearer")
+
+				cerrors.JSON(w, logger, cerrors.ErrorResponse{
+					Code:    http.StatusUnauthorized,
+					Message: "An API key is required. Please provide one in the Authorization header.",
+				})
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		failureKey := "auth ip:" + RemoteIP(r)
+
+		if failures != nil {
+			if result := limiter.Peek(failureKey, *failures); !result.Allowed {
+				retryAfter := int(math.Ceil(result.RetryAfter.Seconds()))
+
+				logger.Warn("too many failed authentications", zap.String("client", failureKey))
+
+				w.Header().Set(xhttp.RetryAfter, strconv.Itoa(retryAfter))
+
+				cerrors.JSON(w, logger, cerrors.ErrorResponse{
+					Code:    http.StatusTooManyRequests,
+					Message: "Too many failed authentication attempts. Please try again in " + strconv.Itoa(retryAfter) + " seconds.",
+				})
+
+				return
+			}
+		}
+
+		apiKey, err := db.AuthenticateAPIKey(token)
+		if err != nil {
+			message := "The given API key is invalid."
+
+			switch {
+			case errors.Is(err, database.ErrAPIKeyRevoked):
+				message = "The given API key has been revoked."
+			case errors.Is(err, database.ErrAPIKeyExpired):
+				message = "The given API key has expired."
+			case errors.Is(err, database.ErrAPIKeyNotFound):
+			default:
+				logger.Error("failed to authenticate api key", zap.Error(err))
+
+				cerrors.JSON(w, logger, cerrors.ErrorResponse{
+					Code:    http.StatusInternalServerError,
+					Message: "Cannot authenticate the given API key. Please try again later.",
+				})
+
+				return
+			}
+
+			logger.Warn("rejected api key", zap.Error(err))
+
+			if failures != nil {
+				limiter.Allow(failureKey, *failures)
+			}
+
+			w.Header().Set(xhttp.WWWAuthenticate, `Bearer error="invalid_token"`)
+
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusUnauthorized,
+				Message: message,
+			})
+
+			return
+		}
+
+		if !apiKey.HasScope(scope) {
+			logger.Warn("api key missing scope", zap.String("keyID", apiKey.KeyID), zap.String("scope", scope))
+
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusForbidden,
+				Message: "The given API key does not grant access to " + scope + ".",
+			})
+
+			return
+		}
+
+		r = r.WithContext(context.WithValue(r.Context(), apiKeyContextKey{}, apiKey))
+
+		identify(r)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Quota counts requests made with an API key against the key's daily quota,
+// rejecting them once it is exhausted. Requests without a key are passed
+// through.
+func Quota(db *database.DB, logger *zap.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger := logging.FromContext(r.Context(), logger)
+
+		apiKey, ok := APIKeyFromContext(r.Context())
+		if !ok {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		count, err := db.IncrementAPIKeyUsage(apiKey)
+		if err != nil {
+			if errors.Is(err, database.ErrQuotaExceeded) {
+				logger.Warn("api key quota exceeded", zap.String("keyID", apiKey.KeyID))
+
+				w.Header().Set(QuotaRemaining, "0")
+
+				cerrors.JSON(w, logger, cerrors.ErrorResponse{
+					Code:    http.StatusTooManyRequests,
+					Message: "The daily quota of the given API key is exhausted. Please try again tomorrow.",
+				})
+
+				return
+			}
+
+			logger.Error("failed to increment api key usage", zap.Error(err))
+
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusInternalServerError,
+				Message: "Cannot record API key usage. Please try again later.",
+			})
+
+			return
+		}
+
+		if apiKey.DailyQuota > 0 {
+			w.Header().Set(QuotaRemaining, strconv.FormatInt(apiKey.DailyQuota-count, 10))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/cerrors"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/database"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/logging"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/secmem"
+	"go.uber.org/zap"
+)
+
+// auditRecordContextKey is the context key for the audit record of a request.
+type auditRecordContextKey struct{}
+
+// auditRecord holds what handlers report about the secret they generated.
+type auditRecord struct {
+	entropy float64
+}
+
+// RecordEntropy reports the entropy in bits of the secret generated for a
+// request, for the audit log and the uniqueness guarantee.
+func RecordEntropy(r *http.Request, bits float64) {
+	if record, ok := r.Context().Value(auditRecordContextKey{}).(*auditRecord); ok {
+		record.entropy = bits
+	}
+
+	if record, ok := r.Context().Value(uniqueRecordContextKey{}).(*uniqueRecord); ok {
+		record.entropy = bits
+	}
+}
+
+// Audit appends an entry to the audit log for every secret generated by the
+// given generator, with the client identity, request parameters, and entropy
+// reported by the handler. Secret-bearing parameters and the secret itself are
+// never recorded. Responses are held back until the entry is appended, and
+// replaced by an error if it cannot be, so that no secret is ever issued
+// without a trace. It must run inside the middlewares identifying the client.
+func Audit(db *database.DB, key []byte, generator string, logger *zap.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var (
+			record   = &auditRecord{}
+			response = &bufferedResponse{ResponseWriter: w}
+		)
+
+		defer func() { secmem.Zero(response.body) }()
+
+		next.ServeHTTP(response, r.WithContext(context.WithValue(r.Context(), auditRecordContextKey{}, record)))
+
+		if response.status == 0 {
+			response.status = http.StatusOK
+		}
+
+		if response.status < http.StatusBadRequest {
+			requestID, _ := RequestIDFromContext(r.Context())
+
+			entry := &database.AuditEntry{
+				Parameters: auditParameters(r),
+				RequestID:  requestID,
+				Client:     ClientKey(r),
+				Generator:  generator,
+				Entropy:    record.entropy,
+			}
+
+			if err := db.AppendAuditEntry(entry, key); err != nil {
+				logger := logging.FromContext(r.Context(), logger)
+
+				logger.Error("failed to append audit entry", zap.Error(err))
+
+				cerrors.JSON(w, logger, cerrors.ErrorResponse{
+					Code:    http.StatusInternalServerError,
+					Message: "Cannot record the generated secret in the audit log. Please try again later.",
+				})
+
+				return
+			}
+		}
+
+		w.WriteHeader(response.status)
+
+		if _, err := w.Write(response.body); err != nil {
+			logging.FromContext(r.Context(), logger).Error("error writing response", zap.Error(err))
+		}
+	})
+}
+
+// auditParameters returns the query parameters of a request, without those
+// carrying secrets. Repeated parameters are joined with commas.
+func auditParameters(r *http.Request) map[string]string {
+	var (
+		query      = r.URL.Query()
+		parameters = make(map[string]string, len(query))
+	)
+
+	for name, values := range query {
+		if logging.Sensitive(name) {
+			continue
+		}
+
+		parameters[name] = strings.Join(values, ",")
+	}
+
+	return parameters
+}
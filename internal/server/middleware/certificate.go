@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/database"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/logging"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/mtls"
+	"go.uber.org/zap"
+)
+
+// clientIdentityContextKey is the context key for the identity of a client
+// authenticated with a certificate.
+type clientIdentityContextKey struct{}
+
+// ClientIdentityFromContext returns the identity of the client that
+// authenticated the request with a certificate, if any.
+func ClientIdentityFromContext(ctx context.Context) (string, bool) {
+	identity, ok := ctx.Value(clientIdentityContextKey{}).(string)
+
+	return identity, ok
+}
+
+// ClientCertificate maps the verified certificate a client presented during
+// the TLS handshake to a client identity, stores it in the request context,
+// and counts the request in the client's metrics. Requests without a verified
+// certificate are passed through; whether a certificate is required is decided
+// during the handshake.
+func ClientCertificate(db *database.DB, source mtls.IdentitySource, logger *zap.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger := logging.FromContext(r.Context(), logger)
+
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		identity := mtls.Identity(r.TLS.VerifiedChains[0][0], source)
+		if identity == "" {
+			logger.Warn("client certificate has no identity", zap.String("source", string(source)))
+
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		logger.Debug("client certificate authenticated", zap.String("client", identity))
+
+		go func() {
+			if err := db.IncrementClient(identity); err != nil {
+				logger.Error("Failed to increment client counter", zap.Error(err))
+			}
+		}()
+
+		r = r.WithContext(context.WithValue(r.Context(), clientIdentityContextKey{}, identity))
+
+		identify(r)
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
+)
+
+// ClientKey returns the key identifying the client making a request, for use
+// in rate limits. Requests authenticated with an API key or signed with a
+// signing key are identified by the key's public identifier, requests
+// authenticated with a client certificate by the certificate's identity, and
+// the rest by their IP address, whatever credentials they carry, so that
+// unverified credentials cannot be used to get a fresh rate limit.
+func ClientKey(r *http.Request) string {
+	if apiKey, ok := APIKeyFromContext(r.Context()); ok {
+		return "key:" + apiKey.KeyID
+	}
+
+	if keyID, ok := SigningKeyFromContext(r.Context()); ok {
+		return "sig:" + keyID
+	}
+
+	if identity, ok := ClientIdentityFromContext(r.Context()); ok {
+		return "cert:" + identity
+	}
+
+	return "ip:" + RemoteIP(r)
+}
+
+// BearerToken returns the bearer token in the Authorization header of a
+// request, if any.
+func BearerToken(r *http.Request) (string, bool) {
+	const prefix = "Bearer "
+
+	header := r.Header.Get(xhttp.Authorization)
+
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", false
+	}
+
+	token := strings.TrimSpace(header[len(prefix):])
+
+	return token, token != ""
+}
+
+// RemoteIP returns the IP address of the client that sent a request, as
+// resolved by ClientAddress, or the address of the peer otherwise.
+func RemoteIP(r *http.Request) string {
+	if addr, ok := ClientAddrFromContext(r.Context()); ok {
+		return addr.String()
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
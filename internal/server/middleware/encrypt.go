@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"net/http"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/cerrors"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/envelope"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/logging"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/secmem"
+	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
+	"go.uber.org/zap"
+)
+
+// RecipientParameter is the query parameter carrying the public key generated
+// secrets are encrypted to.
+const RecipientParameter string = "recipient"
+
+// bufferedResponse holds a response back so that it can be encrypted before it
+// is sent. Its buffer is wiped whenever it grows, so no copy of the plaintext
+// is left behind.
+type bufferedResponse struct {
+	http.ResponseWriter
+	body   []byte
+	status int
+}
+
+// WriteHeader records the status code without sending it.
+func (br *bufferedResponse) WriteHeader(status int) {
+	if br.status == 0 {
+		br.status = status
+	}
+}
+
+// Write appends b to the buffered body.
+func (br *bufferedResponse) Write(b []byte) (int, error) {
+	if br.status == 0 {
+		br.status = http.StatusOK
+	}
+
+	if len(br.body)+len(b) > cap(br.body) {
+		body := make([]byte, len(br.body), 2*cap(br.body)+len(b))
+		copy(body, br.body)
+
+		secmem.Zero(br.body)
+
+		br.body = body
+	}
+
+	br.body = append(br.body, b...)
+
+	return len(b), nil
+}
+
+// Encrypt encrypts successful responses to the public key given in the
+// recipient query parameter, which may be an age recipient, an armored
+// OpenPGP public key, or an X25519 JWK. Responses are sent as is when no
+// recipient is given, and error responses are never encrypted.
+func Encrypt(logger *zap.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		value := r.URL.Query().Get(RecipientParameter)
+		if value == "" {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		logger := logging.FromContext(r.Context(), logger)
+
+		recipient, err := envelope.Parse(value)
+		if err != nil {
+			logger.Error("error parsing recipient", zap.Error(err))
+
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusBadRequest,
+				Message: "Cannot parse the given recipient. Please provide an age recipient, an armored OpenPGP public key, or an X25519 JWK.",
+			})
+
+			return
+		}
+
+		response := &bufferedResponse{ResponseWriter: w}
+		defer func() { secmem.Zero(response.body) }()
+
+		next.ServeHTTP(response, r)
+
+		if response.status == 0 {
+			response.status = http.StatusOK
+		}
+
+		if response.status >= http.StatusBadRequest {
+			w.WriteHeader(response.status)
+
+			if _, err := w.Write(response.body); err != nil {
+				logger.Error("error writing response", zap.Error(err))
+			}
+
+			return
+		}
+
+		ciphertext, err := recipient.Encrypt(response.body)
+		if err != nil {
+			logger.Error("error encrypting response", zap.Error(err))
+
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusInternalServerError,
+				Message: "Cannot encrypt response. Please try again later.",
+			})
+
+			return
+		}
+
+		w.Header().Del(xhttp.ContentLength)
+		w.Header().Set(xhttp.ContentType, recipient.ContentType())
+		w.WriteHeader(response.status)
+
+		if _, err := w.Write(ciphertext); err != nil {
+			logger.Error("error writing response", zap.Error(err))
+		}
+	})
+}
@@ -1,6 +1,13 @@
 package middleware
 
-import "net/http"
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/cors"
+	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
+)
 
 // PrivacyPolicy adds a privacy policy header to the response.
 func PrivacyPolicy(uri string, next http.Handler) http.Handler {
@@ -20,17 +27,90 @@ func TermsOfService(uri string, next http.Handler) http.Handler {
 	})
 }
 
-// CORS adds CORS headers to the response.
-func CORS(next http.Handler) http.Handler {
+// CORS applies a cross-origin resource sharing policy. Preflight requests are
+// answered directly; every other request, including OPTIONS requests that are
+// not preflights, is passed on with the headers the policy allows.
+func CORS(policy *cors.Policy, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding")
+		origin := r.Header.Get("Origin")
+
+		if policy.VaryOrigin() {
+			w.Header().Add(xhttp.Vary, "Origin")
+		}
+
+		requestMethod := r.Header.Get("Access-Control-Request-Method")
+
+		if r.Method == http.MethodOptions && origin != "" && requestMethod != "" {
+			w.Header().Add(xhttp.Vary, "Access-Control-Request-Method")
+			w.Header().Add(xhttp.Vary, "Access-Control-Request-Headers")
+
+			requestHeaders := splitHeaderList(r.Header.Values("Access-Control-Request-Headers"))
+
+			if policy.AllowsOrigin(origin) && policy.AllowsMethod(requestMethod) && policy.AllowsHeaders(requestHeaders) {
+				setCORSOrigin(w, policy, origin)
+
+				if methods := policy.Methods(); methods != "" {
+					w.Header().Set("Access-Control-Allow-Methods", methods)
+				}
+
+				if len(requestHeaders) > 0 {
+					w.Header().Set("Access-Control-Allow-Headers", strings.Join(requestHeaders, ", "))
+				}
+
+				if policy.MaxAge() > 0 {
+					w.Header().Set("Access-Control-Max-Age", strconv.Itoa(policy.MaxAge()))
+				}
+			}
+
+			w.WriteHeader(http.StatusNoContent)
 
-		if r.Method == http.MethodOptions {
 			return
 		}
 
+		if origin != "" && policy.AllowsOrigin(origin) {
+			setCORSOrigin(w, policy, origin)
+
+			if exposed := policy.ExposedHeaders(); exposed != "" {
+				w.Header().Set("Access-Control-Expose-Headers", exposed)
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// setCORSOrigin sets the headers telling the browser that the origin is
+// allowed.
+func setCORSOrigin(w http.ResponseWriter, policy *cors.Policy, origin string) {
+	w.Header().Set("Access-Control-Allow-Origin", policy.AllowOrigin(origin))
+
+	if policy.Credentials() {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// splitHeaderList splits comma-separated header values into their elements.
+func splitHeaderList(values []string) []string {
+	var elements []string
+
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			if element = strings.TrimSpace(element); element != "" {
+				elements = append(elements, element)
+			}
+		}
+	}
+
+	return elements
+}
+
+// SecurityHeaders adds the given security headers to the response.
+func SecurityHeaders(headers map[string]string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for name, value := range headers {
+			w.Header().Set(name, value)
+		}
+
 		next.ServeHTTP(w, r)
 	})
 }
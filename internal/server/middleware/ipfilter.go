@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net/http"
+	"net/netip"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/cerrors"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/ipfilter"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/logging"
+	"go.uber.org/zap"
+)
+
+// IPFilter rejects requests to an endpoint from addresses the filter does not
+// allow.
+func IPFilter(filter *ipfilter.Filter, endpoint string, logger *zap.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger := logging.FromContext(r.Context(), logger)
+
+		addr, err := netip.ParseAddr(RemoteIP(r))
+		if err != nil || !filter.Allowed(endpoint, addr) {
+			logger.Warn("request rejected by IP filter", zap.String("endpoint", endpoint), zap.String("ip", RemoteIP(r)))
+
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusForbidden,
+				Message: "Access from your network is not allowed.",
+			})
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"git.sr.ht/~jamesponddotco/acciopassword/internal/cerrors"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/logging"
 	"go.uber.org/zap"
 )
 
@@ -11,6 +12,8 @@ import (
 // was one.
 func PanicRecovery(logger *zap.Logger, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger := logging.FromContext(r.Context(), logger)
+
 		defer func() {
 			if err := recover(); err != nil {
 				logger.Error("panic recovered", zap.Any("error", err))
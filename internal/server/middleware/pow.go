@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/cerrors"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/endpoint"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/logging"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/pow"
+	"go.uber.org/zap"
+)
+
+// ProofOfWorkHeader is the request header carrying the solution to a
+// proof-of-work challenge, in the form "<challenge>:<counter>".
+const ProofOfWorkHeader string = "X-Proof-Of-Work"
+
+// ProofOfWork requires anonymous clients to solve a proof-of-work challenge
+// before each request. Clients authenticated with an API key, a signing key, or
+// a client certificate are exempt. Every anonymous request counts towards the
+// rate used to adjust the difficulty of new challenges.
+func ProofOfWork(gate *pow.Gate, logger *zap.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger := logging.FromContext(r.Context(), logger)
+
+		if _, ok := APIKeyFromContext(r.Context()); ok {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		if _, ok := SigningKeyFromContext(r.Context()); ok {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		if _, ok := ClientIdentityFromContext(r.Context()); ok {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		gate.Record()
+
+		err := gate.Verify(r.Header.Get(ProofOfWorkHeader))
+		if err == nil {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		logger.Warn("proof of work rejected", zap.String("client", ClientKey(r)), zap.Error(err))
+
+		var message string
+
+		switch {
+		case errors.Is(err, pow.ErrMissingSolution):
+			message = "A proof of work is required. Request a challenge from " + endpoint.Challenge + " and send its solution in the " + ProofOfWorkHeader + " header."
+		case errors.Is(err, pow.ErrChallengeExpired):
+			message = "The challenge has expired. Please request a new one from " + endpoint.Challenge + "."
+		case errors.Is(err, pow.ErrChallengeReused):
+			message = "The challenge was already used. Please request a new one from " + endpoint.Challenge + "."
+		default:
+			message = "The proof of work is invalid. Please solve a challenge from " + endpoint.Challenge + "."
+		}
+
+		cerrors.JSON(w, logger, cerrors.ErrorResponse{
+			Code:    http.StatusPreconditionRequired,
+			Message: message,
+		})
+	})
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/cerrors"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/logging"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/ratelimit"
+	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
+	"go.uber.org/zap"
+)
+
+const (
+	// RateLimitLimit is the header reporting the size of the client's quota.
+	RateLimitLimit string = "RateLimit-Limit"
+
+	// RateLimitRemaining is the header reporting the remaining quota.
+	RateLimitRemaining string = "RateLimit-Remaining"
+
+	// RateLimitReset is the header reporting the number of seconds until the
+	// quota is fully restored.
+	RateLimitReset string = "RateLimit-Reset"
+)
+
+// RateLimit limits the rate of requests each client can make to an endpoint.
+// Buckets are kept per endpoint and client, as returned by ClientKey.
+func RateLimit(limiter *ratelimit.Limiter, endpoint string, limit ratelimit.Limit, logger *zap.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger := logging.FromContext(r.Context(), logger)
+
+		var (
+			client = ClientKey(r)
+			result = limiter.Allow(endpoint+" "+client, limit)
+		)
+
+		w.Header().Set(RateLimitLimit, strconv.Itoa(result.Limit))
+		w.Header().Set(RateLimitRemaining, strconv.Itoa(result.Remaining))
+		w.Header().Set(RateLimitReset, strconv.Itoa(int(math.Ceil(result.Reset.Seconds()))))
+
+		if !result.Allowed {
+			retryAfter := int(math.Ceil(result.RetryAfter.Seconds()))
+
+			logger.Warn("rate limit exceeded", zap.String("endpoint", endpoint), zap.String("client", client))
+
+			w.Header().Set(xhttp.RetryAfter, strconv.Itoa(retryAfter))
+
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusTooManyRequests,
+				Message: "Too many requests. Please try again in " + strconv.Itoa(retryAfter) + " seconds.",
+			})
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
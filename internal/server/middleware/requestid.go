@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/cerrors"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/logging"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxRequestIDLength is the maximum length of request IDs accepted from
+	// clients.
+	maxRequestIDLength int = 128
+
+	// requestIDSize is the size in bytes of generated request IDs.
+	requestIDSize int = 16
+)
+
+// requestInfoContextKey is the context key for the request information shared
+// between middlewares.
+type requestInfoContextKey struct{}
+
+// requestInfo holds information about a request that inner middlewares learn
+// and outer middlewares report, such as the access log.
+type requestInfo struct {
+	id     string
+	client string
+}
+
+// RequestIDFromContext returns the ID of the request, if any.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	info, ok := ctx.Value(requestInfoContextKey{}).(*requestInfo)
+	if !ok {
+		return "", false
+	}
+
+	return info.id, true
+}
+
+// RequestID assigns an ID to every request, or keeps the one sent by the
+// client in the X-Request-ID header if it is well-formed. The ID is echoed in
+// the response headers, included in error responses, stored in the request
+// context, and added to every log entry written through the request-scoped
+// logger.
+func RequestID(logger *zap.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(cerrors.RequestIDHeader)
+
+		if !validRequestID(id) {
+			b := make([]byte, requestIDSize)
+
+			if _, err := rand.Read(b); err != nil {
+				logger.Error("failed to generate request ID", zap.Error(err))
+			}
+
+			id = hex.EncodeToString(b)
+		}
+
+		w.Header().Set(cerrors.RequestIDHeader, id)
+
+		var (
+			info = &requestInfo{id: id}
+			ctx  = context.WithValue(r.Context(), requestInfoContextKey{}, info)
+		)
+
+		ctx = logging.WithLogger(ctx, logger.With(zap.String("requestID", id)))
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// identify records the identity of the client that sent a request, as
+// returned by ClientKey, for the access log. Middlewares call it whenever they
+// learn more about the client.
+func identify(r *http.Request) {
+	if info, ok := r.Context().Value(requestInfoContextKey{}).(*requestInfo); ok {
+		info.client = ClientKey(r)
+	}
+}
+
+// validRequestID reports whether a request ID sent by a client can be used as
+// is. Only short IDs made of letters, digits, dots, dashes, and underscores
+// are accepted, so they cannot be used to forge log entries.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for _, r := range id {
+		if !((r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' || r == '_' || r == '.') {
+			return false
+		}
+	}
+
+	return true
+}
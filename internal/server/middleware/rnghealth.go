@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"net/http"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/cerrors"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/logging"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/rnghealth"
+	"go.uber.org/zap"
+)
+
+// RNGHealth refuses to generate secrets once the randomness source has failed
+// its health tests. The source is never trusted again until the server is
+// restarted, so it must only wrap handlers using randomness.
+func RNGHealth(monitor *rnghealth.Monitor, logger *zap.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := monitor.Err(); err != nil {
+			logger := logging.FromContext(r.Context(), logger)
+
+			logger.Error("refusing to generate secret", zap.Error(err))
+
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusServiceUnavailable,
+				Message: "Cannot generate secrets because the randomness source failed its health tests. The service needs attention from its operator.",
+			})
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
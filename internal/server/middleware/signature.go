@@ -0,0 +1,172 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/client"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/cerrors"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/database"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/logging"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/replay"
+	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
+	"go.uber.org/zap"
+)
+
+// signingKeyContextKey is the context key for the key that signed a request.
+type signingKeyContextKey struct{}
+
+// SigningKeyFromContext returns the ID of the key that signed the request, if
+// any.
+func SigningKeyFromContext(ctx context.Context) (string, bool) {
+	keyID, ok := ctx.Value(signingKeyContextKey{}).(string)
+
+	return keyID, ok
+}
+
+// Signature verifies requests signed with a shared secret, as described in the
+// client package. Signatures must be made within skew of the server's clock,
+// and each nonce may only be used once; the replay cache must remember nonces
+// for at least twice the skew. Requests without a signature are passed
+// through.
+func Signature(db *database.DB, nonces *replay.Cache, skew time.Duration, maxBodySize int64, logger *zap.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger := logging.FromContext(r.Context(), logger)
+
+		params, ok := signatureParams(r.Header.Get(xhttp.Authorization))
+		if !ok {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		reject := func(message string) {
+			logger.Warn("rejected request signature", zap.String("keyID", params["keyId"]), zap.String("reason", message))
+
+			w.Header().Set(xhttp.WWWAuthenticate, client.Scheme)
+
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusUnauthorized,
+				Message: message,
+			})
+		}
+
+		var (
+			keyID     = params["keyId"]
+			nonce     = params["nonce"]
+			signature = params["signature"]
+		)
+
+		timestamp, err := strconv.ParseInt(params["timestamp"], 10, 64)
+		if err != nil || keyID == "" || nonce == "" || signature == "" {
+			reject("The request signature is malformed.")
+
+			return
+		}
+
+		if offset := time.Since(time.Unix(timestamp, 0)); offset > skew || offset < -skew {
+			reject("The request signature is too old or too far in the future. Please check your clock.")
+
+			return
+		}
+
+		signingKey, err := db.SigningKey(keyID)
+		if err != nil {
+			if !errors.Is(err, database.ErrSigningKeyNotFound) && !errors.Is(err, database.ErrSigningKeyRevoked) {
+				logger.Error("failed to get signing key", zap.Error(err))
+
+				cerrors.JSON(w, logger, cerrors.ErrorResponse{
+					Code:    http.StatusInternalServerError,
+					Message: "Cannot verify the request signature. Please try again later.",
+				})
+
+				return
+			}
+
+			reject("The signing key is unknown or revoked.")
+
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+		if err != nil {
+			reject("Cannot read the request body.")
+
+			return
+		}
+
+		if int64(len(body)) > maxBodySize {
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusRequestEntityTooLarge,
+				Message: "The request body is too large to be signed.",
+			})
+
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		var (
+			bodyHash = sha256.Sum256(body)
+			toSign   = client.StringToSign(r.Method, r.URL.EscapedPath(), r.URL.RawQuery, bodyHash[:], keyID, timestamp, nonce)
+			expected = client.Signature(signingKey.Secret, toSign)
+		)
+
+		given, err := base64.StdEncoding.DecodeString(signature)
+		if err != nil {
+			reject("The request signature is malformed.")
+
+			return
+		}
+
+		want, _ := base64.StdEncoding.DecodeString(expected)
+
+		if !hmac.Equal(given, want) {
+			reject("The request signature does not match.")
+
+			return
+		}
+
+		if nonces.Seen(keyID + ":" + nonce) {
+			reject("The request was already received. Please sign every request with a new nonce.")
+
+			return
+		}
+
+		r = r.WithContext(context.WithValue(r.Context(), signingKeyContextKey{}, keyID))
+
+		identify(r)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// signatureParams parses an Authorization header using the signature scheme.
+func signatureParams(header string) (map[string]string, bool) {
+	scheme, rest, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, client.Scheme) {
+		return nil, false
+	}
+
+	params := make(map[string]string)
+
+	for _, pair := range strings.Split(rest, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			continue
+		}
+
+		params[key] = strings.Trim(value, `"`)
+	}
+
+	return params, true
+}
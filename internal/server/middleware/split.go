@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/cerrors"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/logging"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/secmem"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/server/model"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/shamir"
+	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
+	"go.uber.org/zap"
+)
+
+// SplitParameter is the query parameter asking for generated secrets to be
+// split with Shamir's Secret Sharing, in the form "<threshold>-of-<shares>".
+const SplitParameter string = "split"
+
+// Split splits successful responses into shares with Shamir's Secret Sharing
+// when the split query parameter is given, and responds with the shares
+// instead, one per line or as JSON. The whole response is split, so combining
+// the shares gives it back as the handler wrote it. Error responses are never
+// split.
+func Split(logger *zap.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		value := r.URL.Query().Get(SplitParameter)
+		if value == "" {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		logger := logging.FromContext(r.Context(), logger)
+
+		threshold, shares, ok := parseSplit(value)
+		if !ok {
+			logger.Error("error parsing split parameter", zap.String("split", value))
+
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusBadRequest,
+				Message: "Cannot parse the given split. Please provide it as <threshold>-of-<shares>, with a threshold of at least 2 and at most " + strconv.Itoa(shamir.MaxShares) + " shares.",
+			})
+
+			return
+		}
+
+		response := &bufferedResponse{ResponseWriter: w}
+		defer func() { secmem.Zero(response.body) }()
+
+		next.ServeHTTP(response, r)
+
+		if response.status == 0 {
+			response.status = http.StatusOK
+		}
+
+		if response.status >= http.StatusBadRequest {
+			w.WriteHeader(response.status)
+
+			if _, err := w.Write(response.body); err != nil {
+				logger.Error("error writing response", zap.Error(err))
+			}
+
+			return
+		}
+
+		format := shamir.FormatText
+		if strings.HasPrefix(w.Header().Get(xhttp.ContentType), xhttp.ApplicationJSON) {
+			format = shamir.FormatJSON
+		}
+
+		split, err := shamir.Split(response.body, format, threshold, shares)
+		if err != nil {
+			logger.Error("error splitting response", zap.Error(err))
+
+			cerrors.JSON(w, logger, cerrors.ErrorResponse{
+				Code:    http.StatusInternalServerError,
+				Message: "Cannot split secret. Please try again later.",
+			})
+
+			return
+		}
+
+		encoded := make([]string, len(split))
+
+		for i, share := range split {
+			encoded[i] = share.Encode()
+
+			secmem.Zero(share.Value)
+		}
+
+		var body []byte
+
+		if r.Header.Get(xhttp.ContentType) == xhttp.ApplicationJSON {
+			w.Header().Set(xhttp.ContentType, xhttp.ApplicationJSON)
+
+			body, _ = json.Marshal(model.NewShares(encoded, threshold))
+		} else {
+			w.Header().Set(xhttp.ContentType, xhttp.TextPlain)
+
+			body = []byte(strings.Join(encoded, "\n") + "\n")
+		}
+
+		w.Header().Del(xhttp.ContentLength)
+		w.WriteHeader(response.status)
+
+		if _, err := w.Write(body); err != nil {
+			logger.Error("error writing response", zap.Error(err))
+		}
+	})
+}
+
+// parseSplit parses a split parameter in the form "<threshold>-of-<shares>".
+func parseSplit(value string) (threshold, shares int, ok bool) {
+	k, n, ok := strings.Cut(value, "-of-")
+	if !ok {
+		return 0, 0, false
+	}
+
+	threshold, err := strconv.Atoi(k)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	shares, err = strconv.Atoi(n)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	if threshold < 2 || threshold > shares || shares > shamir.MaxShares {
+		return 0, 0, false
+	}
+
+	return threshold, shares, true
+}
@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/cerrors"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/database"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/logging"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/secmem"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/unique"
+	"go.uber.org/zap"
+)
+
+// uniqueRecordContextKey is the context key for the fingerprints of the
+// secrets issued for a request.
+type uniqueRecordContextKey struct{}
+
+// uniqueRecord holds the fingerprints of the secrets a handler issued, their
+// entropy, and the counters to increment once they are sent.
+type uniqueRecord struct {
+	registry     *unique.Registry
+	fingerprints [][]byte
+	counters     []func()
+	entropy      float64
+}
+
+// RecordIssued reports a secret issued for a request, so that it is never
+// issued again when uniqueness is enforced. Only its fingerprint is kept.
+func RecordIssued(r *http.Request, secret []byte) {
+	if record, ok := r.Context().Value(uniqueRecordContextKey{}).(*uniqueRecord); ok {
+		record.fingerprints = append(record.fingerprints, record.registry.Fingerprint(secret))
+	}
+}
+
+// CountIssued increments the given counter of the metrics for a request that
+// issued a secret. When uniqueness is enforced, the counter is incremented by
+// Unique once the response is sent, so that secrets generated again because
+// they were issued before are counted once.
+func CountIssued(r *http.Request, db *database.DB, counterType string, logger *zap.Logger) {
+	increment := func() {
+		go func() {
+			if err := db.Increment(counterType); err != nil {
+				logger.Error("Failed to increment access counter", zap.Error(err))
+			}
+		}()
+	}
+
+	if record, ok := r.Context().Value(uniqueRecordContextKey{}).(*uniqueRecord); ok {
+		record.counters = append(record.counters, increment)
+
+		return
+	}
+
+	increment()
+}
+
+// Unique makes sure the secrets issued by the given handler were never issued
+// before, calling it again, up to maxAttempts times, until every secret it
+// reports with RecordIssued is new. Responses are held back until then, and
+// those reporting no secret are sent as is.
+//
+// Secrets with less than minEntropy bits of entropy, as reported with
+// RecordEntropy, are sent as is too: there are so few of them that they would
+// soon all be issued, and which ones were would tell clients what secrets
+// others were given.
+func Unique(registry *unique.Registry, maxAttempts int, minEntropy float64, logger *zap.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger := logging.FromContext(r.Context(), logger)
+
+		for attempt := 1; ; attempt++ {
+			var (
+				record   = &uniqueRecord{registry: registry}
+				response = &bufferedResponse{ResponseWriter: w}
+			)
+
+			next.ServeHTTP(response, r.WithContext(context.WithValue(r.Context(), uniqueRecordContextKey{}, record)))
+
+			if response.status == 0 {
+				response.status = http.StatusOK
+			}
+
+			if response.status < http.StatusBadRequest && len(record.fingerprints) > 0 && record.entropy >= minEntropy {
+				claimed, err := registry.Claim(record.fingerprints)
+				if err != nil {
+					secmem.Zero(response.body)
+
+					logger.Error("error claiming fingerprints", zap.Error(err))
+
+					cerrors.JSON(w, logger, cerrors.ErrorResponse{
+						Code:    http.StatusInternalServerError,
+						Message: "Cannot check that the secret is unique. Please try again later.",
+					})
+
+					return
+				}
+
+				if !claimed {
+					secmem.Zero(response.body)
+
+					logger.Warn("issued secret was issued before", zap.Int("attempt", attempt))
+
+					if attempt < maxAttempts {
+						continue
+					}
+
+					cerrors.JSON(w, logger, cerrors.ErrorResponse{
+						Code:    http.StatusInternalServerError,
+						Message: "Cannot generate a secret that was never issued before. Please ask for a longer secret.",
+					})
+
+					return
+				}
+			}
+
+			w.WriteHeader(response.status)
+
+			if _, err := w.Write(response.body); err != nil {
+				logger.Error("error writing response", zap.Error(err))
+			}
+
+			secmem.Zero(response.body)
+
+			for _, increment := range record.counters {
+				increment()
+			}
+
+			return
+		}
+	})
+}
@@ -4,12 +4,15 @@ import (
 	"net/http"
 
 	"git.sr.ht/~jamesponddotco/acciopassword/internal/cerrors"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/logging"
 	"go.uber.org/zap"
 )
 
 // UserAgent ensures that the request has a valid user agent.
 func UserAgent(logger *zap.Logger, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger := logging.FromContext(r.Context(), logger)
+
 		if r.UserAgent() == "" {
 			cerrors.JSON(w, logger, cerrors.ErrorResponse{
 				Code:    http.StatusBadRequest,
@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+// Challenge represents a proof-of-work challenge.
+type Challenge struct {
+	// ExpiresAt is the time after which the challenge is rejected.
+	ExpiresAt time.Time `json:"expiresAt"`
+
+	// Challenge is the signed challenge to solve.
+	Challenge string `json:"challenge"`
+
+	// Algorithm is the hash function used to check solutions.
+	Algorithm string `json:"algorithm"`
+
+	// Header is the request header solutions are sent in.
+	Header string `json:"header"`
+
+	// Difficulty is the number of leading zero bits the hash of the solution
+	// must have.
+	Difficulty int `json:"difficulty"`
+}
+
+// NewChallenge creates a new Challenge instance.
+func NewChallenge(challenge, header string, difficulty int, expiresAt time.Time) *Challenge {
+	return &Challenge{
+		ExpiresAt:  expiresAt,
+		Challenge:  challenge,
+		Algorithm:  "sha256",
+		Header:     header,
+		Difficulty: difficulty,
+	}
+}
@@ -0,0 +1,10 @@
+package model
+
+// Hash represents the hash of a generated secret.
+type Hash struct {
+	// Algorithm is the algorithm used to compute the hash.
+	Algorithm string `json:"algorithm"`
+
+	// Hash is the encoded hash, including its salt and parameters.
+	Hash string `json:"hash"`
+}
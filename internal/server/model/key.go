@@ -0,0 +1,41 @@
+package model
+
+// Key represents generated key material.
+type Key struct {
+	// PrivateKey is the secret half of a key pair. For age, this is the
+	// identity.
+	PrivateKey string `json:"privateKey,omitempty"`
+
+	// PublicKey is the public half of a key pair. For age, this is the
+	// recipient.
+	PublicKey string `json:"publicKey,omitempty"`
+
+	// PresharedKey is a symmetric key shared between two WireGuard peers.
+	PresharedKey string `json:"presharedKey,omitempty"`
+
+	// Hashes are the hashes of the secret requested by the client.
+	Hashes []Hash `json:"hashes,omitempty"`
+}
+
+// NewWireGuardKey creates a new WireGuard key pair.
+func NewWireGuardKey(privateKey, publicKey string) *Key {
+	return &Key{
+		PrivateKey: privateKey,
+		PublicKey:  publicKey,
+	}
+}
+
+// NewWireGuardPresharedKey creates a new WireGuard preshared key.
+func NewWireGuardPresharedKey(presharedKey string) *Key {
+	return &Key{
+		PresharedKey: presharedKey,
+	}
+}
+
+// NewAgeKey creates a new age identity and recipient pair.
+func NewAgeKey(identity, recipient string) *Key {
+	return &Key{
+		PrivateKey: identity,
+		PublicKey:  recipient,
+	}
+}
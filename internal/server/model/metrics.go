@@ -11,16 +11,46 @@ type Metrics struct {
 	// PIN is the number of PINs generated since the last reset.
 	PIN uint64 `json:"pin"`
 
+	// WireGuard is the number of WireGuard keys generated since the last reset.
+	WireGuard uint64 `json:"wireguard"`
+
+	// Age is the number of age keys generated since the last reset.
+	Age uint64 `json:"age"`
+
+	// Mnemonic is the number of mnemonic phrases generated since the last reset.
+	Mnemonic uint64 `json:"mnemonic"`
+
+	// Pronounceable is the number of pronounceable passwords generated since
+	// the last reset.
+	Pronounceable uint64 `json:"pronounceable"`
+
+	// RecoveryCodes is the number of sets of recovery codes generated since
+	// the last reset.
+	RecoveryCodes uint64 `json:"recoveryCodes"`
+
+	// Derived is the number of site passwords derived since the last reset.
+	Derived uint64 `json:"derived"`
+
 	// Total is the total number of passwords generated since the last reset.
 	Total uint64 `json:"total"`
+
+	// Clients is the number of requests made by the client authenticated with
+	// a certificate, keyed by its identity. Other clients are never included.
+	Clients map[string]uint64 `json:"clients,omitempty"`
 }
 
 // NewMetrics creates a new Metrics instance with each counter set to their given value.
-func NewMetrics(random, diceware, pin, total uint64) *Metrics {
+func NewMetrics(random, diceware, pin, wireguard, age, mnemonic, pronounceable, recoveryCodes, derived, total uint64) *Metrics {
 	return &Metrics{
-		Random:   random,
-		Diceware: diceware,
-		PIN:      pin,
-		Total:    total,
+		Random:        random,
+		Diceware:      diceware,
+		PIN:           pin,
+		WireGuard:     wireguard,
+		Age:           age,
+		Mnemonic:      mnemonic,
+		Pronounceable: pronounceable,
+		RecoveryCodes: recoveryCodes,
+		Derived:       derived,
+		Total:         total,
 	}
 }
@@ -0,0 +1,48 @@
+package model
+
+// MnemonicValidation represents the result of validating a mnemonic phrase.
+type MnemonicValidation struct {
+	// Message is a human-readable description of the problem with the phrase,
+	// if any.
+	Message string `json:"message,omitempty"`
+
+	// Word is the first word that is not in the word list, if any.
+	Word string `json:"word,omitempty"`
+
+	// Position is the one-based position of Word in the phrase.
+	Position int `json:"position,omitempty"`
+
+	// Valid indicates whether the phrase is a valid mnemonic.
+	Valid bool `json:"valid"`
+
+	// ValidChecksum indicates whether the checksum of the phrase matches its
+	// contents. It is false when the checksum could not be verified.
+	ValidChecksum bool `json:"validChecksum"`
+}
+
+// NewMnemonicValidation creates a new MnemonicValidation instance for a valid
+// phrase.
+func NewMnemonicValidation() *MnemonicValidation {
+	return &MnemonicValidation{
+		Valid:         true,
+		ValidChecksum: true,
+	}
+}
+
+// NewInvalidMnemonicWord creates a new MnemonicValidation instance for a
+// phrase containing an unknown word.
+func NewInvalidMnemonicWord(message, word string, position int) *MnemonicValidation {
+	return &MnemonicValidation{
+		Message:  message,
+		Word:     word,
+		Position: position,
+	}
+}
+
+// NewInvalidMnemonic creates a new MnemonicValidation instance for a phrase
+// that is invalid for any other reason.
+func NewInvalidMnemonic(message string) *MnemonicValidation {
+	return &MnemonicValidation{
+		Message: message,
+	}
+}
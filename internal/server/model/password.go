@@ -11,6 +11,19 @@ type Password struct {
 	// PIN is a password generated using a cryptographically secure random
 	// number generator, but with only digits.
 	PIN string `json:"pin,omitempty"`
+
+	// Mnemonic is a BIP 39 mnemonic phrase.
+	Mnemonic string `json:"mnemonic,omitempty"`
+
+	// Pronounceable is a password made of pronounceable syllables.
+	Pronounceable string `json:"pronounceable,omitempty"`
+
+	// Entropy is the entropy of the password in bits, for generators that can
+	// account for it exactly.
+	Entropy float64 `json:"entropy,omitempty"`
+
+	// Hashes are the hashes of the secret requested by the client.
+	Hashes []Hash `json:"hashes,omitempty"`
 }
 
 // NewDicewarePassword creates a new password using the Diceware method.
@@ -35,3 +48,19 @@ func NewPIN(pin string) *Password {
 		PIN: pin,
 	}
 }
+
+// NewMnemonic creates a new password using a BIP 39 mnemonic phrase.
+func NewMnemonic(mnemonic string) *Password {
+	return &Password{
+		Mnemonic: mnemonic,
+	}
+}
+
+// NewPronounceablePassword creates a new password made of pronounceable
+// syllables with the given entropy.
+func NewPronounceablePassword(pronounceable string, entropy float64) *Password {
+	return &Password{
+		Pronounceable: pronounceable,
+		Entropy:       entropy,
+	}
+}
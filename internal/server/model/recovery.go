@@ -0,0 +1,23 @@
+package model
+
+// RecoveryCodes represents a set of single-use recovery codes.
+type RecoveryCodes struct {
+	// Algorithm is the algorithm used to compute Hashes, if any.
+	Algorithm string `json:"algorithm,omitempty"`
+
+	// Codes are the recovery codes.
+	Codes []string `json:"codes"`
+
+	// Hashes are the hashes of the normalized recovery codes, in the same
+	// order as Codes.
+	Hashes []string `json:"hashes,omitempty"`
+}
+
+// NewRecoveryCodes creates a new RecoveryCodes instance.
+func NewRecoveryCodes(codes, hashes []string, algorithm string) *RecoveryCodes {
+	return &RecoveryCodes{
+		Algorithm: algorithm,
+		Codes:     codes,
+		Hashes:    hashes,
+	}
+}
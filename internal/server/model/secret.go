@@ -0,0 +1,43 @@
+package model
+
+import "fmt"
+
+// Redacted is what a Secret turns into everywhere but the response writer.
+const Redacted string = "[REDACTED]"
+
+// Secret is a secret value, such as a generated password or a candidate
+// password sent for validation. It formats, marshals, and logs as Redacted, so
+// it cannot leak into logs or error messages by accident; call Reveal to get
+// the value when writing the response.
+type Secret string
+
+// Reveal returns the secret value.
+func (s Secret) Reveal() string {
+	return string(s)
+}
+
+// String implements the fmt.Stringer interface.
+func (Secret) String() string {
+	return Redacted
+}
+
+// GoString implements the fmt.GoStringer interface.
+func (Secret) GoString() string {
+	return Redacted
+}
+
+// Format implements the fmt.Formatter interface, so that every verb, %x and %q
+// included, prints Redacted.
+func (Secret) Format(f fmt.State, _ rune) {
+	_, _ = f.Write([]byte(Redacted))
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (Secret) MarshalText() ([]byte, error) {
+	return []byte(Redacted), nil
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (Secret) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + Redacted + `"`), nil
+}
@@ -0,0 +1,56 @@
+package model_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/server/model"
+)
+
+func TestSecretIsRedacted(t *testing.T) {
+	t.Parallel()
+
+	const value = "hunter2-secret"
+
+	secret := model.Secret(value)
+
+	if secret.Reveal() != value {
+		t.Fatalf("Reveal() = %q, want %q", secret.Reveal(), value)
+	}
+
+	for _, verb := range []string{"%v", "%+v", "%s", "%q", "%x", "%X", "%#v"} {
+		for name, arg := range map[string]any{
+			"value":   secret,
+			"pointer": &secret,
+			"struct":  struct{ Secret model.Secret }{secret},
+			"slice":   []model.Secret{secret},
+		} {
+			got := fmt.Sprintf(verb, arg)
+
+			if strings.Contains(got, value) || strings.Contains(got, fmt.Sprintf("%x", value)) {
+				t.Errorf("%s of %s leaks the secret: %s", verb, name, got)
+			}
+		}
+	}
+
+	for name, arg := range map[string]any{
+		"value":  secret,
+		"struct": struct{ Secret model.Secret }{secret},
+		"map":    map[model.Secret]model.Secret{secret: secret},
+	} {
+		got, err := json.Marshal(arg)
+		if err != nil {
+			t.Fatalf("failed to marshal %s: %v", name, err)
+		}
+
+		if strings.Contains(string(got), value) {
+			t.Errorf("JSON of %s leaks the secret: %s", name, got)
+		}
+
+		if !strings.Contains(string(got), model.Redacted) {
+			t.Errorf("JSON of %s is not redacted: %s", name, got)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// Share represents a one-time link to a shared secret.
+type Share struct {
+	// ExpiresAt is the time after which the secret can no longer be read.
+	ExpiresAt time.Time `json:"expiresAt"`
+
+	// URL is the link to the secret. The key decrypting it is in the
+	// fragment, so it is never sent to the server by browsers.
+	URL string `json:"url"`
+
+	// ID is the identifier of the share.
+	ID string `json:"id"`
+}
+
+// NewShare creates a new Share instance.
+func NewShare(id, url string, expiresAt time.Time) *Share {
+	return &Share{
+		ExpiresAt: expiresAt,
+		URL:       url,
+		ID:        id,
+	}
+}
@@ -0,0 +1,19 @@
+package model
+
+// Shares represents the shares a secret was split into with Shamir's Secret
+// Sharing.
+type Shares struct {
+	// Shares are the encoded shares.
+	Shares []string `json:"shares"`
+
+	// Threshold is the number of shares needed to recover the secret.
+	Threshold int `json:"threshold,omitempty"`
+}
+
+// NewShares creates a new Shares instance.
+func NewShares(shares []string, threshold int) *Shares {
+	return &Shares{
+		Shares:    shares,
+		Threshold: threshold,
+	}
+}
@@ -3,10 +3,15 @@ package server
 
 import (
 	"context"
+	"crypto/rand"
 	"crypto/tls"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"net/netip"
 	"os"
 	"os/signal"
 	"syscall"
@@ -14,20 +19,56 @@ import (
 
 	"git.sr.ht/~jamesponddotco/acciopassword/internal/cerrors"
 	"git.sr.ht/~jamesponddotco/acciopassword/internal/config"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/cors"
 	"git.sr.ht/~jamesponddotco/acciopassword/internal/database"
 	"git.sr.ht/~jamesponddotco/acciopassword/internal/endpoint"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/ipfilter"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/mtls"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/passhash"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/pow"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/proxy"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/ratelimit"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/replay"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/rnghealth"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/sandbox"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/secmem"
 	"git.sr.ht/~jamesponddotco/acciopassword/internal/server/handler"
 	"git.sr.ht/~jamesponddotco/acciopassword/internal/server/middleware"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/share"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/unique"
 	"git.sr.ht/~jamesponddotco/xstd-go/xcrypto/xtls"
 	"go.uber.org/zap"
 )
 
 type Server struct {
-	httpServer *http.Server
-	logger     *zap.Logger
+	httpServer     *http.Server
+	limiter        *ratelimit.Limiter
+	nonces         *replay.Cache
+	ipFilter       *ipfilter.Filter
+	gate           *pow.Gate
+	shares         *share.Store
+	registry       *unique.Registry
+	monitor        *rnghealth.Monitor
+	accessLog      *os.File
+	credentials    *sandbox.Credentials
+	logger         *zap.Logger
+	trustedProxies []netip.Prefix
+	proxyProtocol  bool
+	seccomp        bool
 }
 
 func New(cfg *config.Config, db *database.DB, logger *zap.Logger) (*Server, error) {
+	credentials, err := sandbox.LookupCredentials(cfg.Sandbox.User, cfg.Sandbox.Group)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve server user: %w", err)
+	}
+
+	if cfg.Memory.DisableCoreDumps {
+		if err := secmem.DisableCoreDumps(); err != nil {
+			return nil, fmt.Errorf("failed to disable core dumps: %w", err)
+		}
+	}
+
 	cert, err := tls.LoadX509KeyPair(cfg.Server.TLS.Certificate, cfg.Server.TLS.Key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
@@ -45,42 +86,406 @@ func New(cfg *config.Config, db *database.DB, logger *zap.Logger) (*Server, erro
 
 	tlsConfig.Certificates = []tls.Certificate{cert}
 
+	clientAuth, err := mtls.ParseVerifyMode(cfg.Server.TLS.ClientAuth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure client certificates: %w", err)
+	}
+
+	clientIdentity, err := mtls.ParseIdentitySource(cfg.Server.TLS.ClientIdentity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure client certificates: %w", err)
+	}
+
+	if clientAuth != mtls.VerifyOff {
+		clientCAs, err := mtls.LoadCertificates(cfg.Server.TLS.ClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client CA: %w", err)
+		}
+
+		tlsConfig.ClientAuth = clientAuth.ClientAuth()
+		tlsConfig.ClientCAs = mtls.CertPool(clientCAs)
+
+		if cfg.Server.TLS.CRL != "" {
+			checker, err := mtls.NewRevocationChecker(cfg.Server.TLS.CRL, clientCAs)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load CRL: %w", err)
+			}
+
+			tlsConfig.VerifyConnection = checker.VerifyConnection
+		}
+	}
+
+	trustedProxies, err := proxy.ParsePrefixes(cfg.Server.Proxy.Trusted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse trusted proxies: %w", err)
+	}
+
 	middlewares := []func(http.Handler) http.Handler{
 		func(h http.Handler) http.Handler { return middleware.PanicRecovery(logger, h) },
 		func(h http.Handler) http.Handler { return middleware.UserAgent(logger, h) },
-		func(h http.Handler) http.Handler { return middleware.AcceptRequests(logger, h) },
 		func(h http.Handler) http.Handler { return middleware.PrivacyPolicy(cfg.PrivacyPolicy, h) },
 		func(h http.Handler) http.Handler { return middleware.TermsOfService(cfg.TermsOfService, h) },
-		middleware.CORS,
 	}
 
+	if clientAuth != mtls.VerifyOff {
+		middlewares = append(middlewares, func(h http.Handler) http.Handler {
+			return middleware.ClientCertificate(db, clientIdentity, logger, h)
+		})
+	}
+
+	middlewares = append(middlewares, func(h http.Handler) http.Handler {
+		return middleware.ClientAddress(trustedProxies, h)
+	})
+
+	var ipFilter *ipfilter.Filter
+
+	switch {
+	case cfg.IPFilter.File != "":
+		ipFilter, err = ipfilter.Load(cfg.IPFilter.File, time.Duration(cfg.IPFilter.ReloadInterval)*time.Second, logger)
+	case cfg.IPFilter.Enabled():
+		rules := &ipfilter.Rules{
+			Default:   (*ipfilter.Rule)(cfg.IPFilter.Default),
+			Endpoints: make(map[string]*ipfilter.Rule, len(cfg.IPFilter.Endpoints)),
+		}
+
+		for path, rule := range cfg.IPFilter.Endpoints {
+			rules.Endpoints[path] = (*ipfilter.Rule)(rule)
+		}
+
+		ipFilter, err = ipfilter.New(rules)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to load IP filter: %w", err)
+	}
+
+	corsPolicies := make(map[string]*cors.Policy)
+
+	for _, path := range endpoint.Paths() {
+		corsPolicies[path], err = cors.New((*cors.Rule)(cfg.CORS.Rule(path)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure CORS for %s: %w", path, err)
+		}
+	}
+
+	var gate *pow.Gate
+
+	if cfg.ProofOfWork.Enabled {
+		secret, err := base64.StdEncoding.DecodeString(cfg.ProofOfWork.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode proof-of-work secret: %w", err)
+		}
+
+		gate, err = pow.New(&pow.Options{
+			Secret:        secret,
+			TTL:           time.Duration(cfg.ProofOfWork.TTL) * time.Second,
+			Window:        time.Duration(cfg.ProofOfWork.Window) * time.Second,
+			Difficulty:    cfg.ProofOfWork.Difficulty,
+			MaxDifficulty: cfg.ProofOfWork.MaxDifficulty,
+			Threshold:     cfg.ProofOfWork.Threshold,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure proof of work: %w", err)
+		}
+	}
+
+	var (
+		skew   = time.Duration(cfg.Signing.ClockSkew) * time.Second
+		nonces = replay.New(2 * skew)
+	)
+
+	var auditKey []byte
+
+	if cfg.Audit.Enabled {
+		auditKey, err = base64.StdEncoding.DecodeString(cfg.Audit.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode audit key: %w", err)
+		}
+	}
+
+	// The randomness source is tested before any secret is generated, and
+	// sampled in the background from then on.
+	monitor := rnghealth.New(
+		rand.Reader,
+		cfg.RNGHealth.SampleSize,
+		time.Duration(cfg.RNGHealth.SampleInterval)*time.Second,
+		logger,
+	)
+
+	var registry *unique.Registry
+
+	if cfg.Uniqueness.Enabled {
+		key, err := base64.StdEncoding.DecodeString(cfg.Uniqueness.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode uniqueness key: %w", err)
+		}
+
+		registry, err = unique.New(
+			db,
+			key,
+			time.Duration(cfg.Uniqueness.Retention)*time.Second,
+			time.Duration(cfg.Uniqueness.SweepInterval)*time.Second,
+			logger,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure uniqueness: %w", err)
+		}
+	}
+
+	limiter := ratelimit.New(time.Duration(cfg.RateLimiting.IdleTimeout) * time.Second)
+
+	var authFailures *ratelimit.Limit
+
+	if limit := cfg.Authentication.Failures; !limit.Disabled {
+		authFailures = &ratelimit.Limit{
+			Rate:  float64(limit.Requests) / float64(limit.Period),
+			Burst: limit.Burst,
+		}
+	}
+
+	// chain wraps a handler with the common middlewares and those configured
+	// for its endpoint. Requests go through them in this order:
+	//
+	//  1. Security and CORS headers, so they are sent with every response,
+	//     and preflights are answered before any credentials are checked.
+	//  2. The common middlewares, which resolve the client address and
+	//     certificate, counting the request in the client's metrics.
+	//  3. Method checks and the IP filter, before anything else touches the
+	//     database.
+	//  4. Signatures and API keys, so that clients with a key are rate limited
+	//     by key rather than by IP address.
+	//  5. The rate limit, then proofs of work, which are costlier to check and
+	//     not asked of share links being opened.
+	//  6. Quotas, only charged for requests that made it this far.
+	//  7. The audit log, once the client has been identified.
+	//  8. Encryption to the client's key and splitting into shares, right as
+	//     secrets leave the handler.
+	//  9. Regeneration of secrets issued before, and the randomness source
+	//     health check for requests using randomness, before the handler
+	//     generates anything.
+	chain := func(h http.Handler, path string) http.Handler {
+		var handlerMiddlewares []func(http.Handler) http.Handler
+
+		if cfg.RNGHealth.FailClosed && endpoint.Scope(path) != "" {
+			handlerMiddlewares = append(handlerMiddlewares,
+				func(h http.Handler) http.Handler {
+					gated := middleware.RNGHealth(monitor, logger, h)
+
+					return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						if !usesRandomness(path, r) {
+							h.ServeHTTP(w, r)
+
+							return
+						}
+
+						gated.ServeHTTP(w, r)
+					})
+				},
+			)
+		}
+
+		if registry != nil && endpoint.Scope(path) != "" && path != endpoint.Share {
+			handlerMiddlewares = append(handlerMiddlewares,
+				func(h http.Handler) http.Handler {
+					return middleware.Unique(registry, cfg.Uniqueness.MaxAttempts, float64(cfg.Uniqueness.MinEntropy), logger, h)
+				},
+			)
+		}
+
+		if endpoint.Scope(path) != "" && path != endpoint.Share {
+			handlerMiddlewares = append(handlerMiddlewares,
+				func(h http.Handler) http.Handler { return middleware.Split(logger, h) },
+				func(h http.Handler) http.Handler { return middleware.Encrypt(logger, h) },
+			)
+		}
+
+		if cfg.Audit.Enabled && endpoint.Scope(path) != "" {
+			handlerMiddlewares = append(handlerMiddlewares,
+				func(h http.Handler) http.Handler {
+					return middleware.Audit(db, auditKey, endpoint.Name(path), logger, h)
+				},
+			)
+		}
+
+		handlerMiddlewares = append(handlerMiddlewares,
+			func(h http.Handler) http.Handler { return middleware.Quota(db, logger, h) },
+		)
+
+		if gate != nil && endpoint.Scope(path) != "" {
+			handlerMiddlewares = append(handlerMiddlewares,
+				func(h http.Handler) http.Handler {
+					gated := middleware.ProofOfWork(gate, logger, h)
+
+					if path != endpoint.Share {
+						return gated
+					}
+
+					// Share links are opened in browsers, which cannot solve
+					// challenges, so only creating a share requires one.
+					return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						if r.Method == http.MethodGet || r.Method == http.MethodHead {
+							h.ServeHTTP(w, r)
+
+							return
+						}
+
+						gated.ServeHTTP(w, r)
+					})
+				},
+			)
+		}
+
+		if limit := cfg.RateLimiting.Limit(path); !limit.Disabled {
+			rateLimit := ratelimit.Limit{
+				Rate:  float64(limit.Requests) / float64(limit.Period),
+				Burst: limit.Burst,
+			}
+
+			handlerMiddlewares = append(handlerMiddlewares,
+				func(h http.Handler) http.Handler { return middleware.RateLimit(limiter, path, rateLimit, logger, h) },
+			)
+		}
+
+		var (
+			scope    = endpoint.Scope(path)
+			required = cfg.Authentication.Requires(path)
+		)
+
+		handlerMiddlewares = append(handlerMiddlewares,
+			func(h http.Handler) http.Handler {
+				return middleware.APIKey(db, scope, required, limiter, authFailures, logger, h)
+			},
+		)
+
+		handlerMiddlewares = append(handlerMiddlewares,
+			func(h http.Handler) http.Handler {
+				return middleware.Signature(db, nonces, skew, cfg.Signing.MaxBodySize, logger, h)
+			},
+		)
+
+		if ipFilter != nil {
+			handlerMiddlewares = append(handlerMiddlewares,
+				func(h http.Handler) http.Handler { return middleware.IPFilter(ipFilter, path, logger, h) },
+			)
+		}
+
+		methods := endpoint.Methods(path)
+
+		handlerMiddlewares = append(handlerMiddlewares,
+			func(h http.Handler) http.Handler { return middleware.AcceptRequests(methods, logger, h) },
+		)
+
+		handlerMiddlewares = append(handlerMiddlewares, middlewares...)
+		handlerMiddlewares = append(handlerMiddlewares,
+			func(h http.Handler) http.Handler { return middleware.CORS(corsPolicies[path], h) },
+			func(h http.Handler) http.Handler {
+				return middleware.SecurityHeaders(cfg.SecurityHeaders.Headers(path), h)
+			},
+		)
+
+		return middleware.Chain(h, handlerMiddlewares...)
+	}
+
+	hasher := passhash.NewLimiter(&passhash.Params{
+		Argon2Time:       cfg.Hashing.MaxArgon2Time,
+		Argon2Memory:     cfg.Hashing.MaxArgon2Memory,
+		Argon2Threads:    cfg.Hashing.MaxArgon2Threads,
+		BcryptCost:       cfg.Hashing.MaxBcryptCost,
+		ScryptLogN:       cfg.Hashing.MaxScryptLogN,
+		ScryptR:          cfg.Hashing.MaxScryptR,
+		ScryptP:          cfg.Hashing.MaxScryptP,
+		PBKDF2Iterations: cfg.Hashing.MaxPBKDF2Iterations,
+		SHACryptRounds:   cfg.Hashing.MaxSHACryptRounds,
+		SCRAMIterations:  cfg.Hashing.MaxSCRAMIterations,
+		MySQLIterations:  cfg.Hashing.MaxMySQLIterations,
+	}, cfg.Hashing.MaxCost, cfg.Hashing.MaxConcurrent)
+
 	var (
-		dicewareHandler = handler.NewDicewareHandler(db, logger)
-		randomHandler   = handler.NewRandomHandler(db, logger)
-		pinHandler      = handler.NewPINHandler(db, logger)
-		metricsHandler  = handler.NewMetricsHandler(db, logger)
-		healthHandler   = handler.NewHealthHandler(db, logger)
-		pingHandler     = handler.NewPingHandler(logger)
+		dicewareHandler      = handler.NewDicewareHandler(db, hasher, logger)
+		randomHandler        = handler.NewRandomHandler(db, hasher, cfg.Memory.LockMemory, logger)
+		pinHandler           = handler.NewPINHandler(db, hasher, cfg.Memory.LockMemory, logger)
+		wireguardHandler     = handler.NewWireGuardHandler(db, hasher, logger)
+		wireguardPSKHandler  = handler.NewWireGuardPSKHandler(db, hasher, logger)
+		ageHandler           = handler.NewAgeHandler(db, hasher, logger)
+		mnemonicHandler      = handler.NewMnemonicHandler(db, hasher, logger)
+		validationHandler    = handler.NewMnemonicValidationHandler(cfg.Memory.LockMemory, logger)
+		combineHandler       = handler.NewCombineHandler(cfg.Memory.LockMemory, logger)
+		pronounceableHandler = handler.NewPronounceableHandler(db, hasher, logger)
+		recoveryCodesHandler = handler.NewRecoveryCodesHandler(db, hasher, logger)
+		deriveHandler        = handler.NewDeriveHandler(db, hasher, cfg.Memory.LockMemory, logger)
+		metricsHandler       = handler.NewMetricsHandler(db, logger)
+		healthHandler        = handler.NewHealthHandler(db, monitor, logger)
+		pingHandler          = handler.NewPingHandler(logger)
 	)
 
 	mux := http.NewServeMux()
-	mux.HandleFunc(endpoint.Root, func(w http.ResponseWriter, r *http.Request) {
+	mux.Handle(endpoint.Root, middleware.SecurityHeaders(cfg.SecurityHeaders.Headers(endpoint.Root), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		cerrors.JSON(w, logger, cerrors.ErrorResponse{
 			Code:    http.StatusNotFound,
 			Message: "Page not found. Check the URL and try again.",
 		})
-	})
+	})))
+
+	mux.Handle(endpoint.Diceware, chain(dicewareHandler, endpoint.Diceware))
+	mux.Handle(endpoint.Random, chain(randomHandler, endpoint.Random))
+	mux.Handle(endpoint.PIN, chain(pinHandler, endpoint.PIN))
+	mux.Handle(endpoint.WireGuard, chain(wireguardHandler, endpoint.WireGuard))
+	mux.Handle(endpoint.WireGuardPSK, chain(wireguardPSKHandler, endpoint.WireGuardPSK))
+	mux.Handle(endpoint.Age, chain(ageHandler, endpoint.Age))
+	mux.Handle(endpoint.Mnemonic, chain(mnemonicHandler, endpoint.Mnemonic))
+	mux.Handle(endpoint.MnemonicValidation, chain(validationHandler, endpoint.MnemonicValidation))
+	mux.Handle(endpoint.Pronounceable, chain(pronounceableHandler, endpoint.Pronounceable))
+	mux.Handle(endpoint.RecoveryCodes, chain(recoveryCodesHandler, endpoint.RecoveryCodes))
+	mux.Handle(endpoint.Derive, chain(deriveHandler, endpoint.Derive))
+	mux.Handle(endpoint.Combine, chain(combineHandler, endpoint.Combine))
+
+	var shares *share.Store
+
+	if cfg.Share.Enabled {
+		shares = share.New(db, time.Duration(cfg.Share.SweepInterval)*time.Second, logger)
+
+		mux.Handle(endpoint.Share, chain(handler.NewShareHandler(
+			shares,
+			cfg.Share.BaseURL,
+			time.Duration(cfg.Share.TTL)*time.Second,
+			time.Duration(cfg.Share.MaxTTL)*time.Second,
+			cfg.Share.MaxSize,
+			cfg.Memory.LockMemory,
+			logger,
+		), endpoint.Share))
+	}
+
+	if gate != nil {
+		mux.Handle(endpoint.Challenge, chain(handler.NewChallengeHandler(gate, logger), endpoint.Challenge))
+	}
 
-	mux.Handle(endpoint.Diceware, middleware.Chain(dicewareHandler, middlewares...))
-	mux.Handle(endpoint.Random, middleware.Chain(randomHandler, middlewares...))
-	mux.Handle(endpoint.PIN, middleware.Chain(pinHandler, middlewares...))
-	mux.Handle(endpoint.Metrics, middleware.Chain(metricsHandler, middlewares...))
-	mux.Handle(endpoint.Health, middleware.Chain(healthHandler, middlewares...))
-	mux.Handle(endpoint.Ping, middleware.Chain(pingHandler, middlewares...))
+	mux.Handle(endpoint.Metrics, chain(metricsHandler, endpoint.Metrics))
+	mux.Handle(endpoint.Health, chain(healthHandler, endpoint.Health))
+	mux.Handle(endpoint.Ping, chain(pingHandler, endpoint.Ping))
+
+	var (
+		root      http.Handler = mux
+		accessLog *os.File
+	)
+
+	if !cfg.AccessLog.Disabled {
+		var out io.Writer = os.Stdout
+
+		if cfg.AccessLog.Format == middleware.AccessLogCombined && cfg.AccessLog.Path != "" {
+			accessLog, err = os.OpenFile(cfg.AccessLog.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o640)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open access log: %w", err)
+			}
+
+			out = accessLog
+		}
+
+		root = middleware.AccessLog(cfg.AccessLog.Format, out, logger, root)
+	}
 
 	httpServer := &http.Server{
 		Addr:         cfg.Server.Address,
-		Handler:      mux,
+		Handler:      middleware.RequestID(logger, root),
 		TLSConfig:    tlsConfig,
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 10 * time.Second,
@@ -88,8 +493,20 @@ func New(cfg *config.Config, db *database.DB, logger *zap.Logger) (*Server, erro
 	}
 
 	return &Server{
-		httpServer: httpServer,
-		logger:     logger,
+		httpServer:     httpServer,
+		limiter:        limiter,
+		nonces:         nonces,
+		ipFilter:       ipFilter,
+		gate:           gate,
+		shares:         shares,
+		registry:       registry,
+		monitor:        monitor,
+		accessLog:      accessLog,
+		credentials:    credentials,
+		seccomp:        cfg.Sandbox.Enabled,
+		logger:         logger,
+		trustedProxies: trustedProxies,
+		proxyProtocol:  cfg.Server.Proxy.ProxyProtocol,
 	}, nil
 }
 
@@ -111,10 +528,54 @@ func (s *Server) Start() error {
 			s.logger.Error("HTTP server Shutdown:", zap.Error(err))
 		}
 
+		s.limiter.Close()
+		s.nonces.Close()
+
+		if s.ipFilter != nil {
+			s.ipFilter.Close()
+		}
+
+		if s.gate != nil {
+			s.gate.Close()
+		}
+
+		if s.shares != nil {
+			s.shares.Close()
+		}
+
+		if s.registry != nil {
+			s.registry.Close()
+		}
+
+		s.monitor.Close()
+
+		if s.accessLog != nil {
+			s.accessLog.Close()
+		}
+
 		close(shutdownCompleted)
 	}()
 
-	if err := s.httpServer.ListenAndServeTLS("", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
+	listener, err := net.Listen("tcp", s.httpServer.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to start server: %w", err)
+	}
+
+	if s.proxyProtocol {
+		listener = proxy.NewListener(listener, s.trustedProxies)
+	}
+
+	if err := sandbox.DropPrivileges(s.credentials); err != nil {
+		return fmt.Errorf("failed to start server: %w", err)
+	}
+
+	if s.seccomp {
+		if err := sandbox.Seccomp(); err != nil {
+			return fmt.Errorf("failed to start server: %w", err)
+		}
+	}
+
+	if err := s.httpServer.ServeTLS(listener, "", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		return fmt.Errorf("failed to start server: %w", err)
 	}
 
@@ -128,5 +589,46 @@ func (s *Server) Shutdown(ctx context.Context) error {
 		return fmt.Errorf("failed to shutdown server: %w", err)
 	}
 
+	s.limiter.Close()
+	s.nonces.Close()
+
+	if s.ipFilter != nil {
+		s.ipFilter.Close()
+	}
+
+	if s.gate != nil {
+		s.gate.Close()
+	}
+
+	if s.shares != nil {
+		s.shares.Close()
+	}
+
+	if s.registry != nil {
+		s.registry.Close()
+	}
+
+	s.monitor.Close()
+
+	if s.accessLog != nil {
+		if err := s.accessLog.Close(); err != nil {
+			return fmt.Errorf("failed to close access log: %w", err)
+		}
+	}
+
 	return nil
 }
+
+// usesRandomness reports whether serving a request to the given endpoint reads
+// from the randomness source. Opening share links does not, and neither does
+// deriving passwords, unless they are split into shares or encrypted.
+func usesRandomness(path string, r *http.Request) bool {
+	switch path {
+	case endpoint.Share:
+		return r.Method != http.MethodGet && r.Method != http.MethodHead
+	case endpoint.Derive:
+		return r.URL.Query().Get(middleware.SplitParameter) != "" || r.URL.Query().Get(middleware.RecipientParameter) != ""
+	default:
+		return true
+	}
+}
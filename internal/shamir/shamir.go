@@ -0,0 +1,136 @@
+// Package shamir implements Shamir's Secret Sharing over GF(256), and an
+// encoding of shares that lets a combined secret be checked for integrity.
+//
+// Every byte of the secret is split separately, with its own random
+// polynomial, and arithmetic is done in constant time so that no table lookup
+// leaks the secret through the cache.
+package shamir
+
+import (
+	"crypto/rand"
+	"fmt"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/secmem"
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+)
+
+const (
+	// ErrInvalidThreshold is returned when the threshold is lower than two or
+	// higher than the number of shares.
+	ErrInvalidThreshold xerrors.Error = "threshold must be between 2 and the number of shares"
+
+	// ErrTooManyShares is returned when more than MaxShares shares are
+	// requested.
+	ErrTooManyShares xerrors.Error = "too many shares"
+
+	// ErrEmptySecret is returned when splitting an empty secret.
+	ErrEmptySecret xerrors.Error = "secret cannot be empty"
+)
+
+// MaxShares is the maximum number of shares a secret can be split into, since
+// every share needs its own non-zero x coordinate in GF(256).
+const MaxShares int = 255
+
+// split splits secret into the given number of shares, any threshold of which
+// can recover it. Share i is the value at x = i + 1 of every polynomial.
+func split(secret []byte, threshold, shares int) ([][]byte, error) {
+	switch {
+	case len(secret) == 0:
+		return nil, ErrEmptySecret
+	case shares > MaxShares:
+		return nil, ErrTooManyShares
+	case threshold < 2 || threshold > shares:
+		return nil, ErrInvalidThreshold
+	}
+
+	// coefficients holds the random coefficients of every polynomial but the
+	// constant term, which is the byte of the secret.
+	coefficients := make([]byte, threshold-1)
+	defer secmem.Zero(coefficients)
+
+	values := make([][]byte, shares)
+	for i := range values {
+		values[i] = make([]byte, len(secret))
+	}
+
+	for i, b := range secret {
+		if _, err := rand.Read(coefficients); err != nil {
+			return nil, fmt.Errorf("failed to generate polynomial: %w", err)
+		}
+
+		for j := range values {
+			values[j][i] = evaluate(b, coefficients, byte(j+1))
+		}
+	}
+
+	return values, nil
+}
+
+// combine recovers the secret from shares taken at the given x coordinates,
+// which must be distinct and non-zero, by Lagrange interpolation at zero.
+func combine(xs []byte, values [][]byte) []byte {
+	secret := make([]byte, len(values[0]))
+
+	for i := range xs {
+		// The Lagrange basis polynomial for share i, evaluated at zero.
+		// Subtraction is XOR in GF(256), so 0 - x is x.
+		basis := byte(1)
+
+		for j := range xs {
+			if i == j {
+				continue
+			}
+
+			basis = mul(basis, mul(xs[j], inverse(xs[i]^xs[j])))
+		}
+
+		for k := range secret {
+			secret[k] ^= mul(values[i][k], basis)
+		}
+	}
+
+	return secret
+}
+
+// evaluate evaluates at x the polynomial with the given constant term and
+// coefficients, using Horner's method.
+func evaluate(constant byte, coefficients []byte, x byte) byte {
+	var y byte
+
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		y = mul(y, x) ^ coefficients[i]
+	}
+
+	return mul(y, x) ^ constant
+}
+
+// mul multiplies two elements of GF(256), using the AES reduction polynomial
+// x^8 + x^4 + x^3 + x + 1, in constant time.
+func mul(a, b byte) byte {
+	var p byte
+
+	for i := 0; i < 8; i++ {
+		p ^= -(b & 1) & a
+		a = a<<1 ^ (-(a >> 7) & 0x1b)
+		b >>= 1
+	}
+
+	return p
+}
+
+// inverse returns the multiplicative inverse of a non-zero element of GF(256)
+// as a^254, in constant time.
+func inverse(a byte) byte {
+	var (
+		result = byte(1)
+		power  = a
+	)
+
+	// 254 is 0b11111110.
+	for i := 1; i < 8; i++ {
+		power = mul(power, power)
+		result = mul(result, power)
+	}
+
+	return result
+}
@@ -0,0 +1,253 @@
+package shamir
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/secmem"
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+)
+
+const (
+	// ErrInvalidShare is returned when a share cannot be decoded or its
+	// checksum does not match.
+	ErrInvalidShare xerrors.Error = "invalid share"
+
+	// ErrMismatchedShares is returned when shares come from different
+	// splits.
+	ErrMismatchedShares xerrors.Error = "shares belong to different secrets"
+
+	// ErrDuplicateShare is returned when the same share is given twice.
+	ErrDuplicateShare xerrors.Error = "duplicate share"
+
+	// ErrNotEnoughShares is returned when fewer shares than the threshold are
+	// given.
+	ErrNotEnoughShares xerrors.Error = "not enough shares"
+
+	// ErrIntegrity is returned when the combined secret does not match the
+	// digest it was split with, because a share was altered.
+	ErrIntegrity xerrors.Error = "combined secret failed integrity check"
+)
+
+const (
+	// SharePrefix is the prefix of encoded shares.
+	SharePrefix string = "acopw-share-"
+
+	// shareVersion is the version of the share encoding.
+	shareVersion byte = 1
+
+	// idSize is the size in bytes of the random ID tying the shares of a
+	// split together.
+	idSize int = 8
+
+	// digestSize is the size in bytes of the digest of the secret split
+	// alongside it.
+	digestSize int = 16
+
+	// checksumSize is the size in bytes of the checksum of every share,
+	// catching transcription errors.
+	checksumSize int = 4
+
+	// headerSize is the size in bytes of the fields preceding the value of
+	// an encoded share: version, format, threshold, index, and ID.
+	headerSize int = 4 + idSize
+)
+
+const (
+	// FormatText marks secrets split from plain text responses.
+	FormatText byte = 0
+
+	// FormatJSON marks secrets split from JSON responses.
+	FormatJSON byte = 1
+)
+
+// Share is one of the shares a secret was split into.
+type Share struct {
+	// Value is the share of the secret and its digest.
+	Value []byte
+
+	// ID ties together the shares of a split.
+	ID [idSize]byte
+
+	// Format describes the media type of the secret, such as FormatText.
+	Format byte
+
+	// Threshold is the number of shares needed to recover the secret.
+	Threshold byte
+
+	// Index is the x coordinate of the share, starting at one.
+	Index byte
+}
+
+// Split splits secret into the given number of shares, any threshold of which
+// can recover it. A digest of the secret is split alongside it, so that
+// Combine can tell whether the shares it was given were altered.
+func Split(secret []byte, format byte, threshold, shares int) ([]*Share, error) {
+	if len(secret) == 0 {
+		return nil, ErrEmptySecret
+	}
+
+	var id [idSize]byte
+
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, fmt.Errorf("failed to generate split ID: %w", err)
+	}
+
+	plaintext := make([]byte, 0, len(secret)+digestSize)
+	defer func() { secmem.Zero(plaintext) }()
+
+	plaintext = append(plaintext, secret...)
+	plaintext = append(plaintext, digest(id, format, secret)...)
+
+	values, err := split(plaintext, threshold, shares)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*Share, len(values))
+
+	for i, value := range values {
+		result[i] = &Share{
+			Value:     value,
+			ID:        id,
+			Format:    format,
+			Threshold: byte(threshold),
+			Index:     byte(i + 1),
+		}
+	}
+
+	return result, nil
+}
+
+// Combine recovers a secret from its shares and checks it against the digest
+// it was split with. The returned secret must be wiped by the caller.
+func Combine(shares []*Share) ([]byte, error) {
+	if len(shares) == 0 {
+		return nil, ErrNotEnoughShares
+	}
+
+	first := shares[0]
+
+	if len(shares) < int(first.Threshold) {
+		return nil, fmt.Errorf("%w: got %d, need %d", ErrNotEnoughShares, len(shares), first.Threshold)
+	}
+
+	var (
+		xs     = make([]byte, 0, len(shares))
+		values = make([][]byte, 0, len(shares))
+		seen   = make(map[byte]bool, len(shares))
+	)
+
+	for _, share := range shares {
+		if share.ID != first.ID || share.Format != first.Format || share.Threshold != first.Threshold || len(share.Value) != len(first.Value) {
+			return nil, ErrMismatchedShares
+		}
+
+		if seen[share.Index] {
+			return nil, fmt.Errorf("%w: share %d", ErrDuplicateShare, share.Index)
+		}
+
+		seen[share.Index] = true
+
+		xs = append(xs, share.Index)
+		values = append(values, share.Value)
+	}
+
+	plaintext := combine(xs, values)
+
+	var (
+		secret = plaintext[:len(plaintext)-digestSize]
+		want   = plaintext[len(plaintext)-digestSize:]
+	)
+
+	if subtle.ConstantTimeCompare(digest(first.ID, first.Format, secret), want) != 1 {
+		secmem.Zero(plaintext)
+
+		return nil, ErrIntegrity
+	}
+
+	secmem.Zero(want)
+
+	return secret, nil
+}
+
+// Encode returns the text encoding of the share: SharePrefix followed by the
+// unpadded base64url encoding of its fields and a checksum.
+func (s *Share) Encode() string {
+	raw := make([]byte, 0, headerSize+len(s.Value)+checksumSize)
+	defer func() { secmem.Zero(raw) }()
+
+	raw = append(raw, shareVersion, s.Format, s.Threshold, s.Index)
+	raw = append(raw, s.ID[:]...)
+	raw = append(raw, s.Value...)
+
+	checksum := sha256.Sum256(raw)
+	raw = append(raw, checksum[:checksumSize]...)
+
+	return SharePrefix + base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// ParseShare decodes a share encoded by Share.Encode. It takes the share as
+// bytes, so that callers can wipe it, and wipes every copy it makes.
+func ParseShare(s []byte) (*Share, error) {
+	encoded, ok := bytes.CutPrefix(bytes.TrimSpace(s), []byte(SharePrefix))
+	if !ok {
+		return nil, fmt.Errorf("%w: missing %q prefix", ErrInvalidShare, SharePrefix)
+	}
+
+	raw := make([]byte, base64.RawURLEncoding.DecodedLen(len(encoded)))
+	defer secmem.Zero(raw)
+
+	n, err := base64.RawURLEncoding.Decode(raw, encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidShare, err)
+	}
+
+	raw = raw[:n]
+
+	if len(raw) < headerSize+digestSize+1+checksumSize {
+		return nil, fmt.Errorf("%w: too short", ErrInvalidShare)
+	}
+
+	var (
+		body     = raw[:len(raw)-checksumSize]
+		checksum = sha256.Sum256(body)
+	)
+
+	if !bytes.Equal(checksum[:checksumSize], raw[len(body):]) {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrInvalidShare)
+	}
+
+	if body[0] != shareVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidShare, body[0])
+	}
+
+	share := &Share{
+		Value:     append([]byte(nil), body[headerSize:]...),
+		Format:    body[1],
+		Threshold: body[2],
+		Index:     body[3],
+	}
+
+	copy(share.ID[:], body[4:headerSize])
+
+	if share.Index == 0 || share.Threshold < 2 {
+		return nil, fmt.Errorf("%w: invalid index or threshold", ErrInvalidShare)
+	}
+
+	return share, nil
+}
+
+// digest returns the digest of a secret split with the given ID and format.
+func digest(id [idSize]byte, format byte, secret []byte) []byte {
+	h := sha256.New()
+	h.Write(id[:])
+	h.Write([]byte{format})
+	h.Write(secret)
+
+	return h.Sum(nil)[:digestSize]
+}
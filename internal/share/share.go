@@ -0,0 +1,203 @@
+// Package share implements one-time secret sharing. Secrets are encrypted
+// with a random key that is handed back to the client and never stored, so
+// the database only ever holds ciphertext. A shared secret is deleted the
+// first time it is read, and expired secrets are purged in the background.
+package share
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/database"
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/secmem"
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+	"go.uber.org/zap"
+)
+
+const (
+	// ErrInvalidKey is returned when a key is malformed or does not decrypt
+	// the shared secret.
+	ErrInvalidKey xerrors.Error = "invalid share key"
+
+	// ErrEmptySecret is returned when sharing an empty secret.
+	ErrEmptySecret xerrors.Error = "secret cannot be empty"
+)
+
+const (
+	// idSize is the size in bytes of share IDs.
+	idSize int = 16
+
+	// keySize is the size in bytes of the AES-256 keys secrets are encrypted
+	// with.
+	keySize int = 32
+)
+
+// Store shares secrets through the database.
+type Store struct {
+	db        *database.DB
+	logger    *zap.Logger
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// New returns a new Store and starts deleting expired secrets from the
+// database every interval. Call Close to stop it.
+func New(db *database.DB, interval time.Duration, logger *zap.Logger) *Store {
+	s := &Store{
+		db:     db,
+		logger: logger,
+		done:   make(chan struct{}),
+	}
+
+	go s.sweep(interval)
+
+	return s
+}
+
+// Create encrypts and stores a secret for the given time, and returns the ID
+// of the share and the key needed to read it. The key is encoded with
+// unpadded base64url, so it can be embedded in a URL as is.
+func (s *Store) Create(secret []byte, ttl time.Duration) (*database.Share, string, error) {
+	if len(secret) == 0 {
+		return nil, "", ErrEmptySecret
+	}
+
+	id := make([]byte, idSize)
+	if _, err := rand.Read(id); err != nil {
+		return nil, "", fmt.Errorf("failed to generate share ID: %w", err)
+	}
+
+	rawKey := make([]byte, keySize)
+	defer secmem.Zero(rawKey)
+
+	if _, err := rand.Read(rawKey); err != nil {
+		return nil, "", fmt.Errorf("failed to generate share key: %w", err)
+	}
+
+	aead, err := newAEAD(rawKey)
+	if err != nil {
+		return nil, "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(secret)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, "", fmt.Errorf("failed to generate share nonce: %w", err)
+	}
+
+	now := time.Unix(time.Now().Unix(), 0).UTC()
+
+	share := &database.Share{
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+		ID:        hex.EncodeToString(id),
+	}
+
+	// The ID is authenticated alongside the secret, so ciphertexts cannot be
+	// swapped between shares.
+	share.Ciphertext = aead.Seal(nonce, nonce, secret, []byte(share.ID))
+
+	if err := s.db.CreateShare(share); err != nil {
+		return nil, "", err
+	}
+
+	return share, base64.RawURLEncoding.EncodeToString(rawKey), nil
+}
+
+// Reveal decrypts the secret with the given ID and deletes it. A wrong key
+// does not delete the secret. The returned buffer must be destroyed by the
+// caller.
+func (s *Store) Reveal(id, key string) (*secmem.Buffer, error) {
+	rawKey, err := base64.RawURLEncoding.DecodeString(key)
+	if err != nil || len(rawKey) != keySize {
+		return nil, ErrInvalidKey
+	}
+	defer secmem.Zero(rawKey)
+
+	share, err := s.db.Share(id)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(rawKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(share.Ciphertext) < aead.NonceSize()+aead.Overhead() {
+		return nil, ErrInvalidKey
+	}
+
+	var (
+		nonce      = share.Ciphertext[:aead.NonceSize()]
+		ciphertext = share.Ciphertext[aead.NonceSize():]
+		secret     = secmem.NewBuffer(len(ciphertext) - aead.Overhead())
+	)
+
+	if _, err := aead.Open(secret.Bytes()[:0], nonce, ciphertext, []byte(share.ID)); err != nil {
+		secret.Destroy()
+
+		return nil, ErrInvalidKey
+	}
+
+	// Only the reader whose delete succeeds gets the secret, so it is never
+	// revealed twice even when read concurrently.
+	if err := s.db.DeleteShare(share.ID); err != nil {
+		secret.Destroy()
+
+		return nil, err
+	}
+
+	return secret, nil
+}
+
+// Close stops deleting expired secrets.
+func (s *Store) Close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+}
+
+// sweep periodically deletes expired secrets.
+func (s *Store) sweep(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			deleted, err := s.db.DeleteExpiredShares()
+			if err != nil {
+				s.logger.Error("failed to delete expired shares", zap.Error(err))
+
+				continue
+			}
+
+			if deleted > 0 {
+				s.logger.Debug("deleted expired shares", zap.Int64("count", deleted))
+			}
+		}
+	}
+}
+
+// newAEAD returns an AES-256-GCM cipher using the given key.
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create share cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create share cipher: %w", err)
+	}
+
+	return aead, nil
+}
@@ -0,0 +1,104 @@
+// Package unique guarantees that no secret is issued twice. Every issued
+// secret is fingerprinted with HMAC-SHA256 under a server-side key, and the
+// fingerprint is stored until the retention window passes. Secrets whose
+// fingerprint is already stored are thrown away and generated again.
+//
+// Only fingerprints are stored, never the secrets themselves, and without the
+// key they cannot be used to check guesses against the database.
+package unique
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/acciopassword/internal/database"
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+	"go.uber.org/zap"
+)
+
+// ErrShortKey is returned when the fingerprint key is too short.
+const ErrShortKey xerrors.Error = "fingerprint key must be at least 32 bytes"
+
+// MinKeySize is the minimum size in bytes of the fingerprint key.
+const MinKeySize int = 32
+
+// Registry keeps the fingerprints of issued secrets in the database.
+type Registry struct {
+	db        *database.DB
+	logger    *zap.Logger
+	done      chan struct{}
+	key       []byte
+	retention time.Duration
+	closeOnce sync.Once
+}
+
+// New returns a new Registry fingerprinting secrets with the given key, and
+// starts deleting fingerprints older than retention from the database every
+// interval. A retention of zero or less keeps fingerprints forever. Call Close
+// to stop it.
+func New(db *database.DB, key []byte, retention, interval time.Duration, logger *zap.Logger) (*Registry, error) {
+	if len(key) < MinKeySize {
+		return nil, ErrShortKey
+	}
+
+	r := &Registry{
+		db:        db,
+		logger:    logger,
+		done:      make(chan struct{}),
+		key:       key,
+		retention: retention,
+	}
+
+	if retention > 0 {
+		go r.sweep(interval)
+	}
+
+	return r, nil
+}
+
+// Fingerprint returns the fingerprint of a secret.
+func (r *Registry) Fingerprint(secret []byte) []byte {
+	mac := hmac.New(sha256.New, r.key)
+	mac.Write(secret)
+
+	return mac.Sum(nil)
+}
+
+// Claim stores the given fingerprints and reports whether none of them was
+// issued before. Nothing is stored if any of them was.
+func (r *Registry) Claim(fingerprints [][]byte) (bool, error) {
+	return r.db.ClaimFingerprints(fingerprints) //nolint:wrapcheck // the error is already descriptive
+}
+
+// Close stops deleting old fingerprints.
+func (r *Registry) Close() {
+	r.closeOnce.Do(func() {
+		close(r.done)
+	})
+}
+
+// sweep periodically deletes fingerprints older than the retention window.
+func (r *Registry) sweep(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+			deleted, err := r.db.DeleteFingerprintsBefore(time.Now().Add(-r.retention))
+			if err != nil {
+				r.logger.Error("failed to delete old fingerprints", zap.Error(err))
+
+				continue
+			}
+
+			if deleted > 0 {
+				r.logger.Debug("deleted old fingerprints", zap.Int64("count", deleted))
+			}
+		}
+	}
+}